@@ -0,0 +1,233 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commands are the operator tasks the API binary runs instead of starting the server,
+// as in "adv import teams.csv". Each returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"fixtures": fixturesCommand,
+	"import":   importCommand,
+}
+
+// commandApplication connects to the database for a command, and returns an
+// application that can use the same helpers as the handlers.
+func commandApplication(dsn string) (*application, func(), error) {
+	var cfg config
+	cfg.db.dsn = dsn
+
+	db, err := openDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app := &application{
+		config: cfg,
+		logger: log.New(os.Stderr, "", log.Ldate|log.Ltime),
+		models: data.NewModels(db),
+	}
+	return app, func() { db.Close() }, nil
+}
+
+// importCommand imports teams from a CSV or NDJSON file, as POST /v1/teams/import
+// does, and writes the report to standard output. It exits with status 1 if any row
+// failed.
+func importCommand(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: adv import [flags] file.csv|file.ndjson|-")
+		fs.PrintDefaults()
+	}
+	dsn := fs.String("db-dsn", defaultDSN, "PostgreSQL DSN")
+	format := fs.String("format", "", "Input format, csv or ndjson (default from the file extension)")
+	columnMap := fs.String("map", "", "CSV header mapping, as Column=field,...")
+	atomic := fs.Bool("atomic", false, "Import nothing unless every row is valid")
+	dryRun := fs.Bool("dry-run", false, "Check the rows without importing them")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	path := fs.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	columns, err := parseColumnMap(*columnMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv import: -map: %v\n", err)
+		return 2
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "adv import: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var rows []*teamImportRow
+	switch *format {
+	case "csv":
+		rows, err = readTeamImportCSV(in, columns)
+	case "ndjson", "jsonl":
+		rows, err = readTeamImportNDJSON(in)
+	default:
+		fmt.Fprintln(os.Stderr, "adv import: -format must be csv or ndjson")
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv import: %s: %v\n", path, err)
+		return 1
+	}
+
+	app, closeDB, err := commandApplication(*dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv import: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	// Imports from the command line aren't made on behalf of a user.
+	report, err := app.importTeams(rows, *atomic, *dryRun, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv import: %v\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(envelope{"import": report})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv import: %v\n", err)
+		return 1
+	}
+
+	app.logger.Printf("%d teams read, %d imported, %d failed", report.Total, report.Imported, report.Failed)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// fixturesCommand generates a season's fixtures, as POST /v1/seasons/:id/fixtures
+// does, and writes them to standard output. Its only subcommand is "generate".
+func fixturesCommand(args []string) int {
+	fs := flag.NewFlagSet("fixtures generate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: adv fixtures generate [flags]")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "generate" {
+		fs.Usage()
+		return 2
+	}
+
+	dsn := fs.String("db-dsn", defaultDSN, "PostgreSQL DSN")
+	seasonName := fs.String("season", data.SeasonOf(time.Now()).String(), "Season name, such as 2024-25")
+	firstMatchday := fs.String("first-matchday", "", "Date of the first round, as YYYY-MM-DD (default the season's first day)")
+	interval := fs.Int("interval", 7, "Days between rounds")
+	kickoff := fs.String("kickoff", "15:00", "Kickoff time, UK time, as HH:MM")
+	blackouts := fs.String("blackout", "", "Dates to skip, as YYYY-MM-DD,...")
+	seed := fs.Int64("seed", 0, "Seed for drawing the schedule (default random)")
+	preview := fs.Bool("preview", false, "Print the fixtures without scheduling them")
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	opts := fixtureOptions{IntervalDays: *interval, KickoffTime: *kickoff}
+	if *firstMatchday != "" {
+		d, err := time.Parse(time.DateOnly, *firstMatchday)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "adv fixtures: -first-matchday must be a date in the format YYYY-MM-DD")
+			return 2
+		}
+		opts.FirstMatchday = data.Date(d)
+	}
+	if *blackouts != "" {
+		for _, s := range strings.Split(*blackouts, ",") {
+			d, err := time.Parse(time.DateOnly, strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "adv fixtures: -blackout must be dates in the format YYYY-MM-DD")
+				return 2
+			}
+			opts.BlackoutDates = append(opts.BlackoutDates, data.Date(d))
+		}
+	}
+	// A seed of 0 is as good as any other, so only an explicit -seed counts.
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			opts.Seed = seed
+		}
+	})
+
+	app, closeDB, err := commandApplication(*dsn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv fixtures: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	season, err := app.models.Seasons.GetByName(*seasonName)
+	if err != nil {
+		if errors.Is(err, data.ErrRecordNotFound) {
+			err = fmt.Errorf("no season named %q", *seasonName)
+		}
+		fmt.Fprintf(os.Stderr, "adv fixtures: %v\n", err)
+		return 1
+	}
+
+	v := validator.New()
+	report, err := app.generateFixtures(season, opts, *preview, v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv fixtures: %v\n", err)
+		return 1
+	}
+	if !v.Valid() {
+		for key, message := range v.Errors {
+			fmt.Fprintf(os.Stderr, "adv fixtures: %s: %s\n", key, message)
+		}
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(envelope{"fixtures": report})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adv fixtures: %v\n", err)
+		return 1
+	}
+
+	if *preview {
+		app.logger.Printf("%d matches in %d rounds previewed for %s, seed %d", report.Total, len(report.Rounds), report.Season, report.Seed)
+	} else {
+		app.logger.Printf("%d matches in %d rounds scheduled for %s, seed %d", report.Total, len(report.Rounds), report.Season, report.Seed)
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"EPLgateway/auth-service/introspect"
+	"context"
+	"net/http"
+)
+
+// Define a custom contextKey type, so that our keys can't collide with keys set by
+// other packages.
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// anonymousUser represents a request that didn't carry an Authorization header. It is
+// an inactive introspection result with no user ID and no permissions.
+var anonymousUser = &introspect.Result{}
+
+// The contextSetUser() method returns a new copy of the request with the provided
+// introspection result added to the context.
+func (app *application) contextSetUser(r *http.Request, user *introspect.Result) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// The contextGetUser() retrieves the introspection result from the request context.
+// The authenticate() middleware always sets one, so a missing value is a bug and we
+// panic.
+func (app *application) contextGetUser(r *http.Request) *introspect.Result {
+	user, ok := r.Context().Value(userContextKey).(*introspect.Result)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/blob"
+	"adv.erakaisar.net/internal/crest"
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"path"
+	"time"
+)
+
+// multipartOverhead is allowed on top of the crest size limit for the boundaries and
+// part headers of a multipart request body.
+const multipartOverhead = 64 << 10
+
+// crestContentTypes maps the extensions of stored crests to their content types.
+var crestContentTypes = map[string]string{
+	".png": "image/png",
+	".jpg": "image/jpeg",
+	".svg": "image/svg+xml",
+}
+
+// readCrestUpload reads the "crest" part of a multipart/form-data request body. Other
+// parts are skipped. Problems with the upload itself are recorded in v; the error is
+// only for bodies that can't be read at all.
+func (app *application) readCrestUpload(w http.ResponseWriter, r *http.Request, v *validator.Validator) ([]byte, error) {
+	maxBytes := app.config.crests.maxBytes
+	// The crest limit is separate from readJSON()'s, and much larger, so the whole
+	// body is capped here as well.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("body must be multipart/form-data")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.Is(err, io.EOF):
+				v.AddError("crest", "must be provided")
+				return nil, nil
+			case errors.As(err, &maxBytesError):
+				v.AddError("crest", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("body contains a badly-formed multipart message: %w", err)
+			}
+		}
+		if part.FormName() != "crest" {
+			continue
+		}
+
+		body, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				v.AddError("crest", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+				return nil, nil
+			default:
+				return nil, fmt.Errorf("body contains a badly-formed multipart message: %w", err)
+			}
+		}
+		v.Check(int64(len(body)) <= maxBytes, "crest", fmt.Sprintf("must not be larger than %d bytes", maxBytes))
+		return body, nil
+	}
+}
+
+// storeCrest writes a crest and its variants to the blob store under key.
+func (app *application) storeCrest(ctx context.Context, key string, body []byte, img *crest.Image) error {
+	err := app.crests.Put(ctx, key, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for size, variant := range img.Variants {
+		err := app.crests.Put(ctx, crest.VariantKey(key, size), bytes.NewReader(variant))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeCrest deletes a crest that is no longer used, and its variants. Failures only
+// leave unused files behind, so they are logged rather than returned.
+func (app *application) removeCrest(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	keys := []string{key}
+	for _, size := range crest.Sizes {
+		if variant := crest.VariantKey(key, size); variant != key {
+			keys = append(keys, variant)
+		}
+	}
+
+	for _, k := range keys {
+		err := app.crests.Delete(ctx, k)
+		if err != nil {
+			app.logger.Printf("removing crest %s: %v", k, err)
+		}
+	}
+}
+
+// putTeamCrestHandler replaces a team's crest with a PNG, JPEG or SVG image uploaded
+// as the "crest" part of a multipart/form-data body. The format is worked out from
+// the content, not from the part's Content-Type.
+func (app *application) putTeamCrestHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !ifMatch(r, teamETag(team)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	body, err := app.readCrestUpload(w, r, v)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	img, err := crest.Process(body)
+	if err != nil {
+		var dimensionError *crest.DimensionError
+		switch {
+		case errors.Is(err, crest.ErrUnsupportedFormat), errors.Is(err, crest.ErrUnsafeSVG), errors.Is(err, crest.ErrSVGTooLarge), errors.As(err, &dimensionError):
+			v.AddError("crest", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	key := img.Key(team.ID, body)
+	current := team.CrestKey()
+
+	err = app.storeCrest(r.Context(), key, body, img)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	previous, err := app.models.Teams.SetCrest(team, key, app.contextGetUser(r).UserID)
+	if err != nil {
+		// Don't remove files the team is still using if the same crest was uploaded
+		// again.
+		if key != current {
+			app.removeCrest(key)
+		}
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if previous != "" && previous != key {
+		app.removeCrest(previous)
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTeamCrestHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if team.CrestKey() == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+	if !ifMatch(r, teamETag(team)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	previous, err := app.models.Teams.SetCrest(team, "", app.contextGetUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if previous != "" {
+		app.removeCrest(previous)
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showCrestHandler serves a stored crest. Crest keys include a hash of their content,
+// so a key never refers to anything else and the response can be cached for good.
+func (app *application) showCrestHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+
+	contentType, ok := crestContentTypes[path.Ext(key)]
+	if !ok || !blob.ValidKey(key) {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rc, err := app.crests.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// SVG crests are checked for scripts on upload; the policy makes sure nothing in
+	// one can run if it is opened directly.
+	if contentType == "image/svg+xml" {
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 // The logError() method is a generic helper for logging an error message. Later in the
@@ -63,6 +64,54 @@ func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
 }
 
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+// The preconditionFailedResponse() method is used when the If-Match header sent by the
+// client doesn't match the current ETag of the resource.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since you last retrieved it"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// The patchFailedResponse() method reports which operation of a JSON Patch document
+// couldn't be applied.
+func (app *application) patchFailedResponse(w http.ResponseWriter, r *http.Request, err *patchError) {
+	message := map[string]any{
+		"operation": err.Index,
+		"op":        err.Op,
+		"path":      err.Path,
+		"message":   err.Err.Error(),
+	}
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+// unsupportedMediaTypeResponse lists the accepted media types in the given header,
+// which is Accept-Patch or Accept-Post depending on the method.
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, header string, accepted ...string) {
+	w.Header().Set(header, strings.Join(accepted, ", "))
+	message := fmt.Sprintf("the request body must be one of %s", strings.Join(accepted, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
 var (
 	ErrRecordNotFound = errors.New("record not found")
 	ErrEditConflict   = errors.New("edit conflict")
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sseHeartbeatInterval is how often a comment line is sent on an otherwise idle event
+// stream, so that proxies don't decide the connection is dead.
+const sseHeartbeatInterval = 15 * time.Second
+
+// readEventCursor returns the ID of the last event the client has seen, taken from
+// the Last-Event-ID header that EventSource sends when it reconnects, or from the
+// after_id query string parameter. It returns -1 if the client didn't send either.
+func (app *application) readEventCursor(r *http.Request) (int64, error) {
+	s := r.Header.Get("Last-Event-ID")
+	if s == "" {
+		s = r.URL.Query().Get("after_id")
+	}
+	if s == "" {
+		return -1, nil
+	}
+
+	cursor, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || cursor < 0 {
+		return 0, errors.New("invalid event cursor")
+	}
+	return cursor, nil
+}
+
+// startEventStream sends the headers for a Server-Sent Events response and returns
+// the controller used to flush each event.
+func (app *application) startEventStream(w http.ResponseWriter) (*http.ResponseController, error) {
+	// The server's WriteTimeout would otherwise cut every stream off, so lift the
+	// deadline for this response only.
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	return rc, nil
+}
+
+// teamEventsHandler streams team create/update/delete events as Server-Sent Events.
+// Every event carries its ID, so a reconnecting EventSource resumes where it left off.
+func (app *application) teamEventsHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, err := app.readEventCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rc, err := app.startEventStream(w)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	events, errs := app.models.TeamEvents.Stream(ctx, cursor)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				select {
+				case err := <-errs:
+					app.logError(r, err)
+				default:
+				}
+				return
+			}
+
+			js, err := json.Marshal(event)
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js)
+			rc.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			rc.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// teamExportFormats are the formats a team export can be downloaded in.
+var teamExportFormats = []string{"csv", "ndjson", "json"}
+
+// exportWriteTimeout is how long an export can take to send each team. The deadline
+// is pushed back as every team is written, so a large export can run for as long as
+// it needs to, but a client that stops reading is cut off instead of keeping the
+// export's database connection busy.
+const exportWriteTimeout = time.Minute
+
+// A teamExporter writes the teams of an export in one format. begin is called before
+// the first team and end after the last, even if there are none.
+type teamExporter interface {
+	begin() error
+	write(team *data.TeamExport) error
+	end() error
+}
+
+// newTeamExporter returns the exporter for format, with the content type to serve it
+// as.
+func newTeamExporter(format string, w io.Writer, counts []string) (teamExporter, string) {
+	switch format {
+	case "csv":
+		return &csvTeamExporter{w: csv.NewWriter(w), counts: counts}, "text/csv; charset=utf-8"
+	case "ndjson":
+		return &jsonTeamExporter{w: w, enc: json.NewEncoder(w)}, ndjsonMediaType
+	default:
+		return &jsonTeamExporter{w: w, enc: json.NewEncoder(w), array: true}, "application/json"
+	}
+}
+
+// csvTeamExporter writes a header row followed by one row per team. Only the counts
+// that were asked for get a column.
+type csvTeamExporter struct {
+	w      *csv.Writer
+	counts []string
+}
+
+func (e *csvTeamExporter) begin() error {
+	header := []string{"id", "name", "location", "stadium_id", "stadium", "crest_url", "history", "version"}
+	return e.w.Write(append(header, e.counts...))
+}
+
+func (e *csvTeamExporter) write(team *data.TeamExport) error {
+	record := []string{
+		strconv.FormatInt(team.ID, 10),
+		spreadsheetSafe(team.Name),
+		spreadsheetSafe(team.Location),
+		strconv.FormatInt(team.StadiumID, 10),
+		spreadsheetSafe(team.Stadium),
+		team.CrestURL,
+		spreadsheetSafe(team.History),
+		strconv.FormatInt(int64(team.Version), 10),
+	}
+	for _, count := range e.counts {
+		n := team.Players
+		if count == "matches" {
+			n = team.Matches
+		}
+		record = append(record, strconv.Itoa(*n))
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvTeamExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// spreadsheetSafe stops a free-text value from being run as a formula when the CSV is
+// opened in a spreadsheet, by prefixing values that start like one with a quote.
+func spreadsheetSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// jsonTeamExporter writes one team per line, either on its own (NDJSON) or as the
+// elements of a "teams" array, like the body of GET /v1/teams.
+type jsonTeamExporter struct {
+	w     io.Writer
+	enc   *json.Encoder
+	array bool
+	n     int
+}
+
+func (e *jsonTeamExporter) begin() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "{\"teams\": [\n")
+	return err
+}
+
+func (e *jsonTeamExporter) write(team *data.TeamExport) error {
+	if e.array && e.n > 0 {
+		_, err := io.WriteString(e.w, ",")
+		if err != nil {
+			return err
+		}
+	}
+	e.n++
+	return e.enc.Encode(team)
+}
+
+func (e *jsonTeamExporter) end() error {
+	if !e.array {
+		return nil
+	}
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// exportTeamsHandler downloads every team matching the filters of GET /v1/teams, in
+// CSV, NDJSON or JSON, as the rows come back from the database. include=players,matches
+// adds those counts to each team; the match counts need the matches:read permission.
+func (app *application) exportTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string
+		Location string
+		Stadium  string
+		Format   string
+		Include  []string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.Location = app.readString(qs, "location", "")
+	input.Stadium = app.readString(qs, "stadium", "")
+	input.Format = app.readString(qs, "format", "csv")
+	input.Include = app.readCSV(qs, "include", []string{})
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "location", "stadium", "-id", "-name", "-location", "-stadium"}
+
+	// Exports aren't paged, so only the sort is checked.
+	v.Check(validator.PermittedValue(input.Filters.Sort, input.Filters.SortSafelist...), "sort", "invalid sort value")
+	v.Check(validator.PermittedValue(input.Format, teamExportFormats...), "format", "must be one of csv, ndjson or json")
+	for _, count := range input.Include {
+		v.Check(validator.PermittedValue(count, data.TeamExportCounts...), "include", "must be a comma-separated list of players and matches")
+	}
+	v.Check(validator.Unique(input.Include), "include", "must not contain duplicate values")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// The match counts are only for users who can see the matches.
+	if slices.Contains(input.Include, "matches") && app.introspector != nil {
+		user := app.contextGetUser(r)
+		switch {
+		case !user.Active:
+			app.authenticationRequiredResponse(w, r)
+			return
+		case !user.HasPermission("matches:read"):
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+	// Keep the CSV columns in a fixed order, whatever order they were asked for in.
+	slices.SortFunc(input.Include, func(a, b string) int {
+		return slices.Index(data.TeamExportCounts, a) - slices.Index(data.TeamExportCounts, b)
+	})
+
+	exporter, contentType := newTeamExporter(input.Format, w, input.Include)
+	rc := http.NewResponseController(w)
+	extendDeadline := func() error {
+		return rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	}
+
+	// The headers are only sent with the first row, so that a query that fails
+	// straight away still gets a proper error response.
+	started := false
+	start := func() error {
+		// A large export can take longer than the server's WriteTimeout, which would
+		// otherwise cut the download off, so it is replaced by exportWriteTimeout.
+		err := extendDeadline()
+		if err != nil {
+			return err
+		}
+
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="teams.%s"`, input.Format))
+		return exporter.begin()
+	}
+
+	err := app.models.Teams.Export(r.Context(), input.Name, input.Location, input.Stadium, input.Filters, input.Include, func(team *data.TeamExport) error {
+		if !started {
+			err := start()
+			if err != nil {
+				return err
+			}
+		} else {
+			err := extendDeadline()
+			if err != nil {
+				return err
+			}
+		}
+		return exporter.write(team)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = exporter.end()
+	}
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		// It's too late to send an error response. Abort the response instead, so
+		// that the client sees a failed download rather than a file that looks
+		// complete but isn't.
+		app.logError(r, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"EPLgateway/auth-service/introspect"
+	"adv.erakaisar.net/internal/data"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func exportTeams(t *testing.T, format string, counts []string) string {
+	t.Helper()
+
+	players, matches := 25, 38
+	teams := []*data.TeamExport{
+		{Team: data.Team{ID: 1, Name: "Arsenal", Location: "London", StadiumID: 1, Stadium: "Emirates Stadium", History: "Founded 1886.", Version: 1}, Players: &players, Matches: &matches},
+		{Team: data.Team{ID: 2, Name: "=HYPERLINK(\"x\")", Location: "Liverpool", StadiumID: 2, Stadium: "Goodison Park", History: "Founded 1878.", Version: 3}, Players: &players, Matches: &matches},
+	}
+
+	var buf bytes.Buffer
+	exporter, _ := newTeamExporter(format, &buf, counts)
+	if err := exporter.begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, team := range teams {
+		if err := exporter.write(team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exporter.end(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCSVTeamExporter(t *testing.T) {
+	got := exportTeams(t, "csv", []string{"matches"})
+	want := "id,name,location,stadium_id,stadium,crest_url,history,version,matches\n" +
+		"1,Arsenal,London,1,Emirates Stadium,,Founded 1886.,1,38\n" +
+		"2,\"'=HYPERLINK(\"\"x\"\")\",Liverpool,2,Goodison Park,,Founded 1878.,3,38\n"
+
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestJSONTeamExporter(t *testing.T) {
+	var body struct {
+		Teams []data.TeamExport `json:"teams"`
+	}
+	err := json.Unmarshal([]byte(exportTeams(t, "json", nil)), &body)
+	if err != nil {
+		t.Fatalf("export is not valid JSON: %s", err)
+	}
+	if len(body.Teams) != 2 || *body.Teams[1].Players != 25 {
+		t.Errorf("unexpected teams: %+v", body.Teams)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace([]byte(exportTeams(t, "ndjson", nil))), []byte("\n"))
+	if len(lines) != 2 {
+		t.Errorf("expected one line per team, got %d", len(lines))
+	}
+}
+
+func TestExportTeamsMatchCountsPermission(t *testing.T) {
+	app := &application{
+		logger:       log.New(io.Discard, "", 0),
+		introspector: introspect.New("http://127.0.0.1:0", "adv", "secret", time.Minute),
+	}
+
+	tests := []struct {
+		name string
+		user *introspect.Result
+		code int
+	}{
+		{"anonymous", anonymousUser, http.StatusUnauthorized},
+		{"missing permission", &introspect.Result{Active: true, UserID: 7, Permissions: []string{"teams:write"}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/teams/export?include=players,matches", nil)
+			r = app.contextSetUser(r, tt.user)
+			w := httptest.NewRecorder()
+
+			app.exportTeamsHandler(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("got status %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
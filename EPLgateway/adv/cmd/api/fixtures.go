@@ -0,0 +1,229 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/fixtures"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// fixtureOptions are the settings for drawing up a season's fixtures. A zero
+// FirstMatchday means the season's first day, and a nil Seed a random one.
+type fixtureOptions struct {
+	FirstMatchday data.Date
+	IntervalDays  int
+	KickoffTime   string
+	BlackoutDates []data.Date
+	Seed          *int64
+}
+
+// A fixtureRound is one round of a season's fixtures, all played on the same day.
+type fixtureRound struct {
+	Round   int           `json:"round"`
+	Date    data.Date     `json:"date"`
+	Matches []*data.Match `json:"matches"`
+}
+
+// A fixtureReport is the outcome of generating a season's fixtures. Seed is the one
+// the schedule was drawn with, so that a preview can be generated again for real.
+type fixtureReport struct {
+	Season  string          `json:"season"`
+	Preview bool            `json:"preview"`
+	Seed    int64           `json:"seed"`
+	Total   int             `json:"total"`
+	Rounds  []*fixtureRound `json:"rounds"`
+}
+
+// generateFixtures draws up a double round-robin for an open season and, unless
+// preview is set, schedules its matches. Every team is at home at its own stadium.
+// Problems with the options or the season are recorded in v, in which case the report
+// is nil; the error is only for unexpected failures.
+func (app *application) generateFixtures(season *data.Season, opts fixtureOptions, preview bool, v *validator.Validator) (*fixtureReport, error) {
+	if time.Time(opts.FirstMatchday).IsZero() {
+		opts.FirstMatchday = season.StartsOn
+	}
+	first, startsOn, endsOn := time.Time(opts.FirstMatchday), time.Time(season.StartsOn), time.Time(season.EndsOn)
+
+	kickoff, err := time.Parse("15:04", opts.KickoffTime)
+	if err != nil {
+		v.AddError("kickoff_time", "must be a time in the format HH:MM")
+	}
+	v.Check(opts.IntervalDays >= 1, "interval_days", "must be at least 1")
+	v.Check(opts.IntervalDays <= 28, "interval_days", "must not be more than 28")
+	v.Check(!first.Before(startsOn) && !first.After(endsOn), "first_matchday", "must be within the season")
+	v.Check(season.Status == data.SeasonOpen, "status", "season is closed")
+	if !v.Valid() {
+		return nil, nil
+	}
+
+	members, err := app.models.Seasons.GetFixtureTeams(season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make([]fixtures.Team, 0, len(members))
+	byID := make(map[int64]*data.FixtureTeam, len(members))
+	for _, t := range members {
+		teams = append(teams, fixtures.Team{ID: t.TeamID, StadiumID: t.StadiumID})
+		byID[t.TeamID] = t
+	}
+
+	seed := time.Now().UnixNano()
+	if opts.Seed != nil {
+		seed = *opts.Seed
+	}
+
+	rounds, err := fixtures.Generate(teams, seed)
+	if err != nil {
+		var conflict *fixtures.StadiumConflictError
+		switch {
+		case errors.Is(err, fixtures.ErrTooFewTeams):
+			v.AddError("teams", "season must have at least two teams")
+		case errors.As(err, &conflict):
+			v.AddError("teams", fmt.Sprintf("teams %v share a stadium and can't all be given home matches", conflict.TeamIDs))
+		default:
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	blackouts := make([]time.Time, len(opts.BlackoutDates))
+	for i, d := range opts.BlackoutDates {
+		blackouts[i] = time.Time(d)
+	}
+	days, err := fixtures.Matchdays(first, opts.IntervalDays, blackouts, len(rounds), endsOn)
+	if err != nil {
+		switch {
+		case errors.Is(err, fixtures.ErrNotEnoughMatchdays):
+			v.AddError("interval_days", fmt.Sprintf("leaves too few matchdays before the season ends for %d rounds", len(rounds)))
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	report := &fixtureReport{Season: season.Name, Preview: preview, Seed: seed, Rounds: []*fixtureRound{}}
+	var matches []*data.Match
+	for i, round := range rounds {
+		day := days[i]
+		fr := &fixtureRound{Round: i + 1, Date: data.Date(day), Matches: []*data.Match{}}
+
+		for _, f := range round {
+			match := &data.Match{
+				HomeTeamID: f.HomeTeamID,
+				AwayTeamID: f.AwayTeamID,
+				Kickoff:    time.Date(day.Year(), day.Month(), day.Day(), kickoff.Hour(), kickoff.Minute(), 0, 0, data.MatchdayLocation),
+				Venue:      byID[f.HomeTeamID].Stadium,
+				Status:     data.MatchScheduled,
+			}
+			fr.Matches = append(fr.Matches, match)
+			matches = append(matches, match)
+		}
+
+		report.Rounds = append(report.Rounds, fr)
+	}
+	report.Total = len(matches)
+
+	if preview {
+		return report, nil
+	}
+
+	err = app.models.Matches.InsertFixtures(season.ID, matches)
+	if err != nil {
+		var doubleBooking *data.DoubleBookingError
+		switch {
+		case errors.Is(err, data.ErrSeasonClosed):
+			v.AddError("status", "season is closed")
+		case errors.Is(err, data.ErrSeasonHasFixtures):
+			v.AddError("status", "season already has fixtures")
+		case errors.As(err, &doubleBooking):
+			v.AddError("blackout_dates", fmt.Sprintf("must include the day of match %d, which team %d is already playing in", doubleBooking.MatchID, doubleBooking.TeamID))
+		default:
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return report, nil
+}
+
+// generateFixturesHandler draws up a season's fixtures as a double round-robin, with
+// a round every interval_days from first_matchday, skipping blackout_dates. With
+// preview=true the fixtures are only returned; otherwise they are scheduled, and a
+// season can only have its fixtures generated once.
+func (app *application) generateFixturesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		FirstMatchday data.Date   `json:"first_matchday"`
+		IntervalDays  *int        `json:"interval_days"`
+		KickoffTime   string      `json:"kickoff_time"`
+		BlackoutDates []data.Date `json:"blackout_dates"`
+		Seed          *int64      `json:"seed"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	preview := app.readBool(r.URL.Query(), "preview", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	season, err := app.models.Seasons.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	opts := fixtureOptions{
+		FirstMatchday: input.FirstMatchday,
+		IntervalDays:  7,
+		KickoffTime:   input.KickoffTime,
+		BlackoutDates: input.BlackoutDates,
+		Seed:          input.Seed,
+	}
+	if input.IntervalDays != nil {
+		opts.IntervalDays = *input.IntervalDays
+	}
+	if opts.KickoffTime == "" {
+		opts.KickoffTime = "15:00"
+	}
+
+	report, err := app.generateFixtures(season, opts, preview, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	status := http.StatusCreated
+	if preview {
+		status = http.StatusOK
+	}
+
+	err = app.writeJSON(w, status, envelope{"fixtures": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
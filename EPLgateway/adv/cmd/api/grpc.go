@@ -0,0 +1,327 @@
+package main
+
+import (
+	"EPLgateway/auth-service/introspect"
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"adv.erakaisar.net/proto/teamspb"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPermissions lists the RPCs that need a permission, mirroring the
+// requirePermission() calls in routes().
+var grpcPermissions = map[string]string{
+	teamspb.Teams_Create_FullMethodName: "teams:write",
+	teamspb.Teams_Update_FullMethodName: "teams:write",
+	teamspb.Teams_Delete_FullMethodName: "teams:write",
+}
+
+// teamsServer implements the Teams gRPC service on top of the same models as the
+// HTTP handlers.
+type teamsServer struct {
+	teamspb.UnimplementedTeamsServer
+	app *application
+}
+
+func (app *application) grpcServer() *grpc.Server {
+	srv := grpc.NewServer(grpc.UnaryInterceptor(app.grpcAuthenticate))
+	teamspb.RegisterTeamsServer(srv, &teamsServer{app: app})
+	return srv
+}
+
+// grpcAuthenticate is the gRPC counterpart of authenticate() and requirePermission().
+// The bearer token travels in the "authorization" metadata key.
+func (app *application) grpcAuthenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	permission, protected := grpcPermissions[info.FullMethod]
+	if !protected || app.introspector == nil {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "you must be authenticated to access this resource")
+	}
+
+	headerParts := strings.Split(values[0], " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+	}
+
+	user, err := app.introspector.Introspect(ctx, headerParts[1])
+	if err != nil {
+		app.logger.Print(err)
+		return nil, status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+	}
+	if !user.Active {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+	}
+	if !user.HasPermission(permission) {
+		return nil, status.Error(codes.PermissionDenied, "your user account doesn't have the necessary permissions to access this resource")
+	}
+
+	return handler(context.WithValue(ctx, userContextKey, user), req)
+}
+
+// grpcActor returns the ID of the user grpcAuthenticate() authenticated, or 0 for
+// RPCs that don't require authentication.
+func grpcActor(ctx context.Context) int64 {
+	user, ok := ctx.Value(userContextKey).(*introspect.Result)
+	if !ok {
+		return 0
+	}
+	return user.UserID
+}
+
+func (s *teamsServer) Create(ctx context.Context, req *teamspb.TeamRequest) (*teamspb.TeamResponse, error) {
+	team := &data.Team{
+		Name:     req.GetName(),
+		Location: req.GetLocation(),
+		History:  req.GetHistory(),
+	}
+
+	v := validator.New()
+	err := s.validateTeam(v, team, req.GetStadium())
+	if err != nil {
+		return nil, s.serverErrorStatus(err)
+	}
+	if !v.Valid() {
+		return nil, failedValidationStatus(v.Errors)
+	}
+
+	err = s.app.models.Teams.Insert(team, grpcActor(ctx))
+	if err != nil {
+		return nil, s.modelErrorStatus(err)
+	}
+
+	return &teamspb.TeamResponse{Message: "team successfully created", Team: teamToProto(team)}, nil
+}
+
+// validateTeam validates a team sent over gRPC. The protocol names the team's stadium
+// rather than giving its ID, so the stadium is looked up by name and any problem with
+// it is reported against the stadium field.
+func (s *teamsServer) validateTeam(v *validator.Validator, team *data.Team, stadiumName string) error {
+	team.StadiumID = 0
+
+	if stadiumName == "" {
+		v.AddError("stadium", "must be provided")
+	} else {
+		stadium, err := s.app.models.Stadiums.GetByName(stadiumName)
+		switch {
+		case err == nil:
+			team.StadiumID = stadium.ID
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("stadium", "must be the name of an existing stadium")
+		default:
+			return err
+		}
+	}
+
+	data.ValidateTeam(v, team)
+	delete(v.Errors, "stadium_id")
+	return nil
+}
+
+func (s *teamsServer) Show(ctx context.Context, req *teamspb.TeamIDRequest) (*teamspb.TeamResponse, error) {
+	team, err := s.app.models.Teams.Get(req.GetId())
+	if err != nil {
+		return nil, s.modelErrorStatus(err)
+	}
+
+	return &teamspb.TeamResponse{Team: teamToProto(team)}, nil
+}
+
+func (s *teamsServer) Update(ctx context.Context, req *teamspb.TeamUpdateRequest) (*teamspb.TeamResponse, error) {
+	team, err := s.app.models.Teams.Get(req.GetId())
+	if err != nil {
+		return nil, s.modelErrorStatus(err)
+	}
+
+	if req.Version != nil && req.GetVersion() != team.Version {
+		return nil, s.modelErrorStatus(data.ErrEditConflict)
+	}
+
+	team.Name = req.GetName()
+	team.Location = req.GetLocation()
+	team.History = req.GetHistory()
+
+	v := validator.New()
+	err = s.validateTeam(v, team, req.GetStadium())
+	if err != nil {
+		return nil, s.serverErrorStatus(err)
+	}
+	if !v.Valid() {
+		return nil, failedValidationStatus(v.Errors)
+	}
+
+	err = s.app.models.Teams.Update(team, grpcActor(ctx))
+	if err != nil {
+		return nil, s.modelErrorStatus(err)
+	}
+
+	return &teamspb.TeamResponse{Message: "team successfully updated", Team: teamToProto(team)}, nil
+}
+
+func (s *teamsServer) Delete(ctx context.Context, req *teamspb.TeamIDRequest) (*teamspb.DeleteResponse, error) {
+	err := s.app.models.Teams.Delete(req.GetId(), grpcActor(ctx))
+	if err != nil {
+		return nil, s.modelErrorStatus(err)
+	}
+
+	return &teamspb.DeleteResponse{Message: "team successfully deleted"}, nil
+}
+
+func (s *teamsServer) List(ctx context.Context, req *teamspb.ListTeamsRequest) (*teamspb.ListTeamsResponse, error) {
+	filters := data.Filters{
+		Page:         int(req.GetPage()),
+		PageSize:     int(req.GetPageSize()),
+		Sort:         "id",
+		SortSafelist: []string{"id"},
+	}
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+
+	v := validator.New()
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		return nil, failedValidationStatus(v.Errors)
+	}
+
+	teams, metadata, err := s.app.models.Teams.GetAll("", "", "", filters)
+	if err != nil {
+		return nil, s.serverErrorStatus(err)
+	}
+
+	resp := &teamspb.ListTeamsResponse{
+		Teams: make([]*teamspb.Team, 0, len(teams)),
+		Metadata: &teamspb.Metadata{
+			CurrentPage:  int32(metadata.CurrentPage),
+			PageSize:     int32(metadata.PageSize),
+			FirstPage:    int32(metadata.FirstPage),
+			LastPage:     int32(metadata.LastPage),
+			TotalRecords: int32(metadata.TotalRecords),
+		},
+	}
+	for _, team := range teams {
+		resp.Teams = append(resp.Teams, teamToProto(team))
+	}
+
+	return resp, nil
+}
+
+// WatchTeams streams team events until the client goes away. Like the SSE feed, it
+// starts at the current position unless the client asks to resume after an event.
+func (s *teamsServer) WatchTeams(req *teamspb.WatchTeamsRequest, stream teamspb.Teams_WatchTeamsServer) error {
+	cursor := req.GetAfterId()
+	if cursor < 0 {
+		return status.Error(codes.InvalidArgument, "after_id must not be negative")
+	}
+	if req.AfterId == nil {
+		cursor = -1
+	}
+
+	events, errs := s.app.models.TeamEvents.Stream(stream.Context(), cursor)
+
+	for event := range events {
+		err := stream.Send(eventToProto(event))
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case err := <-errs:
+		return s.serverErrorStatus(err)
+	default:
+		return status.FromContextError(stream.Context().Err()).Err()
+	}
+}
+
+var teamEventTypes = map[string]teamspb.TeamEventType{
+	data.TeamCreated:  teamspb.TeamEventType_TEAM_EVENT_TYPE_CREATED,
+	data.TeamUpdated:  teamspb.TeamEventType_TEAM_EVENT_TYPE_UPDATED,
+	data.TeamDeleted:  teamspb.TeamEventType_TEAM_EVENT_TYPE_DELETED,
+	data.TeamRestored: teamspb.TeamEventType_TEAM_EVENT_TYPE_RESTORED,
+}
+
+func eventToProto(event *data.TeamEvent) *teamspb.TeamEvent {
+	return &teamspb.TeamEvent{
+		Id:        event.ID,
+		Type:      teamEventTypes[event.Type],
+		TeamId:    event.TeamID,
+		Team:      teamToProto(event.Team),
+		CreatedAt: timestamppb.New(event.CreatedAt),
+	}
+}
+
+func teamToProto(team *data.Team) *teamspb.Team {
+	pb := &teamspb.Team{
+		Id:        team.ID,
+		Name:      team.Name,
+		Location:  team.Location,
+		Stadium:   team.Stadium,
+		History:   team.History,
+		CreatedAt: timestamppb.New(team.CreatedAt),
+		Version:   team.Version,
+	}
+	if !team.UpdatedAt.IsZero() {
+		pb.UpdatedAt = timestamppb.New(team.UpdatedAt)
+	}
+	return pb
+}
+
+// failedValidationStatus is the gRPC equivalent of failedValidationResponse(): an
+// InvalidArgument status carrying one field violation per validator error.
+func failedValidationStatus(errs map[string]string) error {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	br := &errdetails.BadRequest{}
+	for _, field := range fields {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: errs[field],
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, "the request failed validation").WithDetails(br)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "the request failed validation")
+	}
+	return st.Err()
+}
+
+// modelErrorStatus maps the errors returned by the data models to gRPC statuses.
+func (s *teamsServer) modelErrorStatus(err error) error {
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "the requested resource could not be found")
+	case errors.Is(err, data.ErrEditConflict):
+		return status.Error(codes.Aborted, "unable to update the record due to an edit conflict, please try again")
+	case errors.Is(err, data.ErrUnknownStadium):
+		return failedValidationStatus(map[string]string{"stadium": "must be the name of an existing stadium"})
+	default:
+		return s.serverErrorStatus(err)
+	}
+}
+
+func (s *teamsServer) serverErrorStatus(err error) error {
+	s.app.logger.Print(err)
+	return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"EPLgateway/auth-service/introspect"
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/proto/teamspb"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestModelErrorStatus(t *testing.T) {
+	s := &teamsServer{app: &application{logger: log.New(io.Discard, "", 0)}}
+
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"not found", data.ErrRecordNotFound, codes.NotFound},
+		{"wrapped not found", fmt.Errorf("get team: %w", data.ErrRecordNotFound), codes.NotFound},
+		{"edit conflict", data.ErrEditConflict, codes.Aborted},
+		{"unknown stadium", data.ErrUnknownStadium, codes.InvalidArgument},
+		{"anything else", errors.New("connection reset"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(s.modelErrorStatus(tt.err)); got != tt.code {
+				t.Errorf("got %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestFailedValidationStatus(t *testing.T) {
+	st := status.Convert(failedValidationStatus(map[string]string{
+		"name":    "must be provided",
+		"stadium": "must be the name of an existing stadium",
+	}))
+
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("got %s, want InvalidArgument", st.Code())
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("expected one detail, got %v", st.Details())
+	}
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok {
+		t.Fatalf("expected a BadRequest detail, got %T", st.Details()[0])
+	}
+
+	// Violations are sorted by field, so clients see them in a stable order.
+	violations := br.GetFieldViolations()
+	if len(violations) != 2 || violations[0].GetField() != "name" || violations[1].GetField() != "stadium" {
+		t.Errorf("unexpected field violations %v", violations)
+	}
+}
+
+func TestGRPCAuthenticate(t *testing.T) {
+	// The introspection endpoint knows two tokens: one for a user who may write
+	// teams and one for a user who may not. Anything else is inactive.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		exp := time.Now().Add(time.Hour).Unix()
+		switch r.PostForm.Get("token") {
+		case "writer":
+			fmt.Fprintf(w, `{"active": true, "user_id": 7, "exp": %d, "permissions": ["teams:write"]}`, exp)
+		case "reader":
+			fmt.Fprintf(w, `{"active": true, "user_id": 8, "exp": %d, "permissions": ["matches:read"]}`, exp)
+		default:
+			fmt.Fprint(w, `{"active": false}`)
+		}
+	}))
+	defer srv.Close()
+
+	app := &application{
+		logger:       log.New(io.Discard, "", 0),
+		introspector: introspect.New(srv.URL, "adv", "secret", time.Minute),
+	}
+
+	var actor int64
+	handler := func(ctx context.Context, req any) (any, error) {
+		actor = grpcActor(ctx)
+		return "ok", nil
+	}
+	create := &grpc.UnaryServerInfo{FullMethod: teamspb.Teams_Create_FullMethodName}
+	show := &grpc.UnaryServerInfo{FullMethod: teamspb.Teams_Show_FullMethodName}
+
+	withToken := func(header string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", header))
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		info  *grpc.UnaryServerInfo
+		code  codes.Code
+		actor int64
+	}{
+		{"unprotected", context.Background(), show, codes.OK, 0},
+		{"no token", context.Background(), create, codes.Unauthenticated, 0},
+		{"malformed header", withToken("writer"), create, codes.Unauthenticated, 0},
+		{"inactive token", withToken("Bearer expired"), create, codes.Unauthenticated, 0},
+		{"missing permission", withToken("Bearer reader"), create, codes.PermissionDenied, 0},
+		{"permitted", withToken("Bearer writer"), create, codes.OK, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor = 0
+			_, err := app.grpcAuthenticate(tt.ctx, nil, tt.info, handler)
+			if got := status.Code(err); got != tt.code {
+				t.Fatalf("got %s, want %s", got, tt.code)
+			}
+			if actor != tt.actor {
+				t.Errorf("got actor %d, want %d", actor, tt.actor)
+			}
+		})
+	}
+
+	// Without introspection, authentication is disabled and every RPC goes through.
+	app.introspector = nil
+	_, err := app.grpcAuthenticate(context.Background(), nil, create, handler)
+	if err != nil {
+		t.Errorf("expected no error with authentication disabled, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// headToHeadHandler compares two teams: their record in every finished meeting, the
+// aggregate score, each side's biggest win and the five most recent meetings. Next to
+// that it shows both teams' rows of the league table for a season, the current one
+// unless season is given.
+func (app *application) headToHeadHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	otherID, err := app.readOtherParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	v.Check(otherID != id, "other", "must be a different team")
+	season, seasonID, from, to, err := app.resolveSeason(app.readString(qs, "season", data.SeasonOf(time.Now()).String()))
+	switch {
+	case errors.Is(err, data.ErrInvalidSeason):
+		v.AddError("season", "must be a season in the format YYYY-YY, such as 2024-25")
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var teams [2]*data.Team
+	for i, teamID := range []int64{id, otherID} {
+		teams[i], err = app.models.Teams.Get(teamID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	meetings, err := app.models.Matches.GetMeetings(id, otherID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	results, err := app.models.Matches.GetResults(seasonID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	h2h := data.ComputeHeadToHead(teams[0], teams[1], meetings)
+	h2h.SetSeason(data.ComputeStandings(results))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season, "head_to_head": h2h}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
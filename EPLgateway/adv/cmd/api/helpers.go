@@ -1,16 +1,19 @@
 package main
 
 import (
+	"adv.erakaisar.net/internal/data"
 	"adv.erakaisar.net/internal/validator"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Retrieve the "id" URL parameter from the current request context, then convert it to
@@ -24,8 +27,52 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readRevisionParam reads the "rev" URL parameter in the same way as readIDParam().
+func (app *application) readRevisionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	rev, err := strconv.ParseInt(params.ByName("rev"), 10, 32)
+	if err != nil || rev < 1 {
+		return 0, errors.New("invalid rev parameter")
+	}
+	return int32(rev), nil
+}
+
+// readOtherParam reads the "other" URL parameter, the second team of a comparison, in
+// the same way as readIDParam().
+func (app *application) readOtherParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("other"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid other parameter")
+	}
+	return id, nil
+}
+
 type envelope map[string]any
 
+// teamETag returns the entity tag for a team. The version number changes on every
+// update, so it is all we need to tell two representations apart.
+func teamETag(team *data.Team) string {
+	return fmt.Sprintf(`"%d"`, team.Version)
+}
+
+// ifMatch reports whether the request's If-Match header allows a write to a resource
+// with the given ETag. A missing header always matches; weak tags never do, because
+// If-Match uses strong comparison.
+func ifMatch(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // Change the data parameter to have the type envelope instead of any.
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
@@ -155,3 +202,56 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	// Otherwise, return the converted integer value.
 	return i
 }
+
+// The readDate() helper reads a "YYYY-MM-DD" date from the query string and returns
+// the start of that day in the given location. If no matching key could be found it
+// returns the zero time. If the value couldn't be parsed, then we record an error
+// message in the provided Validator instance.
+func (app *application) readDate(qs url.Values, key string, loc *time.Location, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		v.AddError(key, "must be a date in the format YYYY-MM-DD")
+		return time.Time{}
+	}
+	return t
+}
+
+// The readFloat() helper reads a decimal number from the query string. It returns nil
+// if no matching key could be found, so that callers can tell a missing value from
+// zero. If the value couldn't be parsed, then we record an error message in the
+// provided Validator instance.
+func (app *application) readFloat(qs url.Values, key string, v *validator.Validator) *float64 {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		v.AddError(key, "must be a number")
+		return nil
+	}
+	return &f
+}
+
+// The readBool() helper reads a true/false value from the query string. If no matching
+// key could be found it returns the provided default value. If the value couldn't be
+// parsed, then we record an error message in the provided Validator instance.
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+	return b
+}
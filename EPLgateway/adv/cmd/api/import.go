@@ -0,0 +1,384 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	csvMediaType    = "text/csv"
+	ndjsonMediaType = "application/x-ndjson"
+)
+
+// maxImportBytes is the largest body accepted by the import endpoint. Imports are
+// much larger than the other request bodies, so readJSON()'s limit doesn't apply.
+const maxImportBytes = 10 << 20
+
+// teamImportFields are the columns of a CSV import and the keys of an NDJSON one. A
+// team's stadium can be given by ID or, more conveniently when loading a league, by
+// name.
+var teamImportFields = []string{"name", "location", "stadium_id", "stadium", "history"}
+
+// The status of each row in an import report. Valid rows are ones that could have
+// been imported, but weren't because of a dry run or a failure elsewhere in an
+// atomic import.
+const (
+	importRowImported = "imported"
+	importRowValid    = "valid"
+	importRowFailed   = "failed"
+)
+
+type teamImportInput struct {
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	StadiumID int64  `json:"stadium_id"`
+	Stadium   string `json:"stadium"`
+	History   string `json:"history"`
+}
+
+// A teamImportRow is one team read from an import, numbered by the line it starts on.
+type teamImportRow struct {
+	Row    int               `json:"row"`
+	Status string            `json:"status"`
+	TeamID int64             `json:"team_id,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	input  teamImportInput
+	v      *validator.Validator
+}
+
+type teamImportReport struct {
+	Atomic    bool             `json:"atomic"`
+	DryRun    bool             `json:"dry_run"`
+	Committed bool             `json:"committed"`
+	Total     int              `json:"total"`
+	Imported  int              `json:"imported"`
+	Failed    int              `json:"failed"`
+	Rows      []*teamImportRow `json:"rows"`
+}
+
+// parseColumnMap parses a CSV header mapping such as "Club=name,Ground=stadium",
+// which lets files whose headers don't use the team field names be imported as they
+// are. Column names are matched case-insensitively.
+func parseColumnMap(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if s == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		column, field, ok := strings.Cut(pair, "=")
+		column = strings.ToLower(strings.TrimSpace(column))
+		field = strings.TrimSpace(field)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("%q must have the form column=field", pair)
+		}
+		if !slices.Contains(teamImportFields, field) {
+			return nil, fmt.Errorf("%q is not a team field", field)
+		}
+		columns[column] = field
+	}
+
+	return columns, nil
+}
+
+// readTeamImportCSV reads teams from CSV with a header row. Each header is either a
+// team field or a column mapped to one by columns.
+func readTeamImportCSV(r io.Reader, columns map[string]string) ([]*teamImportRow, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, errors.New("body must not be empty")
+		default:
+			return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+		}
+	}
+
+	fields := make([]string, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		field := strings.ToLower(strings.TrimSpace(column))
+		if mapped, ok := columns[field]; ok {
+			field = mapped
+		}
+		if !slices.Contains(teamImportFields, field) {
+			return nil, fmt.Errorf("column %q is not a team field; map it to one with column=field", column)
+		}
+		if slices.Contains(fields[:i], field) {
+			return nil, fmt.Errorf("more than one column is mapped to %s", field)
+		}
+		fields[i] = field
+	}
+
+	rows := []*teamImportRow{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+		}
+		if len(rows) == data.MaxTeamImport {
+			return nil, fmt.Errorf("body must not contain more than %d teams", data.MaxTeamImport)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := &teamImportRow{Row: line, v: validator.New()}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch fields[i] {
+			case "name":
+				row.input.Name = value
+			case "location":
+				row.input.Location = value
+			case "stadium":
+				row.input.Stadium = value
+			case "history":
+				row.input.History = value
+			case "stadium_id":
+				if value == "" {
+					continue
+				}
+				id, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					row.v.AddError("stadium_id", "must be an integer value")
+					continue
+				}
+				row.input.StadiumID = id
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readTeamImportNDJSON reads teams from newline-delimited JSON, one object per line.
+// Blank lines are skipped. A line that isn't a valid team object is reported as a
+// failed row rather than failing the whole import.
+func readTeamImportNDJSON(r io.Reader) ([]*teamImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	rows := []*teamImportRow{}
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) == data.MaxTeamImport {
+			return nil, fmt.Errorf("body must not contain more than %d teams", data.MaxTeamImport)
+		}
+
+		row := &teamImportRow{Row: line, v: validator.New()}
+		rows = append(rows, row)
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&row.input)
+		if err == nil && dec.More() {
+			err = errors.New("trailing data")
+		}
+		if err != nil {
+			var unmarshalTypeError *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+				row.v.AddError(unmarshalTypeError.Field, fmt.Sprintf("must not be a JSON %s", unmarshalTypeError.Value))
+			case strings.HasPrefix(err.Error(), "json: unknown field "):
+				field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+				row.v.AddError(field, "is not a team field")
+			default:
+				row.v.AddError("json", "must be a single JSON object")
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		switch {
+		case errors.Is(err, bufio.ErrTooLong):
+			return nil, fmt.Errorf("body must not contain lines longer than %d bytes", 1<<20)
+		default:
+			return nil, err
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("body must not be empty")
+	}
+
+	return rows, nil
+}
+
+// importTeams validates the rows read from an import and writes the valid ones. Rows
+// that fail validation never reach the database. In an atomic import any failed row
+// turns the import into a dry run, so that the report still covers every row.
+func (app *application) importTeams(rows []*teamImportRow, atomic, dryRun bool, actorID int64) (*teamImportReport, error) {
+	report := &teamImportReport{Atomic: atomic, DryRun: dryRun, Total: len(rows), Rows: rows}
+
+	// Leagues share few stadiums between many rows, so each name is only looked up
+	// once. Unknown names are remembered as 0.
+	stadiums := make(map[string]int64)
+
+	var teams []*data.Team
+	var written []*teamImportRow
+	for _, row := range rows {
+		team := &data.Team{
+			Name:      row.input.Name,
+			Location:  row.input.Location,
+			StadiumID: row.input.StadiumID,
+			History:   row.input.History,
+		}
+
+		if row.input.Stadium != "" {
+			key := strings.ToLower(row.input.Stadium)
+			id, ok := stadiums[key]
+			if !ok {
+				stadium, err := app.models.Stadiums.GetByName(row.input.Stadium)
+				switch {
+				case err == nil:
+					id = stadium.ID
+				case errors.Is(err, data.ErrRecordNotFound):
+				default:
+					return nil, err
+				}
+				stadiums[key] = id
+			}
+
+			switch {
+			case row.input.StadiumID != 0:
+				row.v.AddError("stadium", "must not be given as well as stadium_id")
+			case id == 0:
+				row.v.AddError("stadium", "must be the name of an existing stadium")
+			default:
+				team.StadiumID = id
+			}
+		}
+
+		data.ValidateTeam(row.v, team)
+		if row.input.Stadium != "" {
+			delete(row.v.Errors, "stadium_id")
+		}
+
+		if row.v.Valid() {
+			teams = append(teams, team)
+			written = append(written, row)
+		} else {
+			report.Failed++
+		}
+	}
+
+	if len(teams) > 0 {
+		rowErrs, err := app.models.Teams.Import(teams, atomic, dryRun || (atomic && report.Failed > 0), actorID)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, row := range written {
+			switch {
+			case rowErrs[i] == nil:
+				row.TeamID = teams[i].ID
+			case errors.Is(rowErrs[i], data.ErrUnknownStadium):
+				if row.input.Stadium != "" {
+					row.v.AddError("stadium", "must be the name of an existing stadium")
+				} else {
+					row.v.AddError("stadium_id", "must be an existing stadium")
+				}
+				report.Failed++
+			default:
+				return nil, rowErrs[i]
+			}
+		}
+	}
+
+	report.Committed = !dryRun && (!atomic || report.Failed == 0) && len(teams) > 0
+	for _, row := range rows {
+		switch {
+		case !row.v.Valid():
+			row.Status = importRowFailed
+			row.Errors = row.v.Errors
+			row.TeamID = 0
+		case report.Committed:
+			row.Status = importRowImported
+			report.Imported++
+		default:
+			row.Status = importRowValid
+			row.TeamID = 0
+		}
+	}
+
+	return report, nil
+}
+
+// importTeamsHandler creates teams in bulk from a CSV (text/csv) or newline-delimited
+// JSON (application/x-ndjson) body, and reports on every row. By default the valid
+// rows are imported even if others fail; with atomic=true nothing is imported unless
+// every row is valid, and with dry_run=true nothing is imported at all. CSV headers
+// that aren't team field names can be mapped with map=Column=field,...
+func (app *application) importTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	atomic := app.readBool(qs, "atomic", false, v)
+	dryRun := app.readBool(qs, "dry_run", false, v)
+	columns, err := parseColumnMap(qs.Get("map"))
+	if err != nil {
+		v.AddError("map", err.Error())
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var rows []*teamImportRow
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case csvMediaType:
+		rows, err = readTeamImportCSV(r.Body, columns)
+	case ndjsonMediaType:
+		rows, err = readTeamImportNDJSON(r.Body)
+	default:
+		app.unsupportedMediaTypeResponse(w, r, "Accept-Post", csvMediaType, ndjsonMediaType)
+		return
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	report, err := app.importTeams(rows, atomic, dryRun, app.contextGetUser(r).UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadTeamImportCSV(t *testing.T) {
+	columns, err := parseColumnMap("Club=name, Ground=stadium")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	body := "Club,location,Ground,history\n" +
+		"Arsenal,London,Emirates Stadium,\"Founded 1886,\nin Woolwich.\"\n" +
+		"Everton,Liverpool,Goodison Park,Founded 1878.\n"
+
+	rows, err := readTeamImportCSV(strings.NewReader(body), columns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	// Rows are numbered by the line they start on, so the quoted line break in
+	// the first row moves the second one down.
+	if rows[0].input.Name != "Arsenal" || rows[0].input.Stadium != "Emirates Stadium" || rows[0].Row != 2 {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	if rows[1].input.History != "Founded 1878." || rows[1].Row != 4 {
+		t.Errorf("unexpected second row: %+v", rows[1])
+	}
+
+	_, err = readTeamImportCSV(strings.NewReader("Club,location\n"), nil)
+	if err == nil {
+		t.Error("expected an error for an unmapped column")
+	}
+}
+
+func TestReadTeamImportNDJSON(t *testing.T) {
+	body := `{"name":"Arsenal","location":"London","stadium_id":1,"history":"Founded 1886."}
+
+{"name":"Everton","colour":"blue"}
+{"name":"Fulham","stadium_id":"7"}
+not json
+`
+
+	rows, err := readTeamImportNDJSON(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	tests := []struct {
+		row   int
+		field string
+	}{
+		{3, "colour"},
+		{4, "stadium_id"},
+		{5, "json"},
+	}
+	if !rows[0].v.Valid() || rows[0].input.StadiumID != 1 {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	for i, tt := range tests {
+		row := rows[i+1]
+		if row.Row != tt.row {
+			t.Errorf("expected row %d, got %d", tt.row, row.Row)
+		}
+		if _, ok := row.v.Errors[tt.field]; !ok {
+			t.Errorf("row %d: expected an error for %q, got %v", row.Row, tt.field, row.v.Errors)
+		}
+	}
+}
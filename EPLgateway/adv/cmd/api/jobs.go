@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// purgeTrash runs for the lifetime of the process, permanently removing teams that
+// have been in the trash for longer than the configured retention period, unless
+// something still refers to them.
+func (app *application) purgeTrash() {
+	if app.config.trash.retention <= 0 || app.config.trash.purgeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-app.config.trash.retention)
+
+		n, kept, err := app.models.Teams.Purge(cutoff)
+		if err != nil {
+			app.logger.Printf("purging trash: %v", err)
+		} else {
+			if n > 0 {
+				app.logger.Printf("purged %d teams deleted before %s", n, cutoff.Format(time.RFC3339))
+			}
+			if kept > 0 {
+				app.logger.Printf("kept %d teams deleted before %s in the trash: they are still referenced by matches, seasons, players or transfers", kept, cutoff.Format(time.RFC3339))
+			}
+		}
+
+		<-ticker.C
+	}
+}
+
+// refreshPlayerStats runs for the lifetime of the process, refreshing the player stats
+// whenever match events change. Changes that come in while a refresh is running, or
+// within the minimum interval after it, are picked up together by the next one.
+func (app *application) refreshPlayerStats() {
+	for range app.models.PlayerStats.Stale() {
+		start := time.Now()
+
+		err := app.models.PlayerStats.Refresh()
+		if err != nil {
+			app.logger.Printf("refreshing player stats: %v", err)
+		}
+
+		time.Sleep(app.config.stats.refreshInterval - time.Since(start))
+	}
+}
+
+// listenForChanges runs for the lifetime of the process, waking up the event streams
+// when another API server or an operator command commits to their feeds.
+func (app *application) listenForChanges() {
+	err := app.models.Listen(context.Background(), app.config.db.dsn, func(err error) {
+		app.logger.Printf("listening for changes: %v", err)
+	})
+	if err != nil {
+		app.logger.Printf("listening for changes: %v; streams will only see changes made by this server", err)
+	}
+}
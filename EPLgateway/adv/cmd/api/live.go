@@ -0,0 +1,288 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxLiveSubscriptions is the number of matches one WebSocket connection can follow at
+// the same time.
+const maxLiveSubscriptions = 50
+
+// liveMatchHandler streams the live feed of the match in the URL as Server-Sent
+// Events: score changes, new match events and status changes. Like the team events
+// stream, it resumes from Last-Event-ID or after_id, and starts from now otherwise.
+func (app *application) liveMatchHandler(w http.ResponseWriter, r *http.Request) {
+	matchID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Matches.Get(matchID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	cursor, err := app.readEventCursor(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rc, err := app.startEventStream(w)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	updates, errs := app.models.MatchUpdates.Stream(ctx, matchID, cursor)
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				select {
+				case err := <-errs:
+					app.logError(r, err)
+				default:
+				}
+				return
+			}
+
+			js, err := json.Marshal(update)
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", update.ID, update.Type, js)
+			rc.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			rc.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// liveMessage is a message sent by the client over the live WebSocket. Action is
+// "subscribe" or "unsubscribe". AfterID resumes a subscription after the last update
+// the client saw; without it the subscription starts from now.
+type liveMessage struct {
+	Action  string `json:"action"`
+	MatchID int64  `json:"match_id"`
+	AfterID *int64 `json:"after_id"`
+}
+
+// liveReply is a message sent to the client over the live WebSocket. Type is "update",
+// "subscribed", "unsubscribed", "error" or "heartbeat".
+type liveReply struct {
+	Type    string            `json:"type"`
+	MatchID int64             `json:"match_id,omitempty"`
+	Update  *data.MatchUpdate `json:"update,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// liveWebSocketHandler follows the live feeds of several matches over one WebSocket.
+// The client sends subscribe and unsubscribe messages, and receives the updates for
+// every match it is subscribed to, tagged with the match ID. Each subscription is a
+// stream of its own, so a slow or resumed subscription doesn't hold up the others.
+func (app *application) liveWebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	// The server's timeouts would otherwise close the connection, so lift them before
+	// it is taken over by the WebSocket.
+	rc := http.NewResponseController(w)
+	err := rc.SetWriteDeadline(time.Time{})
+	if err == nil {
+		err = rc.SetReadDeadline(time.Time{})
+	}
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// websocket.Server, unlike websocket.Handler, doesn't insist on an Origin header,
+	// which mobile clients don't send.
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		app.serveLiveWebSocket(r, ws)
+	}}
+	server.ServeHTTP(w, r)
+}
+
+func (app *application) serveLiveWebSocket(r *http.Request, ws *websocket.Conn) {
+	defer ws.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	var wg sync.WaitGroup
+	subscriptions := map[int64]context.CancelFunc{}
+
+	// Stop every stream and wait for the goroutines forwarding them to finish.
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	// All writes go through this channel to a single writer, since a websocket.Conn
+	// can't be written to from several goroutines at once.
+	out := make(chan liveReply, 64)
+	send := func(reply liveReply) bool {
+		select {
+		case out <- reply:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		// If the client stops reading, closing the connection also ends the read
+		// loop below.
+		defer ws.Close()
+		defer cancel()
+
+		heartbeat := time.NewTicker(sseHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var reply liveReply
+			select {
+			case reply = <-out:
+			case <-heartbeat.C:
+				reply = liveReply{Type: "heartbeat"}
+			case <-ctx.Done():
+				return
+			}
+
+			err := websocket.JSON.Send(ws, reply)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var msg liveMessage
+		err := websocket.JSON.Receive(ws, &msg)
+		if err != nil {
+			// The client has gone away, or sent something that isn't JSON, which we
+			// treat the same.
+			return
+		}
+
+		reply := liveReply{MatchID: msg.MatchID}
+
+		switch msg.Action {
+		case "subscribe":
+			if _, ok := subscriptions[msg.MatchID]; ok {
+				reply.Type = "subscribed"
+				break
+			}
+
+			cursor, err := app.liveCursor(msg)
+			if err != nil {
+				reply.Type = "error"
+				switch {
+				case errors.Is(err, errLiveMatchNotFound), errors.Is(err, errInvalidLiveCursor):
+					reply.Error = err.Error()
+				default:
+					app.logError(r, err)
+					reply.Error = "the server encountered a problem and could not process your request"
+				}
+				break
+			}
+			if len(subscriptions) >= maxLiveSubscriptions {
+				reply.Type = "error"
+				reply.Error = fmt.Sprintf("no more than %d matches can be followed at once", maxLiveSubscriptions)
+				break
+			}
+
+			subCtx, stop := context.WithCancel(ctx)
+			subscriptions[msg.MatchID] = stop
+			updates, errs := app.models.MatchUpdates.Stream(subCtx, msg.MatchID, cursor)
+
+			// Confirm the subscription before any of its updates can be sent.
+			if !send(liveReply{Type: "subscribed", MatchID: msg.MatchID}) {
+				return
+			}
+
+			wg.Add(1)
+			go func(matchID int64) {
+				defer wg.Done()
+
+				for update := range updates {
+					if !send(liveReply{Type: "update", MatchID: matchID, Update: update}) {
+						return
+					}
+				}
+
+				select {
+				case err := <-errs:
+					app.logError(r, err)
+					send(liveReply{Type: "error", MatchID: matchID, Error: "the feed for this match has stopped, unsubscribe and subscribe again to resume"})
+				default:
+				}
+			}(msg.MatchID)
+			continue
+		case "unsubscribe":
+			if stop, ok := subscriptions[msg.MatchID]; ok {
+				stop()
+				delete(subscriptions, msg.MatchID)
+			}
+			reply.Type = "unsubscribed"
+		default:
+			reply.Type = "error"
+			reply.Error = `action must be "subscribe" or "unsubscribe"`
+		}
+
+		if !send(reply) {
+			return
+		}
+	}
+}
+
+var (
+	errLiveMatchNotFound = errors.New("match not found")
+	errInvalidLiveCursor = errors.New("invalid event cursor")
+)
+
+// liveCursor checks that the match in a subscribe message exists and returns the
+// cursor its stream starts after, -1 to start from now.
+func (app *application) liveCursor(msg liveMessage) (int64, error) {
+	_, err := app.models.Matches.Get(msg.MatchID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return 0, errLiveMatchNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	if msg.AfterID != nil {
+		if *msg.AfterID < 0 {
+			return 0, errInvalidLiveCursor
+		}
+		return *msg.AfterID, nil
+	}
+
+	return -1, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"net/http"
+)
+
+// createMatchEventHandler records an event in the match in the URL. Goals update the
+// match score, which is returned alongside the event.
+func (app *application) createMatchEventHandler(w http.ResponseWriter, r *http.Request) {
+	matchID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		TeamID          int64  `json:"team_id"`
+		Type            string `json:"type"`
+		Minute          int    `json:"minute"`
+		AddedTime       int    `json:"added_time"`
+		PlayerID        *int64 `json:"player_id"`
+		RelatedPlayerID *int64 `json:"related_player_id"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event := &data.MatchEvent{
+		MatchID:         matchID,
+		TeamID:          input.TeamID,
+		Type:            input.Type,
+		Minute:          input.Minute,
+		AddedTime:       input.AddedTime,
+		PlayerID:        input.PlayerID,
+		RelatedPlayerID: input.RelatedPlayerID,
+	}
+
+	v := validator.New()
+	if data.ValidateMatchEvent(v, event); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	match, err := app.models.MatchEvents.Insert(event)
+	if err != nil {
+		var timelineErr *data.TimelineError
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrMatchNotStarted):
+			v.AddError("status", "events can only be recorded once the match is live")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &timelineErr):
+			v.AddError(timelineErr.Key, timelineErr.Message)
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"event": event, "match": match}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listMatchEventsHandler returns the timeline of the match in the URL.
+func (app *application) listMatchEventsHandler(w http.ResponseWriter, r *http.Request) {
+	matchID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Matches.Get(matchID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	events, err := app.models.MatchEvents.GetAllForMatch(matchID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// validateMatchTeams checks that both teams in a match exist, recording a validation
+// error for each one that doesn't, and returns the home team so that callers can
+// default the venue to its stadium.
+func (app *application) validateMatchTeams(v *validator.Validator, match *data.Match) (*data.Team, error) {
+	var home *data.Team
+
+	for _, side := range []struct {
+		key string
+		id  int64
+	}{
+		{"home_team_id", match.HomeTeamID},
+		{"away_team_id", match.AwayTeamID},
+	} {
+		if side.id < 1 {
+			continue
+		}
+
+		team, err := app.models.Teams.Get(side.id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError(side.key, "team does not exist")
+				continue
+			default:
+				return nil, err
+			}
+		}
+
+		if side.key == "home_team_id" {
+			home = team
+		}
+	}
+
+	return home, nil
+}
+
+// saveMatchErrorResponse sends the response for an error returned by the Insert()
+// and Update() methods of the match model. Double bookings are reported as a
+// validation failure on the kickoff, and changes the events rule out as one on the
+// events.
+func (app *application) saveMatchErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	var doubleBooking *data.DoubleBookingError
+
+	switch {
+	case errors.As(err, &doubleBooking):
+		v.AddError("kickoff", fmt.Sprintf("team %d already has a match (%d) on this day", doubleBooking.TeamID, doubleBooking.MatchID))
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrMatchHasEvents):
+		v.AddError("events", "the teams and the score can't be changed once events have been recorded; the score is computed from the goals")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMatchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		HomeTeamID int64     `json:"home_team_id"`
+		AwayTeamID int64     `json:"away_team_id"`
+		Kickoff    time.Time `json:"kickoff"`
+		Venue      string    `json:"venue"`
+		Status     string    `json:"status"`
+		HomeScore  *int      `json:"home_score"`
+		AwayScore  *int      `json:"away_score"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	match := &data.Match{
+		HomeTeamID: input.HomeTeamID,
+		AwayTeamID: input.AwayTeamID,
+		Kickoff:    input.Kickoff,
+		Venue:      input.Venue,
+		Status:     input.Status,
+		HomeScore:  input.HomeScore,
+		AwayScore:  input.AwayScore,
+	}
+	if match.Status == "" {
+		match.Status = data.MatchScheduled
+	}
+
+	v := validator.New()
+
+	home, err := app.validateMatchTeams(v, match)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Unless the client says otherwise, a match is played at the home team's stadium.
+	if match.Venue == "" && home != nil {
+		match.Venue = home.Stadium
+	}
+
+	if data.ValidateMatch(v, match); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Matches.Insert(match)
+	if err != nil {
+		app.saveMatchErrorResponse(w, r, v, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/matches/%d", match.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"match": match}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMatchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	match, err := app.models.Matches.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"match": match}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateMatchHandler applies a partial update: fields missing from the request body
+// keep their current values. Use null scores to clear the score of a match.
+func (app *application) updateMatchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	match, err := app.models.Matches.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Use pointers so that we can tell a field that wasn't provided from its zero
+	// value. The scores are pointers already; a nested pointer tells us whether they
+	// were given at all.
+	var input struct {
+		HomeTeamID *int64     `json:"home_team_id"`
+		AwayTeamID *int64     `json:"away_team_id"`
+		Kickoff    *time.Time `json:"kickoff"`
+		Venue      *string    `json:"venue"`
+		Status     *string    `json:"status"`
+		HomeScore  **int      `json:"home_score"`
+		AwayScore  **int      `json:"away_score"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.HomeTeamID != nil {
+		match.HomeTeamID = *input.HomeTeamID
+	}
+	if input.AwayTeamID != nil {
+		match.AwayTeamID = *input.AwayTeamID
+	}
+	if input.Kickoff != nil {
+		match.Kickoff = *input.Kickoff
+	}
+	if input.Venue != nil {
+		match.Venue = *input.Venue
+	}
+	if input.Status != nil {
+		match.Status = *input.Status
+	}
+	if input.HomeScore != nil {
+		match.HomeScore = *input.HomeScore
+	}
+	if input.AwayScore != nil {
+		match.AwayScore = *input.AwayScore
+	}
+
+	v := validator.New()
+
+	_, err = app.validateMatchTeams(v, match)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if data.ValidateMatch(v, match); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Matches.Update(match)
+	if err != nil {
+		app.saveMatchErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"match": match}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMatchHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Matches.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "match successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listMatchesHandler lists matches, optionally only those involving a team, kicking
+// off between two dates (both inclusive, in UK time) or with a given status.
+func (app *application) listMatchesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.MatchFilter
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.TeamID = int64(app.readInt(qs, "team_id", 0, v))
+	input.From = app.readDate(qs, "from", data.MatchdayLocation, v)
+	input.To = app.readDate(qs, "to", data.MatchdayLocation, v)
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "kickoff")
+	input.Filters.SortSafelist = []string{"id", "kickoff", "-id", "-kickoff"}
+
+	if !input.To.IsZero() {
+		v.Check(input.From.IsZero() || !input.To.Before(input.From), "to", "must not be before from")
+		// The filter's upper bound is exclusive, so move it to the start of the next
+		// day.
+		input.To = input.To.AddDate(0, 0, 1)
+	}
+	if input.Status != "" {
+		v.Check(validator.PermittedValue(input.Status, data.MatchStatuses...), "status", "must be one of scheduled, live, finished or postponed")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	matches, metadata, err := app.models.Matches.GetAll(input.MatchFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"matches": matches, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The authenticate() middleware asks auth-service about the bearer token in the
+// Authorization header, if there is one, and stores the result in the request
+// context. Requests without the header continue as the anonymous user.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Responses vary depending on the token, so caches must take the header
+		// into account.
+		w.Header().Add("Vary", "Authorization")
+
+		// Without introspection every request is anonymous, and the permission checks
+		// below let it through.
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" || app.introspector == nil {
+			r = app.contextSetUser(r, anonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := app.introspector.Introspect(r.Context(), headerParts[1])
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !user.Active {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		r = app.contextSetUser(r, user)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireAuthenticatedUser() middleware rejects anonymous requests, unless
+// authentication is disabled.
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.Active && app.introspector != nil {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// The requirePermission() middleware checks that the authenticated user holds the
+// given permission code, as reported by auth-service. Nothing is checked when
+// authentication is disabled.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+		if !user.HasPermission(code) && app.introspector != nil {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return app.requireAuthenticatedUser(fn)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, js string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(js), &v); err != nil {
+		t.Fatalf("error decoding %s: %v", js, err)
+	}
+	return v
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	doc := decode(t, `{"name":"Arsenal","stadium":"Highbury","history":"Founded 1886."}`)
+	patch := decode(t, `{"stadium":"Emirates Stadium","history":null}`)
+
+	got := applyMergePatch(doc, patch)
+	want := decode(t, `{"name":"Arsenal","stadium":"Emirates Stadium"}`)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		index int
+		err   error
+	}{
+		{
+			name:  "replace and test",
+			doc:   `{"name":"Arsenal","stadium":"Highbury","version":3}`,
+			patch: `[{"op":"test","path":"/version","value":3},{"op":"replace","path":"/stadium","value":"Emirates Stadium"}]`,
+			want:  `{"name":"Arsenal","stadium":"Emirates Stadium","version":3}`,
+		},
+		{
+			name:  "move, copy and remove",
+			doc:   `{"a":"x","b":["y"]}`,
+			patch: `[{"op":"move","from":"/a","path":"/c"},{"op":"copy","from":"/c","path":"/b/0"},{"op":"remove","path":"/b/1"}]`,
+			want:  `{"b":["x"],"c":"x"}`,
+		},
+		{
+			name:  "escaped pointer",
+			doc:   `{"a/b":1,"c~d":2}`,
+			patch: `[{"op":"add","path":"/a~1b","value":3},{"op":"remove","path":"/c~0d"}]`,
+			want:  `{"a/b":3}`,
+		},
+		{
+			name:  "failed test",
+			doc:   `{"version":3}`,
+			patch: `[{"op":"add","path":"/name","value":"x"},{"op":"test","path":"/version","value":2}]`,
+			index: 1,
+			err:   errTestFailed,
+		},
+		{
+			name:  "missing path",
+			doc:   `{"name":"Arsenal"}`,
+			patch: `[{"op":"replace","path":"/stadium","value":"x"}]`,
+			index: 0,
+			err:   errPathNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []patchOperation
+			if err := json.Unmarshal([]byte(tt.patch), &ops); err != nil {
+				t.Fatalf("error decoding patch: %v", err)
+			}
+
+			got, err := applyJSONPatch(decode(t, tt.doc), ops)
+			if tt.err != nil {
+				var patchErr *patchError
+				if !errors.As(err, &patchErr) || !errors.Is(err, tt.err) || patchErr.Index != tt.index {
+					t.Fatalf("expected %v at operation %d, got %v", tt.err, tt.index, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := decode(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// playerInput is the request body accepted when creating or replacing a player.
+type playerInput struct {
+	Name        string    `json:"name"`
+	ShirtNumber int       `json:"shirt_number"`
+	Position    string    `json:"position"`
+	Nationality string    `json:"nationality"`
+	DateOfBirth data.Date `json:"date_of_birth"`
+}
+
+func (input playerInput) copyTo(player *data.Player) {
+	player.Name = input.Name
+	player.ShirtNumber = input.ShirtNumber
+	player.Position = input.Position
+	player.Nationality = input.Nationality
+	player.DateOfBirth = input.DateOfBirth
+}
+
+// createPlayerHandler adds a player to the squad of the team in the URL.
+func (app *application) createPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Get(teamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input playerInput
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	player := &data.Player{TeamID: team.ID}
+	input.copyTo(player)
+
+	v := validator.New()
+	if data.ValidatePlayer(v, player); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Players.Insert(player)
+	if err != nil {
+		switch {
+		// The unique constraint on the squad's shirt numbers is reported like any
+		// other validation failure.
+		case errors.Is(err, data.ErrDuplicateShirtNumber):
+			v.AddError("shirt_number", "is already taken by another player in this squad")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/players/%d", player.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"player": player}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listPlayersHandler lists the squad of the team in the URL, optionally filtered by
+// position and nationality. as_of=YYYY-MM-DD lists the squad as it was on that day.
+func (app *application) listPlayersHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Position    string
+		Nationality string
+		AsOf        time.Time
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Position = app.readString(qs, "position", "")
+	input.Nationality = app.readString(qs, "nationality", "")
+	input.AsOf = app.readDate(qs, "as_of", time.UTC, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "shirt_number")
+	input.Filters.SortSafelist = []string{"id", "name", "shirt_number", "position", "date_of_birth", "-id", "-name", "-shirt_number", "-position", "-date_of_birth"}
+
+	if input.Position != "" {
+		v.Check(validator.PermittedValue(input.Position, data.PlayerPositions...), "position", "must be one of goalkeeper, defender, midfielder or forward")
+	}
+	v.Check(!input.AsOf.After(time.Now()), "as_of", "must not be in the future")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Teams.Get(teamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	players, metadata, err := app.models.Players.GetAllForTeam(teamID, input.Position, input.Nationality, input.AsOf, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"players": players, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	player, err := app.models.Players.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"player": player}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updatePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	player, err := app.models.Players.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input playerInput
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	input.copyTo(player)
+
+	v := validator.New()
+	if data.ValidatePlayer(v, player); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Players.Update(player)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateShirtNumber):
+			v.AddError("shirt_number", "is already taken by another player in this squad")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"player": player}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Players.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "player successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"net/http"
 )
 
-func (app *application) routes() *httprouter.Router {
+func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	// Initialize a new httprouter router instance.
@@ -13,11 +13,82 @@ func (app *application) routes() *httprouter.Router {
 
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	//router.HandlerFunc(http.MethodGet, "/v1/teams", app.listTeamsHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/teams", app.createTeamsHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/teams/:id", app.showTeamsHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/teams/:id", app.updateTeamsHandler)
-	router.HandlerFunc(http.MethodDelete, "/v1/teams/:id", app.deleteTeamsHandler)
-	return router
+	router.HandlerFunc(http.MethodGet, "/v1/teams", app.listTeamsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/teams", app.requirePermission("teams:write", app.createTeamsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/teams/:id", app.byNameOrID(map[string]http.HandlerFunc{
+		"events": app.teamEventsHandler,
+		"export": app.exportTeamsHandler,
+		"search": app.searchTeamsHandler,
+		"trash":  app.requirePermission("teams:admin", app.listTrashedTeamsHandler),
+	}, app.showTeamsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/teams/:id", app.byNameOrID(map[string]http.HandlerFunc{
+		"import": app.requirePermission("teams:write", app.importTeamsHandler),
+	}, app.methodNotAllowedResponse))
+	router.HandlerFunc(http.MethodPut, "/v1/teams/:id", app.requirePermission("teams:write", app.updateTeamsHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/teams/:id", app.requirePermission("teams:write", app.patchTeamsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/teams/:id", app.requirePermission("teams:write", app.deleteTeamsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/restore", app.requirePermission("teams:admin", app.restoreTeamHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/teams/:id/revisions", app.requirePermission("teams:write", app.listTeamRevisionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/revisions/:rev/revert", app.requirePermission("teams:write", app.revertTeamHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/teams/:id/crest", app.requirePermission("teams:write", app.putTeamCrestHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/teams/:id/crest", app.requirePermission("teams:write", app.deleteTeamCrestHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/crests/:key", app.showCrestHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/teams/:id/head-to-head/:other", app.requirePermission("matches:read", app.headToHeadHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/teams/:id/players", app.listPlayersHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/players", app.requirePermission("teams:write", app.createPlayerHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/players/:id", app.showPlayerHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/players/:id", app.requirePermission("teams:write", app.updatePlayerHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/players/:id", app.requirePermission("teams:write", app.deletePlayerHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/transfers", app.listTransfersHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/transfers", app.requirePermission("teams:write", app.createTransferHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/transfers/:id", app.byNameOrID(map[string]http.HandlerFunc{
+		"windows": app.listTransferWindowsHandler,
+	}, app.showTransferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/transfers/windows", app.requirePermission("teams:admin", app.createTransferWindowHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/stadiums", app.listStadiumsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/stadiums", app.requirePermission("teams:write", app.createStadiumHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stadiums/:id", app.byNameOrID(map[string]http.HandlerFunc{
+		"nearby": app.nearbyStadiumsHandler,
+	}, app.showStadiumHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/stadiums/:id", app.requirePermission("teams:write", app.updateStadiumHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/stadiums/:id", app.requirePermission("teams:write", app.deleteStadiumHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/matches", app.requirePermission("matches:read", app.listMatchesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/matches", app.requirePermission("matches:write", app.createMatchHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/matches/:id", app.requirePermission("matches:read", app.byNameOrID(map[string]http.HandlerFunc{
+		"live": app.liveWebSocketHandler,
+	}, app.showMatchHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/matches/:id", app.requirePermission("matches:write", app.updateMatchHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/matches/:id", app.requirePermission("matches:write", app.deleteMatchHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/matches/:id/events", app.requirePermission("matches:read", app.listMatchEventsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/matches/:id/events", app.requirePermission("matches:write", app.createMatchEventHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/matches/:id/live", app.requirePermission("matches:read", app.liveMatchHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/seasons", app.requirePermission("matches:read", app.listSeasonsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/seasons", app.requirePermission("teams:admin", app.createSeasonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/seasons/:id", app.requirePermission("matches:read", app.showSeasonHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/seasons/:id/close", app.requirePermission("teams:admin", app.closeSeasonHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/seasons/:id/fixtures", app.requirePermission("teams:admin", app.generateFixturesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/standings", app.requirePermission("matches:read", app.standingsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/players", app.requirePermission("matches:read", app.listPlayerStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/stats/leaders", app.requirePermission("matches:read", app.playerStatsLeadersHandler))
+	return app.authenticate(router)
+}
+
+// httprouter won't register a static segment such as /v1/teams/events next to the
+// /v1/teams/:id wildcard. Named sub-resources are therefore registered through
+// byNameOrID(), which checks the :id segment against the names before falling back
+// to the handler for a numeric ID.
+func (app *application) byNameOrID(named map[string]http.HandlerFunc, byID http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if next, ok := named[params.ByName("id")]; ok {
+			next(w, r)
+			return
+		}
+		byID(w, r)
+	}
 }
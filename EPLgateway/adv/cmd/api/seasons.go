@@ -0,0 +1,222 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// createSeasonHandler opens a season with the given teams. Only one season can be open
+// at a time.
+func (app *application) createSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string    `json:"name"`
+		StartsOn data.Date `json:"starts_on"`
+		EndsOn   data.Date `json:"ends_on"`
+		TeamIDs  []int64   `json:"team_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	season := &data.Season{
+		Name:     input.Name,
+		StartsOn: input.StartsOn,
+		EndsOn:   input.EndsOn,
+	}
+
+	v := validator.New()
+	data.ValidateSeason(v, season)
+	data.ValidateSeasonTeams(v, "team_ids", input.TeamIDs)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Seasons.Insert(season, input.TeamIDs)
+	if err != nil {
+		app.saveSeasonErrorResponse(w, r, v, "team_ids", err)
+		return
+	}
+
+	// Read the season back so that the response lists its teams.
+	season, err = app.models.Seasons.Get(season.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/seasons/%d", season.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"season": season}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// saveSeasonErrorResponse sends the response for an error from opening a season,
+// either directly or by closing the previous one. teamsKey is the input field that
+// unknown teams are reported against.
+func (app *application) saveSeasonErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, teamsKey string, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateSeason):
+		v.AddError("name", "a season with this name already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrSeasonAlreadyOpen):
+		v.AddError("status", "another season is still open and must be closed first")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrUnknownTeam):
+		v.AddError(teamsKey, "must only contain teams that exist")
+		app.failedValidationResponse(w, r, v.Errors)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	season, err := app.models.Seasons.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"season": season}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listSeasonsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+
+	// Seasons are always listed newest first, so there is nothing to sort by.
+	input.Filters.Sort = "id"
+	input.Filters.SortSafelist = []string{"id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	seasons, metadata, err := app.models.Seasons.GetAll(input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"seasons": seasons, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// closeSeasonHandler closes an open season: the bottom three in the final table are
+// relegated and the next season is opened with the three promoted teams in their
+// place. The next season's name and dates default to the closed season's moved on by
+// a year, which works for seasons named like "2024-25".
+func (app *application) closeSeasonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		PromotedTeamIDs []int64 `json:"promoted_team_ids"`
+		NextSeason      struct {
+			Name     string    `json:"name"`
+			StartsOn data.Date `json:"starts_on"`
+			EndsOn   data.Date `json:"ends_on"`
+		} `json:"next_season"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	season, err := app.models.Seasons.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	next := &data.Season{
+		Name:     input.NextSeason.Name,
+		StartsOn: input.NextSeason.StartsOn,
+		EndsOn:   input.NextSeason.EndsOn,
+	}
+	if next.Name == "" {
+		if year, err := data.ParseSeason(season.Name); err == nil {
+			next.Name = (year + 1).String()
+		}
+	}
+	if time.Time(next.StartsOn).IsZero() {
+		next.StartsOn = data.Date(time.Time(season.StartsOn).AddDate(1, 0, 0))
+	}
+	if time.Time(next.EndsOn).IsZero() {
+		next.EndsOn = data.Date(time.Time(season.EndsOn).AddDate(1, 0, 0))
+	}
+
+	v := validator.New()
+	data.ValidatePromotedTeams(v, input.PromotedTeamIDs)
+	if data.ValidateSeason(v, next); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	closure, err := app.models.Seasons.Close(season.ID, input.PromotedTeamIDs, next)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrSeasonClosed):
+			v.AddError("status", "season is already closed")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrUnplayedMatches):
+			v.AddError("status", "season still has matches that haven't finished")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrTeamAlreadyInSeason):
+			v.AddError("promoted_team_ids", "must not contain teams that are already in the season")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.saveSeasonErrorResponse(w, r, v, "promoted_team_ids", err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"closure": closure}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
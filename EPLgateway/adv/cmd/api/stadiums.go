@@ -0,0 +1,243 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// maxNearbyRadiusKm is the largest radius that stadiums can be searched for in.
+const maxNearbyRadiusKm = 1000
+
+// stadiumInput is the request body accepted when creating or replacing a stadium.
+type stadiumInput struct {
+	Name       string   `json:"name"`
+	City       string   `json:"city"`
+	Capacity   *int     `json:"capacity"`
+	OpenedYear *int     `json:"opened_year"`
+	Latitude   *float64 `json:"latitude"`
+	Longitude  *float64 `json:"longitude"`
+	Surface    string   `json:"surface"`
+}
+
+func (input stadiumInput) copyTo(stadium *data.Stadium) {
+	stadium.Name = input.Name
+	stadium.City = input.City
+	stadium.Capacity = input.Capacity
+	stadium.OpenedYear = input.OpenedYear
+	stadium.Latitude = input.Latitude
+	stadium.Longitude = input.Longitude
+	stadium.Surface = input.Surface
+}
+
+func (app *application) createStadiumHandler(w http.ResponseWriter, r *http.Request) {
+	var input stadiumInput
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	stadium := &data.Stadium{}
+	input.copyTo(stadium)
+
+	v := validator.New()
+	if data.ValidateStadium(v, stadium); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Stadiums.Insert(stadium)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateStadium):
+			v.AddError("name", "a stadium with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/stadiums/%d", stadium.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"stadium": stadium}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showStadiumHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	stadium, err := app.models.Stadiums.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stadium": stadium}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateStadiumHandler replaces a stadium. Stadiums carried over from the teams' old
+// free-text stadium names are completed this way, since every field is required.
+func (app *application) updateStadiumHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	stadium, err := app.models.Stadiums.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input stadiumInput
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	input.copyTo(stadium)
+
+	v := validator.New()
+	if data.ValidateStadium(v, stadium); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Stadiums.Update(stadium)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateStadium):
+			v.AddError("name", "a stadium with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stadium": stadium}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteStadiumHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Stadiums.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrStadiumInUse):
+			app.failedValidationResponse(w, r, map[string]string{"stadium": "is the home ground of at least one team, including teams in the trash"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "stadium successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listStadiumsHandler lists stadiums, optionally only those whose name or city
+// contains the given text.
+func (app *application) listStadiumsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		City string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Name = app.readString(qs, "name", "")
+	input.City = app.readString(qs, "city", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "city", "capacity", "opened_year", "-id", "-name", "-city", "-capacity", "-opened_year"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stadiums, metadata, err := app.models.Stadiums.GetAll(input.Name, input.City, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stadiums": stadiums, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// nearbyStadiumsHandler lists the stadiums within radius_km (50 by default) of the
+// point given by lat and lon, nearest first, with their distance from it.
+func (app *application) nearbyStadiumsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	lat := app.readFloat(qs, "lat", v)
+	lon := app.readFloat(qs, "lon", v)
+	radius := 50.0
+	if r := app.readFloat(qs, "radius_km", v); r != nil {
+		radius = *r
+	}
+
+	data.ValidateCoordinates(v, lat, lon, "lat", "lon")
+	v.Check(radius > 0, "radius_km", "must be greater than zero")
+	v.Check(radius <= maxNearbyRadiusKm, "radius_km", fmt.Sprintf("must not be greater than %d", maxNearbyRadiusKm))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stadiums, err := app.models.Stadiums.Nearby(*lat, *lon, radius)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stadiums": stadiums}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// resolveSeason looks a season up by name, falling back to the calendar season, and
+// returns its canonical name, its ID and the time range its matches kick off in. The
+// ID is 0 for a calendar season. It returns data.ErrInvalidSeason if name is neither
+// an opened season nor a "YYYY-YY" one.
+func (app *application) resolveSeason(name string) (string, int64, time.Time, time.Time, error) {
+	season, err := app.models.Seasons.GetByName(name)
+	switch {
+	case err == nil:
+		from, to := season.Window()
+		return season.Name, season.ID, from, to, nil
+	case errors.Is(err, data.ErrRecordNotFound):
+		year, err := data.ParseSeason(name)
+		if err != nil {
+			return "", 0, time.Time{}, time.Time{}, err
+		}
+		return year.String(), 0, year.Start(), year.End(), nil
+	default:
+		return "", 0, time.Time{}, time.Time{}, err
+	}
+}
+
+// standingsHandler returns the league table for a season, computed from the results
+// of its finished matches. The season defaults to the current one, and as_of shows
+// the table as it stood at the end of that day. A season opened through the seasons
+// endpoints is played between its own dates and teams, as it is when it is closed;
+// any other "YYYY-YY" season is taken to be the calendar season.
+func (app *application) standingsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	asOf := app.readDate(qs, "as_of", data.MatchdayLocation, v)
+
+	year := data.SeasonOf(time.Now())
+	if !asOf.IsZero() {
+		year = data.SeasonOf(asOf)
+	}
+	name := app.readString(qs, "season", year.String())
+
+	name, seasonID, from, to, err := app.resolveSeason(name)
+	switch {
+	case errors.Is(err, data.ErrInvalidSeason):
+		v.AddError("season", "must be a season in the format YYYY-YY, such as 2024-25")
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !asOf.IsZero() && v.Valid() {
+		v.Check(!asOf.Before(from) && asOf.Before(to), "as_of", "must be a date within the season")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Results up to and including the as_of day count, so the upper bound is the
+	// start of the next day.
+	if !asOf.IsZero() {
+		to = asOf.AddDate(0, 0, 1)
+	}
+
+	results, err := app.models.Matches.GetResults(seasonID, from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"season":    name,
+		"standings": data.ComputeStandings(results),
+	}
+	if !asOf.IsZero() {
+		env["as_of"] = asOf.Format(time.DateOnly)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
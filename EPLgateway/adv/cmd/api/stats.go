@@ -0,0 +1,110 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// readPlayerStatsFilter reads the season and team_id parameters shared by the stats
+// endpoints. The season is given by name, such as 2024-25, and must have been opened
+// through the seasons endpoints; without it the stats cover every match played.
+func (app *application) readPlayerStatsFilter(qs url.Values, v *validator.Validator) (data.PlayerStatsFilter, error) {
+	var filter data.PlayerStatsFilter
+
+	filter.TeamID = int64(app.readInt(qs, "team_id", 0, v))
+
+	if name := app.readString(qs, "season", ""); name != "" {
+		season, err := app.models.Seasons.GetByName(name)
+		switch {
+		case err == nil:
+			filter.SeasonID = season.ID
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("season", "must be the name of an existing season")
+		default:
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// listPlayerStatsHandler lists the totals of every player who has taken part in a
+// match, for a season and team if given. The stats are refreshed in the background
+// shortly after match events change.
+func (app *application) listPlayerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filter, err := app.readPlayerStatsFilter(qs, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-goals")
+	input.Filters.SortSafelist = []string{"player_name", "-player_name"}
+	for _, metric := range data.PlayerStatMetrics {
+		input.Filters.SortSafelist = append(input.Filters.SortSafelist, metric, "-"+metric)
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	stats, metadata, err := app.models.PlayerStats.GetAll(filter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// playerStatsLeadersHandler returns the top players for one metric, goals by default.
+// Players level on the metric share a rank, so the last place can hold more than one
+// player and the board can run past limit.
+func (app *application) playerStatsLeadersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filter, err := app.readPlayerStatsFilter(qs, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	metric := app.readString(qs, "metric", "goals")
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(slices.Contains(data.PlayerStatMetrics, metric), "metric", "must be one of appearances, goals, assists, minutes_played, yellow_cards, red_cards or goals_per_90")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 100, "limit", "must be a maximum of 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	leaders, err := app.models.PlayerStats.Leaders(metric, filter, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metric": metric, "leaders": leaders}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
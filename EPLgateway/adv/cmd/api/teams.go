@@ -3,8 +3,10 @@ package main
 import (
 	"adv.erakaisar.net/internal/data"
 	"adv.erakaisar.net/internal/validator"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 )
 
@@ -14,10 +16,10 @@ func (app *application) createTeamsHandler(w http.ResponseWriter, r *http.Reques
 	// of the Movie struct that we created earlier). This struct will be our *target
 	// decode destination*.
 	var input struct {
-		Name     string `json:"name"`
-		Location string `json:"location"`
-		Stadium  string `json:"stadium"`
-		History  string `json:"history"`
+		Name      string `json:"name"`
+		Location  string `json:"location"`
+		StadiumID int64  `json:"stadium_id"`
+		History   string `json:"history"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -26,10 +28,10 @@ func (app *application) createTeamsHandler(w http.ResponseWriter, r *http.Reques
 	}
 	// Copy the values from the input struct to a new Movie struct.
 	team := &data.Team{
-		Name:     input.Name,
-		Location: input.Location,
-		Stadium:  input.Stadium,
-		History:  input.History,
+		Name:      input.Name,
+		Location:  input.Location,
+		StadiumID: input.StadiumID,
+		History:   input.History,
 	}
 	// Initialize a new Validator.
 	v := validator.New()
@@ -40,14 +42,21 @@ func (app *application) createTeamsHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Teams.Insert(team)
+	err = app.models.Teams.Insert(team, app.contextGetUser(r).UserID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrUnknownStadium):
+			v.AddError("stadium_id", "must be an existing stadium")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/v1/teams/%d", team.ID))
+	headers.Set("ETag", teamETag(team))
 
 	// Write a JSON response with a 201 Created status code, the movie data in the
 	// response body, and the Location header.
@@ -76,7 +85,10 @@ func (app *application) showTeamsHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, nil)
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -101,13 +113,19 @@ func (app *application) updateTeamsHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
+	// If the client sent an If-Match header, only go ahead if it names the version we
+	// just read. Otherwise the client is working from a stale copy of the team.
+	if !ifMatch(r, teamETag(team)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
 
 	// Declare an input struct to hold the expected data from the client.
 	var input struct {
-		Name     string `json:"name"`
-		Location string `json:"location"`
-		Stadium  string `json:"stadium"`
-		History  string `json:"history"`
+		Name      string `json:"name"`
+		Location  string `json:"location"`
+		StadiumID int64  `json:"stadium_id"`
+		History   string `json:"history"`
 	}
 	// Read the JSON request body data into the input struct.
 	err = app.readJSON(w, r, &input)
@@ -119,7 +137,7 @@ func (app *application) updateTeamsHandler(w http.ResponseWriter, r *http.Reques
 	// record.
 	team.Name = input.Name
 	team.Location = input.Location
-	team.Stadium = input.Stadium
+	team.StadiumID = input.StadiumID
 	team.History = input.History
 	// Validate the updated movie record, sending the client a 422 Unprocessable Entity
 	// response if any checks fail.
@@ -128,19 +146,197 @@ func (app *application) updateTeamsHandler(w http.ResponseWriter, r *http.Reques
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	// Pass the updated movie record to our new Update() method.
-	err = app.models.Teams.Update(team)
+	// Pass the updated movie record to our new Update() method. Intercept any
+	// ErrEditConflict error and call the new editConflictResponse() helper.
+	err = app.models.Teams.Update(team, app.contextGetUser(r).UserID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrUnknownStadium):
+			v.AddError("stadium_id", "must be an existing stadium")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
 	// Write the updated movie record in a JSON response.
-	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// patchTeamsHandler applies a partial update to a team. The body is either a JSON Merge
+// Patch (RFC 7396) or a JSON Patch (RFC 6902), depending on its Content-Type.
+func (app *application) patchTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !ifMatch(r, teamETag(team)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// Both patch formats work on the JSON representation of the team, so that's what
+	// we apply them to. Only the fields a client can see can be patched.
+	js, err := json.Marshal(team)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	var doc any
+	err = json.Unmarshal(js, &doc)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchMediaType, "application/json":
+		var patch map[string]any
+		err = app.readJSON(w, r, &patch)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		doc = applyMergePatch(doc, patch)
+	case jsonPatchMediaType:
+		var ops []patchOperation
+		err = app.readJSON(w, r, &ops)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		doc, err = applyJSONPatch(doc, ops)
+		if err != nil {
+			var patchErr *patchError
+			switch {
+			case errors.As(err, &patchErr):
+				app.patchFailedResponse(w, r, patchErr)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	default:
+		app.unsupportedMediaTypeResponse(w, r, "Accept-Patch", mergePatchMediaType, jsonPatchMediaType)
+		return
+	}
+
+	// Copy the patched fields back onto the team and validate the result exactly as
+	// a PUT would be validated.
+	v := validator.New()
+	if patchedTeam(v, team, doc); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if data.ValidateTeam(v, team); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Teams.Update(team, app.contextGetUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrUnknownStadium):
+			v.AddError("stadium_id", "must be an existing stadium")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// patchedTeam copies the editable fields of a patched JSON document onto team. The id
+// and version are read-only, and fields that aren't part of a team are rejected rather
+// than silently ignored.
+func patchedTeam(v *validator.Validator, team *data.Team, doc any) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		v.AddError("team", "must be a JSON object")
+		return
+	}
+
+	editable := map[string]*string{
+		"name":     &team.Name,
+		"location": &team.Location,
+		"history":  &team.History,
+	}
+
+	// Like the other fields, a stadium_id removed by the patch is as good as empty.
+	team.StadiumID = 0
+
+	for key, value := range obj {
+		switch key {
+		case "id":
+			v.Check(value == float64(team.ID), "id", "must not be changed")
+		case "version":
+			v.Check(value == float64(team.Version), "version", "must not be changed")
+		case "stadium":
+			// Derived from stadium_id; a changed name is ignored.
+		case "crest_url", "crest_urls":
+			// Crests are changed through PUT /v1/teams/:id/crest.
+		case "stadium_id":
+			n, ok := value.(float64)
+			if !ok || n != float64(int64(n)) {
+				v.AddError(key, "must be an integer")
+				continue
+			}
+			team.StadiumID = int64(n)
+		default:
+			field, ok := editable[key]
+			if !ok {
+				v.AddError(key, "is not a team field")
+				continue
+			}
+			s, ok := value.(string)
+			if !ok {
+				v.AddError(key, "must be a string")
+				continue
+			}
+			*field = s
+		}
+	}
+
+	// A field removed by the patch is as good as empty, which ValidateTeam() rejects.
+	for key, field := range editable {
+		if _, ok := obj[key]; !ok {
+			*field = ""
+		}
+	}
+}
+
 func (app *application) deleteTeamsHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract the movie ID from the URL.
 	id, err := app.readIDParam(r)
@@ -150,7 +346,7 @@ func (app *application) deleteTeamsHandler(w http.ResponseWriter, r *http.Reques
 	}
 	// Delete the movie from the database, sending a 404 Not Found response to the
 	// client if there isn't a matching record.
-	err = app.models.Teams.Delete(id)
+	err = app.models.Teams.Delete(id, app.contextGetUser(r).UserID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -167,34 +363,227 @@ func (app *application) deleteTeamsHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-//func (app *application) listTeamsHandler(w http.ResponseWriter, r *http.Request) {
-//	var input struct {
-//		Name string
-//		data.Filters
-//	}
-//	v := validator.New()
-//	qs := r.URL.Query()
-//	input.Name = app.readString(qs, "name", "")
-//	input.Filters.Page = app.readInt(qs, "page", 1, v)
-//	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-//	input.Filters.Sort = app.readString(qs, "sort", "id")
-//	input.Filters.SortSafelist = []string{"id", "name", "location", "stadium", "history", "-id", "-name", "-location", "-stadium", "-history"}
-//	if data.ValidateFilters(v, input.Filters); !v.Valid() {
-//		app.failedValidationResponse(w, r, v.Errors)
-//		return
-//	}
-//	// Call the GetAll() method to retrieve the movies, passing in the various filter
-//	// parameters.
-//	teams, err := app.models.Teams.GetAll(input.Name, input.Filters)
-//	if err != nil {
-//		app.serverErrorResponse(w, r, err)
-//		return
-//	}
-//
-//	// Send a JSON response containing the movie data.
-//	err = app.writeJSON(w, http.StatusOK, envelope{"teams": teams}, nil)
-//	if err != nil {
-//		app.serverErrorResponse(w, r, err)
-//	}
-//
-//}
+func (app *application) listTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	// To keep things consistent with our other handlers, we'll define an input struct
+	// to hold the expected values from the request query string.
+	var input struct {
+		Name     string
+		Location string
+		Stadium  string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	// Use our helpers to extract the name, location and stadium filters, falling back
+	// to an empty string (which matches every team) if they aren't provided.
+	input.Name = app.readString(qs, "name", "")
+	input.Location = app.readString(qs, "location", "")
+	input.Stadium = app.readString(qs, "stadium", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "location", "stadium", "-id", "-name", "-location", "-stadium"}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	// Call the GetAll() method to retrieve the teams, passing in the various filter
+	// parameters.
+	teams, metadata, err := app.models.Teams.GetAll(input.Name, input.Location, input.Stadium, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Send a JSON response containing the team data and the pagination metadata.
+	err = app.writeJSON(w, http.StatusOK, envelope{"teams": teams, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// searchTeamsHandler runs a ranked full-text search over the teams. The q parameter
+// accepts web-search syntax: "quoted phrases", -exclusions and OR.
+func (app *application) searchTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Query = app.readString(qs, "q", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Search results are always ordered by rank, so that's the only sort value we
+	// accept.
+	input.Filters.Sort = "rank"
+	input.Filters.SortSafelist = []string{"rank"}
+
+	v.Check(input.Query != "", "q", "must be provided")
+	v.Check(len(input.Query) <= 200, "q", "must not be more than 200 bytes long")
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, metadata, err := app.models.Teams.Search(input.Query, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTrashedTeamsHandler lists the soft-deleted teams that haven't been purged yet.
+func (app *application) listTrashedTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:     app.readInt(qs, "page", 1, v),
+		PageSize: app.readInt(qs, "page_size", 20, v),
+		// The trash is always ordered by deletion time.
+		Sort:         "deleted_at",
+		SortSafelist: []string{"deleted_at"},
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	teams, metadata, err := app.models.Teams.GetTrash(filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"teams": teams, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreTeamHandler takes a team out of the trash.
+func (app *application) restoreTeamHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Restore(id, app.contextGetUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTeamRevisionsHandler shows a team's revision history, newest first, with the
+// fields each revision changed.
+func (app *application) listTeamRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters := data.Filters{
+		Page:         app.readInt(qs, "page", 1, v),
+		PageSize:     app.readInt(qs, "page_size", 20, v),
+		Sort:         "-revision",
+		SortSafelist: []string{"-revision"},
+	}
+	if data.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Teams.GetRevisions(id, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	// Every team has at least the revision that created it, so no revisions at all
+	// means there is no such team.
+	if metadata.TotalRecords == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertTeamHandler rolls a team back to the state it was in after an earlier
+// revision. The revert is itself recorded as a new revision.
+func (app *application) revertTeamHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	revision, err := app.readRevisionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	team, err := app.models.Teams.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !ifMatch(r, teamETag(team)) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	team, err = app.models.Teams.Revert(id, team.Version, revision, app.contextGetUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrUnknownStadium):
+			app.failedValidationResponse(w, r, map[string]string{"stadium_id": "the stadium of this revision no longer exists"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", teamETag(team))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"team": team}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func (app *application) createTransferWindowHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name     string    `json:"name"`
+		OpensOn  data.Date `json:"opens_on"`
+		ClosesOn data.Date `json:"closes_on"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	window := &data.TransferWindow{
+		Name:     input.Name,
+		OpensOn:  input.OpensOn,
+		ClosesOn: input.ClosesOn,
+	}
+
+	v := validator.New()
+	if data.ValidateTransferWindow(v, window); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Transfers.InsertWindow(window)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTransferWindow):
+			v.AddError("name", "a transfer window with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrOverlappingTransferWindow):
+			v.AddError("opens_on", "must not overlap another transfer window")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"transfer_window": window}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTransferWindowsHandler(w http.ResponseWriter, r *http.Request) {
+	windows, err := app.models.Transfers.GetWindows()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transfer_windows": windows}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createTransferHandler records a transfer and moves the player to their new club.
+// from_team_id is optional, and checked against the player's club if it is given.
+// Transfers have to fall inside a transfer window unless exceptional is set.
+func (app *application) createTransferHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PlayerID    int64     `json:"player_id"`
+		FromTeamID  int64     `json:"from_team_id"`
+		ToTeamID    int64     `json:"to_team_id"`
+		Type        string    `json:"type"`
+		Fee         *int64    `json:"fee"`
+		Currency    *string   `json:"currency"`
+		Date        data.Date `json:"date"`
+		Exceptional bool      `json:"exceptional"`
+		ShirtNumber int       `json:"shirt_number"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	transfer := &data.Transfer{
+		PlayerID:    input.PlayerID,
+		FromTeamID:  input.FromTeamID,
+		ToTeamID:    input.ToTeamID,
+		Type:        input.Type,
+		Fee:         input.Fee,
+		Currency:    input.Currency,
+		Date:        input.Date,
+		Exceptional: input.Exceptional,
+	}
+
+	v := validator.New()
+	// The player keeps their shirt number at the new club unless given a new one.
+	if input.ShirtNumber != 0 {
+		v.Check(input.ShirtNumber >= 1 && input.ShirtNumber <= 99, "shirt_number", "must be between 1 and 99")
+	}
+	if data.ValidateTransfer(v, transfer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Transfers.Insert(transfer, input.ShirtNumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("player_id", "player does not exist")
+		case errors.Is(err, data.ErrUnknownTeam):
+			v.AddError("to_team_id", "team does not exist")
+		case errors.Is(err, data.ErrWrongFromTeam):
+			v.AddError("from_team_id", "must be the player's current team")
+		case errors.Is(err, data.ErrTransferToSameTeam):
+			v.AddError("to_team_id", "must not be the player's current team")
+		case errors.Is(err, data.ErrTransferOutOfOrder):
+			v.AddError("date", "must be after the player joined their current team")
+		case errors.Is(err, data.ErrOutsideTransferWindow):
+			v.AddError("date", "must be inside a transfer window, unless the transfer is exceptional")
+		case errors.Is(err, data.ErrDuplicateShirtNumber):
+			v.AddError("shirt_number", "is already taken at the new team; give the player a new one")
+		default:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/transfers/%d", transfer.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"transfer": transfer}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	transfer, err := app.models.Transfers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transfer": transfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTransfersHandler lists transfers, optionally only those of a player, to or from
+// a team, in a transfer window, of a type, or between two dates (both inclusive).
+func (app *application) listTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.TransferFilter
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.PlayerID = int64(app.readInt(qs, "player_id", 0, v))
+	input.TeamID = int64(app.readInt(qs, "team_id", 0, v))
+	input.WindowID = int64(app.readInt(qs, "window_id", 0, v))
+	input.Type = app.readString(qs, "type", "")
+	input.From = app.readDate(qs, "from", time.UTC, v)
+	input.To = app.readDate(qs, "to", time.UTC, v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-date")
+	input.Filters.SortSafelist = []string{"id", "date", "fee", "-id", "-date", "-fee"}
+
+	if input.Type != "" {
+		v.Check(validator.PermittedValue(input.Type, data.TransferTypes...), "type", "must be one of permanent, loan or free")
+	}
+	if !input.To.IsZero() {
+		v.Check(input.From.IsZero() || !input.To.Before(input.From), "to", "must not be before from")
+	}
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transfers, metadata, err := app.models.Transfers.GetAll(input.TransferFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transfers": transfers, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,110 @@
+// Package blob stores binary objects, such as uploaded images, under string keys.
+// Handlers depend on the Store interface, so the local filesystem store can be
+// swapped for object storage without touching them.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	// ErrNotFound is returned when there is no object with the given key.
+	ErrNotFound = errors.New("blob not found")
+	// ErrInvalidKey is returned for keys that aren't made up of lowercase letters,
+	// digits, dots, dashes and underscores.
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// keyRX matches the keys a Store accepts. Keys can't contain slashes or start with a
+// dot, so they can be used as file names as they are.
+var keyRX = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,127}$`)
+
+// ValidKey reports whether key can be used with a Store.
+func ValidKey(key string) bool {
+	return keyRX.MatchString(key)
+}
+
+// A Store holds objects by key. Put replaces any object already stored under the
+// key, and Delete of a key that doesn't exist is not an error.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FileStore keeps each object as a file in a directory on the local filesystem.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore for dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes the object to a temporary file first and renames it into place, so
+// readers never see a partly written object.
+func (s *FileStore) Put(ctx context.Context, key string, r io.Reader) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(s.dir, key))
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(filepath.Join(s.dir, key))
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	err := os.Remove(filepath.Join(s.dir, key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// Package crest checks uploaded team crests and renders the PNG variants they are
+// served in. Only the standard library is used, so SVG crests, which it can't
+// rasterise, are checked for active content and served as they are at every size.
+package crest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Sizes are the widths, in pixels, of the square PNG variants rendered for each
+// crest.
+var Sizes = []int{64, 128, 256}
+
+// Raster crests must be between MinDimension and MaxDimension pixels on both sides.
+// The dimensions are checked before the image is decoded, so a small file can't
+// claim an enormous image. SVG crests can't be larger than MaxDimension on either
+// side, going by both their width and height and their viewBox.
+const (
+	MinDimension = 32
+	MaxDimension = 4096
+)
+
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatSVG  = "svg"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for anything that isn't a PNG, JPEG or SVG
+	// image, whatever the client said it was.
+	ErrUnsupportedFormat = errors.New("must be a PNG, JPEG or SVG image")
+	// ErrUnsafeSVG is returned for SVG images with scripts, event handlers or
+	// references to other documents, none of which a crest needs.
+	ErrUnsafeSVG = errors.New("must not contain scripts, event handlers or external references")
+	// ErrSVGTooLarge is returned for SVG images wider or higher than MaxDimension.
+	ErrSVGTooLarge = fmt.Errorf("must not be more than %d pixels wide or high", MaxDimension)
+)
+
+// A DimensionError is returned for raster images that are too small or too large.
+type DimensionError struct {
+	Width, Height int
+}
+
+func (e *DimensionError) Error() string {
+	return fmt.Sprintf("must be between %d and %d pixels wide and high, not %dx%d", MinDimension, MaxDimension, e.Width, e.Height)
+}
+
+// An Image is a checked crest. Variants holds the PNG rendition for each of the Sizes,
+// and is empty for SVG crests.
+type Image struct {
+	Format   string
+	Variants map[int][]byte
+}
+
+// Extension returns the file extension for the crest as uploaded.
+func (img *Image) Extension() string {
+	if img.Format == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + img.Format
+}
+
+// Key returns the blob key under which the crest of a team is stored as uploaded. It
+// includes a hash of the content, so a new crest always gets a new key and clients
+// can cache crests forever.
+func (img *Image) Key(teamID int64, data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%d-%s%s", teamID, hex.EncodeToString(sum[:6]), img.Extension())
+}
+
+// VariantKey returns the blob key of the PNG variant of the given size for the crest
+// stored under key. SVG crests have no variants, so their own key is returned.
+func VariantKey(key string, size int) string {
+	if strings.HasSuffix(key, "."+FormatSVG) {
+		return key
+	}
+	return fmt.Sprintf("%s-%d.png", strings.TrimSuffix(key, path.Ext(key)), size)
+}
+
+// Process works out the format of an upload from its content, checks it and renders
+// the variants.
+func Process(data []byte) (*Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return processRaster(FormatPNG, data, png.DecodeConfig, png.Decode)
+	case "image/jpeg":
+		return processRaster(FormatJPEG, data, jpeg.DecodeConfig, jpeg.Decode)
+	}
+
+	err := checkSVG(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Format: FormatSVG, Variants: map[int][]byte{}}, nil
+}
+
+func processRaster(format string, data []byte, decodeConfig func(io.Reader) (image.Config, error), decode func(io.Reader) (image.Image, error)) (*Image, error) {
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width < MinDimension || config.Height < MinDimension || config.Width > MaxDimension || config.Height > MaxDimension {
+		return nil, &DimensionError{Width: config.Width, Height: config.Height}
+	}
+
+	src, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	// Work in premultiplied RGBA, which can be averaged directly.
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	img := &Image{Format: format, Variants: make(map[int][]byte, len(Sizes))}
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		err := encoder.Encode(&buf, fitSquare(rgba, size))
+		if err != nil {
+			return nil, err
+		}
+		img.Variants[size] = buf.Bytes()
+	}
+
+	return img, nil
+}
+
+// fitSquare scales src to fit a size by size square, keeping its aspect ratio, and
+// centres it on a transparent background. Each pixel of the result is the average of
+// the source pixels it covers, which keeps detail from aliasing away when a large
+// crest is shrunk; when enlarging, the nearest source pixel is used.
+func fitSquare(src *image.RGBA, size int) *image.RGBA {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	scale := math.Min(float64(size)/float64(w), float64(size)/float64(h))
+	dw := max(1, int(math.Round(float64(w)*scale)))
+	dh := max(1, int(math.Round(float64(h)*scale)))
+	offX, offY := (size-dw)/2, (size-dh)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < dh; y++ {
+		y0, y1 := span(y, scale, h)
+		for x := 0; x < dw; x++ {
+			x0, x1 := span(x, scale, w)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := src.PixOffset(sx, sy)
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					b += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(offX+x, offY+y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of source pixels covered by destination pixel d, which is
+// never empty.
+func span(d int, scale float64, limit int) (int, int) {
+	lo := int(float64(d) / scale)
+	hi := int(math.Ceil(float64(d+1) / scale))
+	lo = min(lo, limit-1)
+	hi = max(lo+1, min(hi, limit))
+	return lo, hi
+}
+
+// checkSVG makes sure data is an SVG document without active content. Browsers run
+// scripts in SVG images opened directly, so scripts, event handler attributes,
+// foreignObject and links to anything outside the document are all rejected, as are
+// DTDs, which can declare entities. Style sheets and style attributes may only refer
+// to the document itself, see safeCSS().
+func checkSVG(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = true
+
+	root := true
+	inStyle := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ErrUnsupportedFormat
+		}
+
+		switch tok := tok.(type) {
+		case xml.Directive:
+			return ErrUnsafeSVG
+		case xml.StartElement:
+			name := strings.ToLower(tok.Name.Local)
+			if root {
+				if name != "svg" {
+					return ErrUnsupportedFormat
+				}
+				err := checkSVGSize(tok.Attr)
+				if err != nil {
+					return err
+				}
+				root = false
+			}
+			if name == "script" || name == "foreignobject" {
+				return ErrUnsafeSVG
+			}
+			inStyle = name == "style"
+			for _, attr := range tok.Attr {
+				attrName := strings.ToLower(attr.Name.Local)
+				if strings.HasPrefix(attrName, "on") {
+					return ErrUnsafeSVG
+				}
+				if attrName == "href" && !strings.HasPrefix(strings.TrimSpace(attr.Value), "#") {
+					return ErrUnsafeSVG
+				}
+				// Presentation attributes such as fill take url() references too.
+				if (attrName == "style" || strings.Contains(strings.ToLower(attr.Value), "url(")) && !safeCSS(attr.Value) {
+					return ErrUnsafeSVG
+				}
+			}
+		case xml.EndElement:
+			inStyle = false
+		case xml.CharData:
+			if inStyle && !safeCSS(string(tok)) {
+				return ErrUnsafeSVG
+			}
+		}
+	}
+
+	if root {
+		return ErrUnsupportedFormat
+	}
+	return nil
+}
+
+// safeCSS reports whether a style sheet or attribute only refers to things inside the
+// document: url() references must be to a fragment, and @import and image-set(),
+// which load other documents, aren't allowed at all. Nor are CSS escapes, which
+// could spell any of them.
+func safeCSS(css string) bool {
+	css = strings.ToLower(css)
+	for _, banned := range []string{"@import", "image-set(", `\`} {
+		if strings.Contains(css, banned) {
+			return false
+		}
+	}
+
+	for {
+		i := strings.Index(css, "url(")
+		if i < 0 {
+			return true
+		}
+		css = strings.TrimLeft(css[i+len("url("):], " \t\r\n\f'\"")
+		if !strings.HasPrefix(css, "#") {
+			return false
+		}
+	}
+}
+
+// svgUnits converts the absolute units an SVG's width and height can be given in to
+// pixels.
+var svgUnits = map[string]float64{"": 1, "px": 1, "pt": 4.0 / 3, "pc": 16, "in": 96, "cm": 96 / 2.54, "mm": 96 / 25.4}
+
+// checkSVGSize checks the width, height and viewBox attributes of an SVG's root
+// element against MaxDimension. Relative widths and heights, in percent, leave the
+// size to the viewBox.
+func checkSVGSize(attrs []xml.Attr) error {
+	for _, attr := range attrs {
+		switch strings.ToLower(attr.Name.Local) {
+		case "width", "height":
+			value := strings.ToLower(strings.TrimSpace(attr.Value))
+			if strings.HasSuffix(value, "%") {
+				continue
+			}
+			number := strings.TrimRight(value, "abcdefghijklmnopqrstuvwxyz")
+			scale, ok := svgUnits[value[len(number):]]
+			n, err := strconv.ParseFloat(number, 64)
+			if !ok || err != nil || math.IsNaN(n) || n < 0 {
+				return ErrUnsupportedFormat
+			}
+			if n*scale > MaxDimension {
+				return ErrSVGTooLarge
+			}
+		case "viewbox":
+			fields := strings.FieldsFunc(attr.Value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+			if len(fields) != 4 {
+				return ErrUnsupportedFormat
+			}
+			for i, field := range fields {
+				n, err := strconv.ParseFloat(field, 64)
+				if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || (i >= 2 && n <= 0) {
+					return ErrUnsupportedFormat
+				}
+				if i >= 2 && n > MaxDimension {
+					return ErrSVGTooLarge
+				}
+			}
+		}
+	}
+
+	return nil
+}
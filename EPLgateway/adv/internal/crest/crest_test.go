@@ -0,0 +1,124 @@
+package crest_test
+
+import (
+	"adv.erakaisar.net/internal/crest"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess_PNG(t *testing.T) {
+	data := encodePNG(t, 400, 200)
+
+	img, err := crest.Process(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if img.Format != crest.FormatPNG {
+		t.Errorf("expected png, got %s", img.Format)
+	}
+
+	for _, size := range crest.Sizes {
+		variant, err := png.Decode(bytes.NewReader(img.Variants[size]))
+		if err != nil {
+			t.Fatalf("variant %d: %s", size, err)
+		}
+		if b := variant.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Errorf("variant %d is %dx%d", size, b.Dx(), b.Dy())
+		}
+
+		// A wide crest is letterboxed: the middle is the crest's colour, the top
+		// is transparent.
+		if _, _, _, a := variant.At(size/2, 0).RGBA(); a != 0 {
+			t.Errorf("variant %d: expected a transparent top row", size)
+		}
+		if r, _, _, a := variant.At(size/2, size/2).RGBA(); a != 0xffff || r>>8 != 200 {
+			t.Errorf("variant %d: unexpected centre colour %v", size, variant.At(size/2, size/2))
+		}
+	}
+}
+
+func TestProcess_JPEGDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = crest.Process(buf.Bytes())
+
+	var dimensionError *crest.DimensionError
+	if !errors.As(err, &dimensionError) {
+		t.Fatalf("expected a DimensionError, got %v", err)
+	}
+}
+
+func TestProcess_SVG(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		err  error
+	}{
+		{"plain", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><circle cx="5" cy="5" r="4"/></svg>`, nil},
+		{"internal link", `<svg xmlns="http://www.w3.org/2000/svg"><defs><path id="p"/></defs><use href="#p"/></svg>`, nil},
+		{"script", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, crest.ErrUnsafeSVG},
+		{"event handler", `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`, crest.ErrUnsafeSVG},
+		{"external link", `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><image xlink:href="http://example.com/x.png"/></svg>`, crest.ErrUnsafeSVG},
+		{"doctype", `<!DOCTYPE svg [<!ENTITY x "y">]><svg xmlns="http://www.w3.org/2000/svg"></svg>`, crest.ErrUnsafeSVG},
+		{"gradient", `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256"><defs><linearGradient id="g"/></defs><circle r="4" fill="url(#g)" style="stroke: url( '#g' )"/></svg>`, nil},
+		{"style sheet url", `<svg xmlns="http://www.w3.org/2000/svg"><style>circle { fill: url(http://example.com/x.svg#g) }</style></svg>`, crest.ErrUnsafeSVG},
+		{"style sheet import", `<svg xmlns="http://www.w3.org/2000/svg"><style><![CDATA[@import "http://example.com/x.css";]]></style></svg>`, crest.ErrUnsafeSVG},
+		{"style attribute url", `<svg xmlns="http://www.w3.org/2000/svg"><circle style="fill: URL(//example.com/x.svg)"/></svg>`, crest.ErrUnsafeSVG},
+		{"escaped url", `<svg xmlns="http://www.w3.org/2000/svg"><circle style="fill: u\72l(//example.com/x.svg)"/></svg>`, crest.ErrUnsafeSVG},
+		{"presentation attribute url", `<svg xmlns="http://www.w3.org/2000/svg"><circle filter="url(https://example.com/f.svg#f)"/></svg>`, crest.ErrUnsafeSVG},
+		{"too wide", `<svg xmlns="http://www.w3.org/2000/svg" width="5000" height="10"></svg>`, crest.ErrSVGTooLarge},
+		{"too high in inches", `<svg xmlns="http://www.w3.org/2000/svg" height="50in"></svg>`, crest.ErrSVGTooLarge},
+		{"large viewBox", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100000 100000"></svg>`, crest.ErrSVGTooLarge},
+		{"bad viewBox", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10"></svg>`, crest.ErrUnsupportedFormat},
+		{"html", `<html><body></body></html>`, crest.ErrUnsupportedFormat},
+		{"text", `not an image`, crest.ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := crest.Process([]byte(tt.svg))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if err == nil && img.Format != crest.FormatSVG {
+				t.Errorf("expected svg, got %s", img.Format)
+			}
+		})
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	if got := crest.VariantKey("3-0a1b2c3d4e5f.jpg", 128); got != "3-0a1b2c3d4e5f-128.png" {
+		t.Errorf("unexpected raster variant key %q", got)
+	}
+	if got := crest.VariantKey("3-0a1b2c3d4e5f.svg", 128); got != "3-0a1b2c3d4e5f.svg" {
+		t.Errorf("unexpected SVG variant key %q", got)
+	}
+}
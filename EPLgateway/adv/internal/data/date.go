@@ -0,0 +1,33 @@
+package data
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidDateFormat is returned when a Date can't be parsed from JSON.
+var ErrInvalidDateFormat = errors.New(`invalid date format, expected "YYYY-MM-DD"`)
+
+// Date is a calendar date with no time of day, such as a date of birth. It is encoded
+// in JSON as "YYYY-MM-DD".
+type Date time.Time
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Time(d).Format(time.DateOnly))), nil
+}
+
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	t, err := time.Parse(time.DateOnly, unquotedJSONValue)
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	*d = Date(t)
+	return nil
+}
@@ -0,0 +1,402 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// feedBatchSize is the number of items read from the database at a time.
+const feedBatchSize = 100
+
+// feedBufferSize is the number of items a stream can fall behind the reader of its
+// topic by, a couple of batches. A stream that falls further behind is cut off and
+// catches up from the database on its own, so that one slow client can't hold up the
+// others or make the hub buffer without limit.
+const feedBufferSize = 2 * feedBatchSize
+
+// errFeedLagged is how a stream learns that it has been cut off for falling behind.
+var errFeedLagged = errors.New("stream fell behind its feed")
+
+// A feedHub hands the items committed to a feed, such as the team events, to the
+// streams following it. Topics split a feed up, by match for example; feeds that
+// aren't split up use topic 0. Each topic that is being followed has a single reader,
+// which reads whatever is new from the database when it is woken and passes it on to
+// every stream of the topic, so a commit costs one query however many clients are
+// following. Wake-ups carry no items, so they can be merged or come from another
+// process without anything being lost. channel is the name the feed is notified under.
+type feedHub struct {
+	channel string
+	mu      sync.Mutex
+	topics  map[int64]*feedTopic
+}
+
+// A feedTopic is the reader of one topic and the streams it serves. cursor is the ID
+// of the last item the reader has passed on; it is set, or err is, when ready is
+// closed.
+type feedTopic struct {
+	wake        chan struct{}
+	ready       chan struct{}
+	cursor      int64
+	err         error
+	subscribers map[*feedSubscriber]struct{}
+	stop        context.CancelFunc
+}
+
+// A feedSubscriber is a stream's place in a topic. The reader fills items; if it cuts
+// the stream off, it sets err and closes done.
+type feedSubscriber struct {
+	items chan feedItem
+	done  chan struct{}
+	err   error
+}
+
+type feedItem struct {
+	id    int64
+	value any
+}
+
+func newFeedHub(channel string) *feedHub {
+	return &feedHub{channel: channel, topics: make(map[int64]*feedTopic)}
+}
+
+// wake never blocks: a reader that hasn't got round to reading since its last
+// wake-up reads everything new in one go anyway.
+func (h *feedHub) wake(topics ...int64) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range topics {
+		if t, ok := h.topics[topic]; ok {
+			wakeTopic(t)
+		}
+	}
+}
+
+// wakeAll wakes up every reader, for when notifications may have been missed.
+func (h *feedHub) wakeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, t := range h.topics {
+		wakeTopic(t)
+	}
+}
+
+func wakeTopic(t *feedTopic) {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// join adds a subscriber to a topic and returns the ID after which the topic's reader
+// passes everything on to it; anything before that is for the caller to read from the
+// database. The first subscriber of a topic starts its reader, from the latest item in
+// the feed. A model built without a hub is never woken: its subscribers receive
+// nothing.
+func (h *feedHub) join(topic int64, getAfter func(cursor int64, limit int) ([]feedItem, error), latestID func() (int64, error)) (*feedSubscriber, int64, error) {
+	if h == nil {
+		cursor, err := latestID()
+		return &feedSubscriber{}, cursor, err
+	}
+
+	sub := &feedSubscriber{items: make(chan feedItem, feedBufferSize), done: make(chan struct{})}
+
+	h.mu.Lock()
+	t, ok := h.topics[topic]
+	if !ok {
+		t = &feedTopic{
+			wake:        make(chan struct{}, 1),
+			ready:       make(chan struct{}),
+			subscribers: make(map[*feedSubscriber]struct{}),
+		}
+		h.topics[topic] = t
+	}
+	t.subscribers[sub] = struct{}{}
+	// If the reader is already running, the subscriber gets whatever it reads next.
+	var cursor int64
+	running := false
+	select {
+	case <-t.ready:
+		cursor, running = t.cursor, true
+	default:
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		h.start(topic, t, getAfter, latestID)
+	}
+	if !running {
+		<-t.ready
+		h.mu.Lock()
+		cursor = t.cursor
+		h.mu.Unlock()
+	}
+
+	if t.err != nil {
+		return nil, 0, t.err
+	}
+	return sub, cursor, nil
+}
+
+func (h *feedHub) start(topic int64, t *feedTopic, getAfter func(cursor int64, limit int) ([]feedItem, error), latestID func() (int64, error)) {
+	// Anything committed from here on wakes the reader, since the topic is already in
+	// the hub, and gets an ID after latest.
+	latest, err := latestID()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	defer close(t.ready)
+
+	if err != nil {
+		t.err = err
+		delete(h.topics, topic)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cursor, t.stop = latest, cancel
+	go h.read(ctx, topic, t, getAfter)
+}
+
+// read is the reader of a topic. A subscriber whose buffer is full is cut off rather
+// than waited for. If the database can't be read, every stream of the topic ends with
+// the error, and the next one to join starts a new reader.
+func (h *feedHub) read(ctx context.Context, topic int64, t *feedTopic, getAfter func(cursor int64, limit int) ([]feedItem, error)) {
+	cursor := t.cursor
+
+	for {
+		select {
+		case <-t.wake:
+		case <-ctx.Done():
+			return
+		}
+
+		for {
+			batch, err := getAfter(cursor, feedBatchSize)
+
+			h.mu.Lock()
+			if err != nil {
+				for sub := range t.subscribers {
+					h.cutOff(t, sub, err)
+				}
+				if h.topics[topic] == t {
+					delete(h.topics, topic)
+				}
+				h.mu.Unlock()
+				return
+			}
+			for _, item := range batch {
+				for sub := range t.subscribers {
+					select {
+					case sub.items <- item:
+					default:
+						h.cutOff(t, sub, errFeedLagged)
+					}
+				}
+				cursor = item.id
+			}
+			t.cursor = cursor
+			h.mu.Unlock()
+
+			if len(batch) < feedBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// cutOff must be called with the hub locked.
+func (h *feedHub) cutOff(t *feedTopic, sub *feedSubscriber, err error) {
+	delete(t.subscribers, sub)
+	sub.err = err
+	close(sub.done)
+}
+
+// leave removes a subscriber from a topic, and stops the topic's reader once nobody
+// is left following it.
+func (h *feedHub) leave(topic int64, sub *feedSubscriber) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	t, ok := h.topics[topic]
+	if !ok {
+		return
+	}
+	delete(t.subscribers, sub)
+	if len(t.subscribers) == 0 {
+		t.stop()
+		delete(h.topics, topic)
+	}
+}
+
+// lockFeed must be called by a transaction before it adds an item to a feed. It holds
+// the feed's lock until the transaction ends, so that items are given their IDs in
+// the order they are committed: once a stream has read up to an ID, no item with a
+// smaller one can turn up later. The lock covers the whole feed rather than the topic,
+// since the IDs are shared by every topic and a cursor such as the latest ID can come
+// from any of them. It also queues the notification that wakes up the topic's
+// streams, in every process, once the transaction commits.
+func lockFeed(ctx context.Context, tx *sql.Tx, channel string, topic int64) error {
+	query := `SELECT pg_advisory_xact_lock(hashtext($1)), pg_notify($1, $2::text)`
+
+	_, err := tx.ExecContext(ctx, query, channel, topic)
+	return err
+}
+
+// streamFeed delivers every item of a feed topic with an ID greater than cursor, or
+// from now on if cursor is negative, until ctx is cancelled or an error occurs. The
+// items the topic's reader has already passed by are read from the database first.
+// getAfter reads up to limit items after a cursor, oldest first, id returns an item's
+// ID and latestID the ID of the latest item in the feed. The items channel is closed
+// when the stream ends; if it ended because of an error, the error is sent on the
+// error channel first.
+func streamFeed[T any](ctx context.Context, hub *feedHub, topic, cursor int64, id func(T) int64, getAfter func(cursor int64, limit int) ([]T, error), latestID func() (int64, error)) (<-chan T, <-chan error) {
+	items := make(chan T)
+	errs := make(chan error, 1)
+
+	read := func(cursor int64, limit int) ([]feedItem, error) {
+		batch, err := getAfter(cursor, limit)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]feedItem, len(batch))
+		for i, item := range batch {
+			items[i] = feedItem{id: id(item), value: item}
+		}
+		return items, nil
+	}
+
+	go func() {
+		defer close(items)
+
+		sub, position, err := hub.join(topic, read, latestID)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer func() { hub.leave(topic, sub) }()
+
+		if cursor < 0 {
+			cursor = position
+		}
+
+		for {
+			for cursor < position {
+				batch, err := getAfter(cursor, feedBatchSize)
+				if err != nil {
+					errs <- err
+					return
+				}
+				for _, item := range batch {
+					select {
+					case items <- item:
+						cursor = id(item)
+					case <-ctx.Done():
+						return
+					}
+				}
+				if len(batch) < feedBatchSize {
+					break
+				}
+			}
+
+		live:
+			for {
+				select {
+				case item := <-sub.items:
+					// The reader may pass on items that were read from the database
+					// above.
+					if item.id <= cursor {
+						continue
+					}
+					select {
+					case items <- item.value.(T):
+						cursor = item.id
+					case <-ctx.Done():
+						return
+					}
+				case <-sub.done:
+					if !errors.Is(sub.err, errFeedLagged) {
+						errs <- sub.err
+						return
+					}
+					break live
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			// Cut off for falling behind: join again and catch up from the database.
+			next, nextPosition, err := hub.join(topic, read, latestID)
+			if err != nil {
+				errs <- err
+				return
+			}
+			sub, position = next, nextPosition
+		}
+	}()
+
+	return items, errs
+}
+
+// Listen wakes up the streams opened through the models whenever another process
+// sharing the database, such as another API server or the import command, commits
+// to one of their feeds. Without it streams only see the changes made through these
+// models. It runs until ctx is cancelled, reporting connection problems to logError
+// as it reconnects.
+func (m Models) Listen(ctx context.Context, dsn string, logError func(error)) error {
+	listener := pq.NewListener(dsn, 10*time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			logError(err)
+		}
+	})
+	defer listener.Close()
+
+	hubs := make(map[string]*feedHub, len(m.feeds))
+	for _, hub := range m.feeds {
+		err := listener.Listen(hub.channel)
+		if err != nil {
+			return err
+		}
+		hubs[hub.channel] = hub
+	}
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			// A nil notification means the connection was lost and re-established,
+			// and anything sent in between was missed.
+			if n == nil {
+				for _, hub := range hubs {
+					hub.wakeAll()
+				}
+				continue
+			}
+			topic, err := strconv.ParseInt(n.Extra, 10, 64)
+			if hub, ok := hubs[n.Channel]; ok && err == nil {
+				hub.wake(topic)
+			}
+		case <-time.After(90 * time.Second):
+			// Check the connection now and then, since a dead one would otherwise
+			// go unnoticed until the next notification never arrives.
+			go listener.Ping()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
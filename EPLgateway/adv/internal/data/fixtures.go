@@ -0,0 +1,168 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrSeasonHasFixtures is returned when fixtures are generated for a season that
+// already has matches between its teams.
+var ErrSeasonHasFixtures = errors.New("season already has fixtures")
+
+// A FixtureTeam is a team of a season with the stadium it plays its home matches at.
+type FixtureTeam struct {
+	TeamID    int64
+	TeamName  string
+	StadiumID int64
+	Stadium   string
+}
+
+// GetFixtureTeams lists the teams of a season with their stadiums, by team ID.
+func (m SeasonModel) GetFixtureTeams(id int64) ([]*FixtureTeam, error) {
+	query := `
+        SELECT t.id, t.name, t.stadium_id, s.name
+        FROM season_teams st
+        JOIN teams t ON t.id = st.team_id
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE st.season_id = $1
+        ORDER BY t.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*FixtureTeam{}
+	for rows.Next() {
+		var team FixtureTeam
+		err := rows.Scan(&team.TeamID, &team.TeamName, &team.StadiumID, &team.Stadium)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, &team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// InsertFixtures writes a season's fixtures in one transaction: either every match is
+// scheduled or none is. The season must be open and must not already have matches
+// between its teams, other than postponed ones, otherwise ErrSeasonClosed or
+// ErrSeasonHasFixtures is returned. Each match goes through the same double-booking
+// check as Insert(), so a team's cup match on a matchday is reported as a
+// DoubleBookingError.
+func (m MatchModel) InsertFixtures(seasonID int64, matches []*Match) error {
+	if seasonID < 1 {
+		return ErrRecordNotFound
+	}
+
+	// A whole season is a few hundred matches; allow for them.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second+time.Duration(len(matches))*20*time.Millisecond)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the season so that it can't be closed while its fixtures are written.
+	query := `
+        SELECT starts_on, ends_on, status
+        FROM seasons
+        WHERE id = $1
+        FOR SHARE`
+
+	var season Season
+	err = tx.QueryRowContext(ctx, query, seasonID).Scan(
+		(*time.Time)(&season.StartsOn),
+		(*time.Time)(&season.EndsOn),
+		&season.Status,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	if season.Status != SeasonOpen {
+		return ErrSeasonClosed
+	}
+
+	// Locking every team of the season up front, in the same order as checkBookings,
+	// stops two generations for the season from both passing the check below.
+	query = `
+        SELECT t.id
+        FROM teams t
+        JOIN season_teams st ON st.team_id = t.id
+        WHERE st.season_id = $1
+        ORDER BY t.id
+        FOR UPDATE OF t`
+
+	rows, err := tx.QueryContext(ctx, query, seasonID)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	from, to := season.Window()
+
+	query = `
+        SELECT EXISTS (
+            SELECT 1
+            FROM matches
+            WHERE home_team_id IN (SELECT team_id FROM season_teams WHERE season_id = $1)
+            AND away_team_id IN (SELECT team_id FROM season_teams WHERE season_id = $1)
+            AND kickoff >= $2 AND kickoff < $3
+            AND status <> 'postponed'
+        )`
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, query, seasonID, from, to).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrSeasonHasFixtures
+	}
+
+	query = `
+        INSERT INTO matches (home_team_id, away_team_id, kickoff, venue, status)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, version`
+
+	for _, match := range matches {
+		err = checkBookings(ctx, tx, match)
+		if err != nil {
+			return err
+		}
+
+		args := []any{match.HomeTeamID, match.AwayTeamID, match.Kickoff, match.Venue, match.Status}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&match.ID, &match.CreatedAt, &match.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m MockSeasonModel) GetFixtureTeams(id int64) ([]*FixtureTeam, error) {
+	return nil, nil
+}
+
+func (m MockMatchModel) InsertFixtures(seasonID int64, matches []*Match) error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+func TestMatchModel_InsertFixtures(t *testing.T) {
+	startsOn := time.Date(2025, time.August, 15, 0, 0, 0, 0, time.UTC)
+	endsOn := time.Date(2026, time.May, 24, 0, 0, 0, 0, time.UTC)
+	kickoff := time.Date(2025, time.August, 16, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		status  string
+		exists  bool
+		wantErr error
+	}{
+		{"written", data.SeasonOpen, false, nil},
+		{"season closed", data.SeasonClosed, false, data.ErrSeasonClosed},
+		{"already has fixtures", data.SeasonOpen, true, data.ErrSeasonHasFixtures},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			model := data.MatchModel{DB: db}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM seasons .* FOR SHARE`).WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"starts_on", "ends_on", "status"}).AddRow(startsOn, endsOn, tt.status))
+			if tt.status == data.SeasonOpen {
+				mock.ExpectQuery(`FROM teams t .* FOR UPDATE OF t`).WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery(`SELECT EXISTS`).WithArgs(1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+			}
+			if tt.wantErr == nil {
+				mock.ExpectQuery(`FROM teams .* FOR UPDATE`).WithArgs(1, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery(`FROM matches`).WillReturnRows(sqlmock.NewRows([]string{"id", "team_id"}))
+				mock.ExpectQuery(`INSERT INTO matches`).WithArgs(1, 2, kickoff, "Emirates Stadium", data.MatchScheduled).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(7, time.Now(), 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			match := &data.Match{
+				HomeTeamID: 1,
+				AwayTeamID: 2,
+				Kickoff:    kickoff,
+				Venue:      "Emirates Stadium",
+				Status:     data.MatchScheduled,
+			}
+
+			err = model.InsertFixtures(1, []*data.Match{match})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && match.ID != 7 {
+				t.Errorf("match ID = %d, want 7", match.ID)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
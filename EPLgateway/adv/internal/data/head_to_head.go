@@ -0,0 +1,170 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// recentMeetings is the number of meetings listed under "recent" in a head-to-head.
+const recentMeetings = 5
+
+// A Meeting is one finished match between the two teams of a head-to-head.
+type Meeting struct {
+	MatchID      int64     `json:"match_id"`
+	Kickoff      time.Time `json:"kickoff"`
+	Season       string    `json:"season"`
+	HomeTeamID   int64     `json:"home_team_id"`
+	HomeTeamName string    `json:"home_team_name"`
+	AwayTeamID   int64     `json:"away_team_id"`
+	AwayTeamName string    `json:"away_team_name"`
+	HomeScore    int       `json:"home_score"`
+	AwayScore    int       `json:"away_score"`
+	WinnerID     *int64    `json:"winner_id"`
+}
+
+func newMeeting(r MatchResult) *Meeting {
+	m := &Meeting{
+		MatchID:      r.MatchID,
+		Kickoff:      r.Kickoff,
+		Season:       SeasonOf(r.Kickoff).String(),
+		HomeTeamID:   r.HomeTeamID,
+		HomeTeamName: r.HomeTeamName,
+		AwayTeamID:   r.AwayTeamID,
+		AwayTeamName: r.AwayTeamName,
+		HomeScore:    r.HomeScore,
+		AwayScore:    r.AwayScore,
+	}
+	switch {
+	case r.HomeScore > r.AwayScore:
+		m.WinnerID = &m.HomeTeamID
+	case r.AwayScore > r.HomeScore:
+		m.WinnerID = &m.AwayTeamID
+	}
+	return m
+}
+
+// scoreFor returns the goals scored and conceded in the meeting by teamID.
+func (m *Meeting) scoreFor(teamID int64) (scored, conceded int) {
+	if m.HomeTeamID == teamID {
+		return m.HomeScore, m.AwayScore
+	}
+	return m.AwayScore, m.HomeScore
+}
+
+// A HeadToHeadSide is one team's record in its meetings with the other. BiggestWin is
+// nil if the team has never beaten the other, and Season is nil if the team hasn't
+// played in the current season.
+type HeadToHeadSide struct {
+	TeamID       int64     `json:"team_id"`
+	TeamName     string    `json:"team_name"`
+	Won          int       `json:"won"`
+	Drawn        int       `json:"drawn"`
+	Lost         int       `json:"lost"`
+	GoalsFor     int       `json:"goals_for"`
+	GoalsAgainst int       `json:"goals_against"`
+	BiggestWin   *Meeting  `json:"biggest_win"`
+	Season       *Standing `json:"season"`
+}
+
+// A HeadToHead compares two teams through the matches they have played against each
+// other. Meetings are listed most recent first.
+type HeadToHead struct {
+	Team     *HeadToHeadSide `json:"team"`
+	Other    *HeadToHeadSide `json:"other"`
+	Played   int             `json:"played"`
+	Goals    int             `json:"goals"`
+	Recent   []*Meeting      `json:"recent"`
+	Meetings []*Meeting      `json:"meetings"`
+}
+
+// ComputeHeadToHead builds the head-to-head between team and other from their
+// meetings, which must be in kickoff order. A team's biggest win is the one with the
+// widest margin, then the most goals scored, then the most recent.
+func ComputeHeadToHead(team, other *Team, meetings []MatchResult) *HeadToHead {
+	h := &HeadToHead{
+		Team:     &HeadToHeadSide{TeamID: team.ID, TeamName: team.Name},
+		Other:    &HeadToHeadSide{TeamID: other.ID, TeamName: other.Name},
+		Meetings: make([]*Meeting, 0, len(meetings)),
+	}
+
+	for i := len(meetings) - 1; i >= 0; i-- {
+		m := newMeeting(meetings[i])
+		h.Meetings = append(h.Meetings, m)
+		h.Played++
+		h.Goals += m.HomeScore + m.AwayScore
+
+		for _, side := range []*HeadToHeadSide{h.Team, h.Other} {
+			side.record(m)
+		}
+	}
+
+	h.Recent = h.Meetings[:min(recentMeetings, len(h.Meetings))]
+
+	return h
+}
+
+func (s *HeadToHeadSide) record(m *Meeting) {
+	scored, conceded := m.scoreFor(s.TeamID)
+	s.GoalsFor += scored
+	s.GoalsAgainst += conceded
+
+	switch {
+	case scored > conceded:
+		s.Won++
+		// Meetings are recorded most recent first, so an equal win doesn't replace
+		// the one already found.
+		if s.BiggestWin == nil {
+			s.BiggestWin = m
+			return
+		}
+		best, bestConceded := s.BiggestWin.scoreFor(s.TeamID)
+		margin, bestMargin := scored-conceded, best-bestConceded
+		if margin > bestMargin || margin == bestMargin && scored > best {
+			s.BiggestWin = m
+		}
+	case scored == conceded:
+		s.Drawn++
+	default:
+		s.Lost++
+	}
+}
+
+// SetSeason fills in each side's row of a league table, leaving it nil for a team
+// that isn't in the table.
+func (h *HeadToHead) SetSeason(standings []*Standing) {
+	for _, s := range standings {
+		switch s.TeamID {
+		case h.Team.TeamID:
+			h.Team.Season = s
+		case h.Other.TeamID:
+			h.Other.Season = s
+		}
+	}
+}
+
+// GetMeetings returns every finished match between the two teams, whichever of them
+// was at home, in kickoff order.
+func (m MatchModel) GetMeetings(teamID, otherID int64) ([]MatchResult, error) {
+	query := `
+        SELECT m.id, m.kickoff, m.home_team_id, h.name, m.away_team_id, a.name, m.home_score, m.away_score
+        FROM matches m
+        JOIN teams h ON h.id = m.home_team_id
+        JOIN teams a ON a.id = m.away_team_id
+        WHERE m.status = 'finished'
+        AND ((m.home_team_id = $1 AND m.away_team_id = $2) OR (m.home_team_id = $2 AND m.away_team_id = $1))
+        ORDER BY m.kickoff, m.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, teamID, otherID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanResults(rows)
+}
+
+func (m MockMatchModel) GetMeetings(teamID, otherID int64) ([]MatchResult, error) {
+	return nil, nil
+}
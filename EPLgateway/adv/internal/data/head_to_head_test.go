@@ -0,0 +1,79 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"testing"
+	"time"
+)
+
+func TestComputeHeadToHead(t *testing.T) {
+	arsenal := &data.Team{ID: 1, Name: "Arsenal"}
+	chelsea := &data.Team{ID: 2, Name: "Chelsea"}
+
+	kickoff := time.Date(2018, time.August, 18, 15, 0, 0, 0, time.UTC)
+	meeting := func(id int64, year int, home, away int64, homeScore, awayScore int) data.MatchResult {
+		names := map[int64]string{1: "Arsenal", 2: "Chelsea"}
+		return data.MatchResult{
+			MatchID:      id,
+			Kickoff:      kickoff.AddDate(year, 0, 0),
+			HomeTeamID:   home,
+			HomeTeamName: names[home],
+			AwayTeamID:   away,
+			AwayTeamName: names[away],
+			HomeScore:    homeScore,
+			AwayScore:    awayScore,
+		}
+	}
+
+	// Arsenal's 3-1 and 2-0 wins have the same margin; the 3-1 scored more.
+	meetings := []data.MatchResult{
+		meeting(1, 0, 1, 2, 3, 1),
+		meeting(2, 1, 2, 1, 0, 2),
+		meeting(3, 2, 1, 2, 2, 2),
+		meeting(4, 3, 2, 1, 1, 0),
+		meeting(5, 4, 1, 2, 0, 1),
+		meeting(6, 5, 2, 1, 1, 1),
+	}
+
+	h := data.ComputeHeadToHead(arsenal, chelsea, meetings)
+
+	if h.Played != 6 || h.Goals != 14 {
+		t.Errorf("played %d, goals %d; want 6 and 14", h.Played, h.Goals)
+	}
+	a, c := h.Team, h.Other
+	if a.Won != 2 || a.Drawn != 2 || a.Lost != 2 || a.GoalsFor != 8 || a.GoalsAgainst != 6 {
+		t.Errorf("unexpected Arsenal record %+v", a)
+	}
+	if c.Won != a.Lost || c.Lost != a.Won || c.GoalsFor != a.GoalsAgainst {
+		t.Errorf("Chelsea's record %+v doesn't mirror Arsenal's", c)
+	}
+	if a.BiggestWin == nil || a.BiggestWin.MatchID != 1 {
+		t.Errorf("Arsenal's biggest win = %+v, want match 1", a.BiggestWin)
+	}
+	// Chelsea's two 1-0 wins tie, so the most recent one counts.
+	if c.BiggestWin == nil || c.BiggestWin.MatchID != 5 {
+		t.Errorf("Chelsea's biggest win = %+v, want match 5", c.BiggestWin)
+	}
+
+	if len(h.Meetings) != 6 || h.Meetings[0].MatchID != 6 {
+		t.Fatalf("meetings should be most recent first, got %+v", h.Meetings)
+	}
+	if len(h.Recent) != 5 || h.Recent[4].MatchID != 2 {
+		t.Errorf("unexpected recent meetings %+v", h.Recent)
+	}
+	if h.Meetings[5].Season != "2018-19" || *h.Meetings[5].WinnerID != 1 || h.Meetings[0].WinnerID != nil {
+		t.Errorf("unexpected first meeting %+v", h.Meetings[5])
+	}
+
+	h.SetSeason(data.ComputeStandings(meetings[5:]))
+	if h.Team.Season == nil || h.Team.Season.Drawn != 1 || h.Other.Season == nil {
+		t.Errorf("expected both teams' season rows, got %+v and %+v", h.Team.Season, h.Other.Season)
+	}
+}
+
+func TestComputeHeadToHead_NoMeetings(t *testing.T) {
+	h := data.ComputeHeadToHead(&data.Team{ID: 1}, &data.Team{ID: 2}, nil)
+	if h.Played != 0 || len(h.Recent) != 0 || h.Meetings == nil || h.Team.BiggestWin != nil {
+		t.Errorf("unexpected head-to-head %+v", h)
+	}
+}
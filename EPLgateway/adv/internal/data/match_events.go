@@ -0,0 +1,379 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"sort"
+	"time"
+)
+
+const (
+	EventGoal         = "goal"
+	EventOwnGoal      = "own_goal"
+	EventPenalty      = "penalty"
+	EventYellowCard   = "yellow_card"
+	EventRedCard      = "red_card"
+	EventSubstitution = "substitution"
+)
+
+// MatchEventTypes lists the kinds of event that can happen in a match.
+var MatchEventTypes = []string{EventGoal, EventOwnGoal, EventPenalty, EventYellowCard, EventRedCard, EventSubstitution}
+
+// ErrMatchNotStarted is returned when an event is recorded for a match that is neither
+// live nor finished.
+var ErrMatchNotStarted = errors.New("match has not started")
+
+// A MatchEvent is something that happened in a match at a given minute, such as a
+// goal or a booking. TeamID is the team of the player involved; an own goal counts
+// for the other side. RelatedPlayerID is the player who provided the assist for a
+// goal, or the player coming on for a substitution, in which case PlayerID is the one
+// going off. Player IDs are null once the player has been deleted.
+type MatchEvent struct {
+	ID              int64     `json:"id"`
+	CreatedAt       time.Time `json:"-"`
+	MatchID         int64     `json:"match_id"`
+	TeamID          int64     `json:"team_id"`
+	Type            string    `json:"type"`
+	Minute          int       `json:"minute"`
+	AddedTime       int       `json:"added_time"`
+	PlayerID        *int64    `json:"player_id"`
+	RelatedPlayerID *int64    `json:"related_player_id,omitempty"`
+}
+
+// before reports whether e happened before other. Added time sorts after the minute
+// it was added to, so 45+2 comes before 46.
+func (e *MatchEvent) before(other *MatchEvent) bool {
+	if e.Minute != other.Minute {
+		return e.Minute < other.Minute
+	}
+	return e.AddedTime < other.AddedTime
+}
+
+func (e *MatchEvent) clock() string {
+	if e.AddedTime > 0 {
+		return fmt.Sprintf("%d+%d'", e.Minute, e.AddedTime)
+	}
+	return fmt.Sprintf("%d'", e.Minute)
+}
+
+func ValidateMatchEvent(v *validator.Validator, event *MatchEvent) {
+	v.Check(event.TeamID > 0, "team_id", "must be provided")
+	v.Check(validator.PermittedValue(event.Type, MatchEventTypes...), "type", "must be one of goal, own_goal, penalty, yellow_card, red_card or substitution")
+
+	v.Check(event.Minute >= 1, "minute", "must be greater than zero")
+	v.Check(event.Minute <= 120, "minute", "must not be greater than 120")
+	v.Check(event.AddedTime >= 0, "added_time", "must not be negative")
+	v.Check(event.AddedTime <= 30, "added_time", "must not be greater than 30")
+
+	v.Check(event.PlayerID != nil, "player_id", "must be provided")
+
+	switch event.Type {
+	case EventSubstitution:
+		v.Check(event.RelatedPlayerID != nil, "related_player_id", "must be provided for a substitution")
+	case EventGoal:
+		// The assist is optional.
+	default:
+		v.Check(event.RelatedPlayerID == nil, "related_player_id", "must only be provided for a goal or a substitution")
+	}
+	if event.PlayerID != nil && event.RelatedPlayerID != nil {
+		v.Check(*event.PlayerID != *event.RelatedPlayerID, "related_player_id", "must be different from player_id")
+	}
+}
+
+// A TimelineError is returned when an event doesn't fit with the rest of the match,
+// such as a player being booked after they were sent off. Key is the input field
+// that is at fault.
+type TimelineError struct {
+	Key     string
+	Message string
+}
+
+func (e *TimelineError) Error() string {
+	return fmt.Sprintf("%s %s", e.Key, e.Message)
+}
+
+// ValidateTimeline replays a match's events in order and checks that each one is
+// possible given what came before. Events in the same minute are replayed in the
+// order they are given in, which should be the order they were recorded in.
+//
+// Lineups aren't recorded, so every player is taken to have started the match unless
+// they come on as a substitute. A player can't appear in an event once they have been
+// sent off or substituted, and a substitute can't come on if they have already played.
+// Players who have since been deleted are skipped.
+func ValidateTimeline(events []*MatchEvent) error {
+	events = append([]*MatchEvent{}, events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].before(events[j])
+	})
+
+	// The players who have taken part so far, and the reason, for those who have
+	// left the pitch, that they went off.
+	seen := map[int64]bool{}
+	gone := map[int64]string{}
+	yellows := map[int64]int{}
+
+	for _, e := range events {
+		for _, p := range []struct {
+			key string
+			id  *int64
+		}{{"player_id", e.PlayerID}, {"related_player_id", e.RelatedPlayerID}} {
+			if p.id == nil {
+				continue
+			}
+			if reason, ok := gone[*p.id]; ok {
+				return &TimelineError{Key: p.key, Message: fmt.Sprintf("player %d was %s before %s", *p.id, reason, e.clock())}
+			}
+		}
+
+		if e.Type == EventSubstitution && e.RelatedPlayerID != nil && seen[*e.RelatedPlayerID] {
+			return &TimelineError{Key: "related_player_id", Message: fmt.Sprintf("player %d is already on the pitch at %s", *e.RelatedPlayerID, e.clock())}
+		}
+
+		if e.PlayerID != nil {
+			switch e.Type {
+			case EventSubstitution:
+				gone[*e.PlayerID] = "substituted"
+			case EventRedCard:
+				gone[*e.PlayerID] = "sent off"
+			case EventYellowCard:
+				yellows[*e.PlayerID]++
+				if yellows[*e.PlayerID] == 2 {
+					gone[*e.PlayerID] = "sent off"
+				}
+			}
+		}
+
+		for _, id := range []*int64{e.PlayerID, e.RelatedPlayerID} {
+			if id != nil {
+				seen[*id] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+type MatchEventModel struct {
+	DB      *sql.DB
+	updates *feedHub
+	stats   staleSignal
+}
+
+// Insert records an event and recomputes the match score from its goals, all in one
+// transaction, and publishes both to the match's live feed. The match row is locked
+// while the event is checked against the rest of the timeline, so concurrent events
+// for a match are applied one at a time. It returns the match with its new score.
+func (m MatchEventModel) Insert(event *MatchEvent) (*Match, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT home_team_id, away_team_id, status, home_score, away_score
+        FROM matches
+        WHERE id = $1
+        FOR UPDATE`
+
+	var before Match
+	err = tx.QueryRowContext(ctx, query, event.MatchID).Scan(&before.HomeTeamID, &before.AwayTeamID, &before.Status, &before.HomeScore, &before.AwayScore)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if before.Status != MatchLive && before.Status != MatchFinished {
+		return nil, ErrMatchNotStarted
+	}
+	if event.TeamID != before.HomeTeamID && event.TeamID != before.AwayTeamID {
+		return nil, &TimelineError{Key: "team_id", Message: "must be one of the teams in the match"}
+	}
+
+	// The players involved have to have been in the squad of the event's team on the
+	// day of the match, which isn't necessarily the club they are at now.
+	playerIDs := []int64{*event.PlayerID}
+	if event.RelatedPlayerID != nil {
+		playerIDs = append(playerIDs, *event.RelatedPlayerID)
+	}
+
+	query = `
+        SELECT count(DISTINCT pm.player_id)
+        FROM player_memberships pm
+        JOIN matches m ON m.id = $3
+        WHERE pm.player_id = ANY($1) AND pm.team_id = $2
+        AND pm.joined_on <= (m.kickoff AT TIME ZONE $4)::date
+        AND (pm.left_on IS NULL OR pm.left_on > (m.kickoff AT TIME ZONE $4)::date)`
+
+	var inSquad int
+	err = tx.QueryRowContext(ctx, query, pq.Array(playerIDs), event.TeamID, event.MatchID, MatchdayLocation.String()).Scan(&inSquad)
+	if err != nil {
+		return nil, err
+	}
+	if inSquad != len(playerIDs) {
+		return nil, &TimelineError{Key: "player_id", Message: "must be players in the squad of team_id on the day of the match"}
+	}
+
+	events, err := matchEvents(ctx, tx, event.MatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ValidateTimeline(append(events, event))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+        INSERT INTO match_events (match_id, team_id, type, minute, added_time, player_id, related_player_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at`
+
+	args := []any{
+		event.MatchID,
+		event.TeamID,
+		event.Type,
+		event.Minute,
+		event.AddedTime,
+		event.PlayerID,
+		event.RelatedPlayerID,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := recomputeScore(ctx, tx, event.MatchID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The event goes out on the live feed first, then the score it changed.
+	err = insertMatchUpdate(ctx, tx, MatchUpdateEvent, match, event)
+	if err != nil {
+		return nil, err
+	}
+	err = matchChanges(ctx, tx, &before, match)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	m.updates.wake(match.ID)
+	m.stats.mark()
+	return match, nil
+}
+
+// recomputeScore sets a match's score from its goal events and returns the updated
+// match.
+func recomputeScore(ctx context.Context, tx *sql.Tx, matchID int64) (*Match, error) {
+	query := `
+        UPDATE matches m
+        SET home_score = (
+                SELECT count(*) FROM match_events e
+                WHERE e.match_id = m.id
+                AND ((e.type IN ('goal', 'penalty') AND e.team_id = m.home_team_id)
+                  OR (e.type = 'own_goal' AND e.team_id = m.away_team_id))
+            ),
+            away_score = (
+                SELECT count(*) FROM match_events e
+                WHERE e.match_id = m.id
+                AND ((e.type IN ('goal', 'penalty') AND e.team_id = m.away_team_id)
+                  OR (e.type = 'own_goal' AND e.team_id = m.home_team_id))
+            ),
+            version = version + 1
+        WHERE id = $1
+        RETURNING id, created_at, home_team_id, away_team_id, kickoff, venue, status, home_score, away_score, version`
+
+	var match Match
+	err := tx.QueryRowContext(ctx, query, matchID).Scan(
+		&match.ID,
+		&match.CreatedAt,
+		&match.HomeTeamID,
+		&match.AwayTeamID,
+		&match.Kickoff,
+		&match.Venue,
+		&match.Status,
+		&match.HomeScore,
+		&match.AwayScore,
+		&match.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &match, nil
+}
+
+// GetAllForMatch returns a match's events in the order they happened.
+func (m MatchEventModel) GetAllForMatch(matchID int64) ([]*MatchEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return matchEvents(ctx, m.DB, matchID)
+}
+
+// matchEvents lists a match's events in order. q is either the database or a
+// transaction.
+func matchEvents(ctx context.Context, q queryer, matchID int64) ([]*MatchEvent, error) {
+	query := `
+        SELECT id, created_at, match_id, team_id, type, minute, added_time, player_id, related_player_id
+        FROM match_events
+        WHERE match_id = $1
+        ORDER BY minute, added_time, id`
+
+	rows, err := q.QueryContext(ctx, query, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*MatchEvent{}
+	for rows.Next() {
+		var event MatchEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&event.MatchID,
+			&event.TeamID,
+			&event.Type,
+			&event.Minute,
+			&event.AddedTime,
+			&event.PlayerID,
+			&event.RelatedPlayerID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+type MockMatchEventModel struct{}
+
+func (m MockMatchEventModel) Insert(event *MatchEvent) (*Match, error) {
+	return nil, nil
+}
+func (m MockMatchEventModel) GetAllForMatch(matchID int64) ([]*MatchEvent, error) {
+	return nil, nil
+}
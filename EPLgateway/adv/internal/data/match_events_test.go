@@ -0,0 +1,124 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+func TestValidateTimeline(t *testing.T) {
+	id := func(n int64) *int64 { return &n }
+	event := func(typ string, minute, added int, player int64, related *int64) *data.MatchEvent {
+		return &data.MatchEvent{Type: typ, Minute: minute, AddedTime: added, PlayerID: id(player), RelatedPlayerID: related}
+	}
+
+	tests := []struct {
+		name   string
+		events []*data.MatchEvent
+		key    string
+	}{
+		{
+			name: "valid",
+			events: []*data.MatchEvent{
+				event(data.EventGoal, 12, 0, 9, id(10)),
+				event(data.EventYellowCard, 45, 2, 4, nil),
+				event(data.EventSubstitution, 60, 0, 9, id(19)),
+				event(data.EventGoal, 88, 0, 19, nil),
+			},
+		},
+		{
+			name: "booked after a red card",
+			events: []*data.MatchEvent{
+				event(data.EventRedCard, 30, 0, 4, nil),
+				event(data.EventYellowCard, 31, 0, 4, nil),
+			},
+			key: "player_id",
+		},
+		{
+			name: "scores after a second yellow",
+			events: []*data.MatchEvent{
+				event(data.EventYellowCard, 20, 0, 4, nil),
+				event(data.EventYellowCard, 45, 1, 4, nil),
+				event(data.EventGoal, 50, 0, 4, nil),
+			},
+			key: "player_id",
+		},
+		{
+			name: "substitute already on the pitch",
+			events: []*data.MatchEvent{
+				event(data.EventGoal, 10, 0, 19, nil),
+				event(data.EventSubstitution, 60, 0, 9, id(19)),
+			},
+			key: "related_player_id",
+		},
+		{
+			name: "substituted player assists later",
+			events: []*data.MatchEvent{
+				event(data.EventGoal, 70, 0, 11, id(9)),
+				event(data.EventSubstitution, 60, 0, 9, id(19)),
+			},
+			key: "related_player_id",
+		},
+		{
+			name: "added time sorts before the next minute",
+			events: []*data.MatchEvent{
+				event(data.EventGoal, 46, 0, 9, nil),
+				event(data.EventRedCard, 45, 3, 9, nil),
+			},
+			key: "player_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := data.ValidateTimeline(tt.events)
+
+			if tt.key == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var timelineErr *data.TimelineError
+			if !errors.As(err, &timelineErr) {
+				t.Fatalf("expected a TimelineError, got %v", err)
+			}
+			if timelineErr.Key != tt.key {
+				t.Errorf("expected an error for %q, got %q", tt.key, timelineErr.Key)
+			}
+		})
+	}
+}
+
+func TestMatchEventModel_InsertPlayerNotInSquad(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.MatchEventModel{DB: db}
+
+	// The squad is checked against the players' spells at each club on the day of the
+	// match, so a player who has since moved to team 1 doesn't count for it.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM matches`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"home_team_id", "away_team_id", "status", "home_score", "away_score"}).AddRow(1, 2, "live", 0, 0))
+	mock.ExpectQuery(`FROM player_memberships pm`).WithArgs(sqlmock.AnyArg(), 1, 1, "Europe/London").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	playerID := int64(9)
+	_, err = model.Insert(&data.MatchEvent{MatchID: 1, TeamID: 1, Type: data.EventYellowCard, Minute: 30, PlayerID: &playerID})
+
+	var timelineErr *data.TimelineError
+	if !errors.As(err, &timelineErr) || timelineErr.Key != "player_id" {
+		t.Fatalf("expected a player_id timeline error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
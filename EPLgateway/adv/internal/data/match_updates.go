@@ -0,0 +1,177 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	MatchUpdateScore  = "score"
+	MatchUpdateEvent  = "event"
+	MatchUpdateStatus = "status"
+)
+
+// A MatchUpdate is one item of a match's live feed: a change of score, a new event or
+// a change of status. Match is the match as it stood after the change, and Event is
+// set for new events. Updates are numbered by a bigserial ID shared by every match and
+// handed out in commit order, which is the resume cursor for clients following the
+// feed.
+type MatchUpdate struct {
+	ID        int64       `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Type      string      `json:"type"`
+	MatchID   int64       `json:"match_id"`
+	Match     *Match      `json:"match"`
+	Event     *MatchEvent `json:"event,omitempty"`
+}
+
+// matchUpdatesChannel is the channel match updates are notified under. Each match is a
+// topic of its own.
+const matchUpdatesChannel = "match_updates"
+
+// insertMatchUpdate writes an update to the feed as part of the caller's transaction,
+// in the same way as insertTeamEvent.
+func insertMatchUpdate(ctx context.Context, tx *sql.Tx, updateType string, match *Match, event *MatchEvent) error {
+	matchJSON, err := json.Marshal(match)
+	if err != nil {
+		return err
+	}
+
+	var eventJSON any
+	if event != nil {
+		js, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		eventJSON = js
+	}
+
+	err = lockFeed(ctx, tx, matchUpdatesChannel, match.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO match_updates (type, match_id, match, event)
+        VALUES ($1, $2, $3, $4)`
+
+	_, err = tx.ExecContext(ctx, query, updateType, match.ID, matchJSON, eventJSON)
+	return err
+}
+
+// matchChanges writes the score and status updates for a change to a match from
+// before to after, as part of the caller's transaction.
+func matchChanges(ctx context.Context, tx *sql.Tx, before, after *Match) error {
+	if !sameScore(before.HomeScore, after.HomeScore) || !sameScore(before.AwayScore, after.AwayScore) {
+		err := insertMatchUpdate(ctx, tx, MatchUpdateScore, after, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if before.Status != after.Status {
+		err := insertMatchUpdate(ctx, tx, MatchUpdateStatus, after, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sameScore(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+type MatchUpdateModel struct {
+	DB  *sql.DB
+	hub *feedHub
+}
+
+// LatestID returns the ID of the most recent update to any match, or 0 if there are
+// none. The readers of the feed start from here.
+func (m MatchUpdateModel) LatestID() (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM match_updates`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err := m.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// GetAfter returns up to limit updates to a match with an ID greater than cursor,
+// oldest first.
+func (m MatchUpdateModel) GetAfter(matchID, cursor int64, limit int) ([]*MatchUpdate, error) {
+	query := `
+        SELECT id, created_at, type, match_id, match, event
+        FROM match_updates
+        WHERE match_id = $1 AND id > $2
+        ORDER BY id
+        LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, matchID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := []*MatchUpdate{}
+	for rows.Next() {
+		var update MatchUpdate
+		var matchJSON, eventJSON []byte
+
+		err := rows.Scan(&update.ID, &update.CreatedAt, &update.Type, &update.MatchID, &matchJSON, &eventJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		update.Match = &Match{}
+		err = json.Unmarshal(matchJSON, update.Match)
+		if err != nil {
+			return nil, err
+		}
+		if eventJSON != nil {
+			update.Event = &MatchEvent{}
+			err = json.Unmarshal(eventJSON, update.Event)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		updates = append(updates, &update)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+// Stream delivers every update to a match after cursor, or from now on if cursor is
+// negative, first from the database and then as changes are committed, until ctx is
+// cancelled or an error occurs. It works in the same way as TeamEventModel.Stream.
+func (m MatchUpdateModel) Stream(ctx context.Context, matchID, cursor int64) (<-chan *MatchUpdate, <-chan error) {
+	id := func(update *MatchUpdate) int64 { return update.ID }
+	getAfter := func(cursor int64, limit int) ([]*MatchUpdate, error) {
+		return m.GetAfter(matchID, cursor, limit)
+	}
+	return streamFeed(ctx, m.hub, matchID, cursor, id, getAfter, m.LatestID)
+}
+
+type MockMatchUpdateModel struct{}
+
+func (m MockMatchUpdateModel) Stream(ctx context.Context, matchID, cursor int64) (<-chan *MatchUpdate, <-chan error) {
+	updates := make(chan *MatchUpdate)
+	close(updates)
+	return updates, make(chan error)
+}
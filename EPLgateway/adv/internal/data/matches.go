@@ -0,0 +1,440 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	_ "time/tzdata"
+)
+
+const (
+	MatchScheduled = "scheduled"
+	MatchLive      = "live"
+	MatchFinished  = "finished"
+	MatchPostponed = "postponed"
+)
+
+// MatchStatuses lists the statuses a match can have.
+var MatchStatuses = []string{MatchScheduled, MatchLive, MatchFinished, MatchPostponed}
+
+// MatchdayLocation is the time zone that decides which day a match is played on, for
+// the purpose of stopping a team from being booked twice on the same day.
+var MatchdayLocation = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// ErrMatchHasEvents is returned when the teams or the score of a match are changed
+// directly after events have been recorded for it, since the score is computed from
+// the events and the events belong to the teams.
+var ErrMatchHasEvents = errors.New("match has events")
+
+// A DoubleBookingError is returned when one of the teams in a match already has
+// another match on the same day.
+type DoubleBookingError struct {
+	TeamID  int64
+	MatchID int64
+}
+
+func (e *DoubleBookingError) Error() string {
+	return fmt.Sprintf("team %d is already playing in match %d on that day", e.TeamID, e.MatchID)
+}
+
+type Match struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	HomeTeamID int64     `json:"home_team_id"`
+	AwayTeamID int64     `json:"away_team_id"`
+	Kickoff    time.Time `json:"kickoff"`
+	Venue      string    `json:"venue"`
+	Status     string    `json:"status"`
+	HomeScore  *int      `json:"home_score"`
+	AwayScore  *int      `json:"away_score"`
+	Version    int32     `json:"version"`
+}
+
+func ValidateMatch(v *validator.Validator, match *Match) {
+	v.Check(match.HomeTeamID > 0, "home_team_id", "must be provided")
+	v.Check(match.AwayTeamID > 0, "away_team_id", "must be provided")
+	v.Check(match.HomeTeamID != match.AwayTeamID, "away_team_id", "must be different from home_team_id")
+
+	v.Check(!match.Kickoff.IsZero(), "kickoff", "must be provided")
+
+	v.Check(match.Venue != "", "venue", "must be provided")
+	v.Check(len(match.Venue) <= 100, "venue", "must not be more than 100 bytes long")
+
+	v.Check(validator.PermittedValue(match.Status, MatchStatuses...), "status", "must be one of scheduled, live, finished or postponed")
+
+	// A score only makes sense once the match has kicked off, and a finished match
+	// must have one.
+	hasScore := match.HomeScore != nil && match.AwayScore != nil
+	v.Check((match.HomeScore == nil) == (match.AwayScore == nil), "score", "home_score and away_score must be provided together")
+	switch match.Status {
+	case MatchFinished:
+		v.Check(hasScore, "score", "must be provided for a finished match")
+	case MatchScheduled, MatchPostponed:
+		v.Check(!hasScore, "score", "must not be provided before the match has started")
+	}
+	if hasScore {
+		v.Check(*match.HomeScore >= 0, "home_score", "must not be negative")
+		v.Check(*match.AwayScore >= 0, "away_score", "must not be negative")
+	}
+}
+
+type MatchModel struct {
+	DB      *sql.DB
+	updates *feedHub
+	stats   staleSignal
+}
+
+// checkBookings locks both teams' rows for the rest of tx, then makes sure neither of
+// them has another match on the day of the kickoff. Locking the teams serializes
+// bookings for them, so two concurrent requests can't both pass the check. Postponed
+// matches don't count.
+func checkBookings(ctx context.Context, tx *sql.Tx, match *Match) error {
+	query := `
+        SELECT id
+        FROM teams
+        WHERE id IN ($1, $2)
+        ORDER BY id
+        FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query, match.HomeTeamID, match.AwayTeamID)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	query = `
+        SELECT id, CASE WHEN home_team_id IN ($1, $2) THEN home_team_id ELSE away_team_id END
+        FROM matches
+        WHERE (home_team_id IN ($1, $2) OR away_team_id IN ($1, $2))
+        AND (kickoff AT TIME ZONE $3)::date = ($4::timestamptz AT TIME ZONE $3)::date
+        AND status <> 'postponed'
+        AND id <> $5
+        LIMIT 1`
+
+	args := []any{match.HomeTeamID, match.AwayTeamID, MatchdayLocation.String(), match.Kickoff, match.ID}
+
+	var conflict DoubleBookingError
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&conflict.MatchID, &conflict.TeamID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return &conflict
+	}
+}
+
+func (m MatchModel) Insert(match *Match) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if match.Status != MatchPostponed {
+		err = checkBookings(ctx, tx, match)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+        INSERT INTO matches (home_team_id, away_team_id, kickoff, venue, status, home_score, away_score)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at, version`
+
+	args := []any{
+		match.HomeTeamID,
+		match.AwayTeamID,
+		match.Kickoff,
+		match.Venue,
+		match.Status,
+		match.HomeScore,
+		match.AwayScore,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&match.ID, &match.CreatedAt, &match.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m MatchModel) Get(id int64) (*Match, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, home_team_id, away_team_id, kickoff, venue, status, home_score, away_score, version
+        FROM matches
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var match Match
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&match.ID,
+		&match.CreatedAt,
+		&match.HomeTeamID,
+		&match.AwayTeamID,
+		&match.Kickoff,
+		&match.Venue,
+		&match.Status,
+		&match.HomeScore,
+		&match.AwayScore,
+		&match.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &match, nil
+}
+
+// Update saves a match, using the version number for optimistic concurrency control.
+// The booking check is repeated, since the kickoff or the teams may have changed.
+// Changes to the status or the score are published to the match's live feed. Once a
+// match has events, its teams and score can't be changed here: ErrMatchHasEvents is
+// returned instead.
+func (m MatchModel) Update(match *Match) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Read the current teams, status and score, for the live feed and to check them
+	// against the events. Locking the row stops events from being recorded meanwhile.
+	query := `
+        SELECT home_team_id, away_team_id, status, home_score, away_score,
+            EXISTS (SELECT 1 FROM match_events WHERE match_id = $1)
+        FROM matches
+        WHERE id = $1
+        FOR UPDATE`
+
+	var before Match
+	var hasEvents bool
+	err = tx.QueryRowContext(ctx, query, match.ID).Scan(
+		&before.HomeTeamID,
+		&before.AwayTeamID,
+		&before.Status,
+		&before.HomeScore,
+		&before.AwayScore,
+		&hasEvents,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if hasEvents {
+		sameTeams := before.HomeTeamID == match.HomeTeamID && before.AwayTeamID == match.AwayTeamID
+		if !sameTeams || !sameScore(before.HomeScore, match.HomeScore) || !sameScore(before.AwayScore, match.AwayScore) {
+			return ErrMatchHasEvents
+		}
+	}
+
+	if match.Status != MatchPostponed {
+		err = checkBookings(ctx, tx, match)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = `
+        UPDATE matches
+        SET home_team_id = $1, away_team_id = $2, kickoff = $3, venue = $4, status = $5,
+            home_score = $6, away_score = $7, version = version + 1
+        WHERE id = $8 AND version = $9
+        RETURNING version`
+
+	args := []any{
+		match.HomeTeamID,
+		match.AwayTeamID,
+		match.Kickoff,
+		match.Venue,
+		match.Status,
+		match.HomeScore,
+		match.AwayScore,
+		match.ID,
+		match.Version,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&match.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = matchChanges(ctx, tx, &before, match)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.updates.wake(match.ID)
+	// A new kickoff can move the match into another season.
+	m.stats.mark()
+	return nil
+}
+
+func (m MatchModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM matches
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	// The match's events went with it.
+	m.stats.mark()
+	return nil
+}
+
+// MatchFilter narrows down GetAll(). Zero values match every match; From and To
+// bound the kickoff time, From inclusive and To exclusive.
+type MatchFilter struct {
+	TeamID int64
+	From   time.Time
+	To     time.Time
+	Status string
+}
+
+func (m MatchModel) GetAll(filter MatchFilter, filters Filters) ([]*Match, Metadata, error) {
+	// Nullable parameters let us keep a single query for every combination of
+	// filters.
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, home_team_id, away_team_id, kickoff, venue, status, home_score, away_score, version
+        FROM matches
+        WHERE ($1 = 0 OR home_team_id = $1 OR away_team_id = $1)
+        AND ($2::timestamptz IS NULL OR kickoff >= $2)
+        AND ($3::timestamptz IS NULL OR kickoff < $3)
+        AND ($4 = '' OR status = $4)
+        ORDER BY %s %s, id ASC
+        LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		filter.TeamID,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		filter.Status,
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	matches := []*Match{}
+	for rows.Next() {
+		var match Match
+		err := rows.Scan(
+			&totalRecords,
+			&match.ID,
+			&match.CreatedAt,
+			&match.HomeTeamID,
+			&match.AwayTeamID,
+			&match.Kickoff,
+			&match.Venue,
+			&match.Status,
+			&match.HomeScore,
+			&match.AwayScore,
+			&match.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		matches = append(matches, &match)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return matches, metadata, nil
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+type MockMatchModel struct{}
+
+func (m MockMatchModel) Insert(match *Match) error {
+	return nil
+}
+func (m MockMatchModel) Get(id int64) (*Match, error) {
+	return nil, nil
+}
+func (m MockMatchModel) Update(match *Match) error {
+	return nil
+}
+func (m MockMatchModel) Delete(id int64) error {
+	return nil
+}
+func (m MockMatchModel) GetAll(filter MatchFilter, filters Filters) ([]*Match, Metadata, error) {
+	return nil, Metadata{}, nil
+}
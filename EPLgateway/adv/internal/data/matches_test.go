@@ -0,0 +1,224 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+func TestMatchModel_InsertDoubleBooked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.MatchModel{DB: db}
+
+	kickoff := time.Date(2024, time.August, 17, 15, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM teams .* FOR UPDATE`).WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery(`FROM matches`).WithArgs(1, 2, "Europe/London", kickoff, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "team_id"}).AddRow(9, 2))
+	mock.ExpectRollback()
+
+	match := &data.Match{
+		HomeTeamID: 1,
+		AwayTeamID: 2,
+		Kickoff:    kickoff,
+		Venue:      "Emirates Stadium",
+		Status:     data.MatchScheduled,
+	}
+
+	err = model.Insert(match)
+
+	var conflict *data.DoubleBookingError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a DoubleBookingError, got %v", err)
+	}
+	if conflict.TeamID != 2 || conflict.MatchID != 9 {
+		t.Errorf("unexpected conflict: %+v", conflict)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestValidateMatch(t *testing.T) {
+	score := func(n int) *int { return &n }
+
+	tests := []struct {
+		name  string
+		match data.Match
+		field string
+	}{
+		{"same team", data.Match{HomeTeamID: 1, AwayTeamID: 1, Status: data.MatchScheduled}, "away_team_id"},
+		{"finished without score", data.Match{HomeTeamID: 1, AwayTeamID: 2, Status: data.MatchFinished}, "score"},
+		{"scheduled with score", data.Match{HomeTeamID: 1, AwayTeamID: 2, Status: data.MatchScheduled, HomeScore: score(1), AwayScore: score(0)}, "score"},
+		{"unknown status", data.Match{HomeTeamID: 1, AwayTeamID: 2, Status: "abandoned"}, "status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.match.Kickoff = time.Now()
+			tt.match.Venue = "Anfield"
+
+			v := validator.New()
+			data.ValidateMatch(v, &tt.match)
+
+			if _, ok := v.Errors[tt.field]; !ok {
+				t.Errorf("expected an error for %q, got %v", tt.field, v.Errors)
+			}
+		})
+	}
+}
+
+func TestMatchModel_UpdatePublishesStatusChange(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kickoff := time.Date(2024, time.August, 17, 15, 0, 0, 0, time.UTC)
+	snapshot := `{"id":1,"home_team_id":1,"away_team_id":2,"status":"scheduled"}`
+
+	// The match's topic starts at the latest update, and the backlog holds one update.
+	// Once the stream has delivered it, it waits to be woken by a commit.
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(id\), 0\) FROM match_updates`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(7))
+	mock.ExpectQuery(`FROM match_updates`).WithArgs(1, 0, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "type", "match_id", "match", "event"}).
+			AddRow(7, time.Now(), "status", 1, []byte(snapshot), nil))
+
+	updates, _ := models.MatchUpdates.Stream(ctx, 1, 0)
+	if update := <-updates; update.ID != 7 || update.Match.Status != data.MatchScheduled {
+		t.Fatalf("unexpected backlog update: %+v", update)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT home_team_id, away_team_id, status, home_score, away_score`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"home_team_id", "away_team_id", "status", "home_score", "away_score", "exists"}).
+			AddRow(1, 2, "scheduled", nil, nil, false))
+	mock.ExpectQuery(`FROM teams .* FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery(`FROM matches`).WillReturnRows(sqlmock.NewRows([]string{"id", "team_id"}))
+	mock.ExpectQuery(`UPDATE matches`).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+	mock.ExpectExec(`pg_advisory_xact_lock`).WithArgs("match_updates", 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO match_updates`).WithArgs("status", 1, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectCommit()
+
+	// The commit wakes the stream, which reads the new update from the database.
+	mock.ExpectQuery(`FROM match_updates`).WithArgs(1, 7, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "type", "match_id", "match", "event"}).
+			AddRow(8, time.Now(), "status", 1, []byte(`{"id":1,"home_team_id":1,"away_team_id":2,"status":"live"}`), nil))
+
+	match := &data.Match{
+		ID:         1,
+		HomeTeamID: 1,
+		AwayTeamID: 2,
+		Kickoff:    kickoff,
+		Venue:      "Emirates Stadium",
+		Status:     data.MatchLive,
+		Version:    1,
+	}
+
+	err = models.Matches.Update(match)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	select {
+	case update := <-updates:
+		if update.ID != 8 || update.Type != data.MatchUpdateStatus || update.Match.Status != data.MatchLive {
+			t.Errorf("unexpected live update: %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the live update")
+	}
+
+	select {
+	case <-models.PlayerStats.Stale():
+	default:
+		t.Error("expected the player stats to be marked stale")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMatchModel_UpdateWithEvents(t *testing.T) {
+	two := 2
+
+	tests := []struct {
+		name    string
+		change  func(match *data.Match)
+		wantErr error
+	}{
+		{"score", func(match *data.Match) { match.HomeScore = &two }, data.ErrMatchHasEvents},
+		{"teams", func(match *data.Match) { match.AwayTeamID = 3 }, data.ErrMatchHasEvents},
+		{"venue", func(match *data.Match) { match.Venue = "Wembley Stadium" }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			model := data.MatchModel{DB: db}
+
+			// The match already has a goal recorded, which gave it a 1-0 score.
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT home_team_id, away_team_id, status, home_score, away_score`).WithArgs(1).
+				WillReturnRows(sqlmock.NewRows([]string{"home_team_id", "away_team_id", "status", "home_score", "away_score", "exists"}).
+					AddRow(1, 2, "live", 1, 0, true))
+			if tt.wantErr == nil {
+				mock.ExpectQuery(`FROM teams .* FOR UPDATE`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectQuery(`FROM matches`).WillReturnRows(sqlmock.NewRows([]string{"id", "team_id"}))
+				mock.ExpectQuery(`UPDATE matches`).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			one, zero := 1, 0
+			match := &data.Match{
+				ID:         1,
+				HomeTeamID: 1,
+				AwayTeamID: 2,
+				Kickoff:    time.Date(2024, time.August, 17, 15, 0, 0, 0, time.UTC),
+				Venue:      "Emirates Stadium",
+				Status:     data.MatchLive,
+				HomeScore:  &one,
+				AwayScore:  &zero,
+				Version:    2,
+			}
+			tt.change(match)
+
+			err = model.Update(match)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -1,37 +1,143 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"time"
 )
 
 // Define a custom ErrRecordNotFound error. We'll return this from our Get() method when
-// looking up a movie that doesn't exist in our database.
+// looking up a movie that doesn't exist in our database. ErrEditConflict is returned by
+// Update() when the record has changed since the caller read it.
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// queryer is implemented by both *sql.DB and *sql.Tx, for helpers that read inside or
+// outside of a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
 // Create a Models struct which wraps the MovieModel. We'll add other models to this,
 // like a UserModel and PermissionModel, as our build progresses.
 type Models struct {
 	Teams interface {
-		Insert(team *Team) error
+		Insert(team *Team, actorID int64) error
 		Get(id int64) (*Team, error)
-		Update(team *Team) error
+		Update(team *Team, actorID int64) error
+		Delete(id int64, actorID int64) error
+		GetAll(name, location, stadium string, filters Filters) ([]*Team, Metadata, error)
+		Search(q string, filters Filters) ([]*TeamSearchResult, Metadata, error)
+		GetTrash(filters Filters) ([]*Team, Metadata, error)
+		Restore(id int64, actorID int64) (*Team, error)
+		Purge(cutoff time.Time) (purged, kept int64, err error)
+		GetRevisions(teamID int64, filters Filters) ([]*TeamRevision, Metadata, error)
+		Revert(id int64, version int32, revision int32, actorID int64) (*Team, error)
+		SetCrest(team *Team, key string, actorID int64) (string, error)
+		Import(teams []*Team, atomic, dryRun bool, actorID int64) ([]error, error)
+		Export(ctx context.Context, name, location, stadium string, filters Filters, counts []string, fn func(*TeamExport) error) error
+	}
+	Players interface {
+		Insert(player *Player) error
+		Get(id int64) (*Player, error)
+		Update(player *Player) error
+		Delete(id int64) error
+		GetAllForTeam(teamID int64, position, nationality string, asOf time.Time, filters Filters) ([]*Player, Metadata, error)
+	}
+	Transfers interface {
+		InsertWindow(window *TransferWindow) error
+		GetWindows() ([]*TransferWindow, error)
+		Insert(transfer *Transfer, shirtNumber int) error
+		Get(id int64) (*Transfer, error)
+		GetAll(filter TransferFilter, filters Filters) ([]*Transfer, Metadata, error)
+	}
+	Stadiums interface {
+		Insert(stadium *Stadium) error
+		Get(id int64) (*Stadium, error)
+		GetByName(name string) (*Stadium, error)
+		Update(stadium *Stadium) error
+		Delete(id int64) error
+		GetAll(name, city string, filters Filters) ([]*Stadium, Metadata, error)
+		Nearby(lat, lon, radiusKm float64) ([]*NearbyStadium, error)
+	}
+	Matches interface {
+		Insert(match *Match) error
+		Get(id int64) (*Match, error)
+		Update(match *Match) error
 		Delete(id int64) error
+		GetAll(filter MatchFilter, filters Filters) ([]*Match, Metadata, error)
+		GetResults(seasonID int64, from, to time.Time) ([]MatchResult, error)
+		GetMeetings(teamID, otherID int64) ([]MatchResult, error)
+		InsertFixtures(seasonID int64, matches []*Match) error
+	}
+	Seasons interface {
+		Insert(season *Season, teamIDs []int64) error
+		Get(id int64) (*Season, error)
+		GetByName(name string) (*Season, error)
+		GetAll(filters Filters) ([]*Season, Metadata, error)
+		Close(id int64, promoted []int64, next *Season) (*SeasonClosure, error)
+		GetFixtureTeams(id int64) ([]*FixtureTeam, error)
+	}
+	MatchEvents interface {
+		Insert(event *MatchEvent) (*Match, error)
+		GetAllForMatch(matchID int64) ([]*MatchEvent, error)
+	}
+	MatchUpdates interface {
+		Stream(ctx context.Context, matchID, cursor int64) (<-chan *MatchUpdate, <-chan error)
 	}
+	PlayerStats interface {
+		Stale() <-chan struct{}
+		Refresh() error
+		GetAll(filter PlayerStatsFilter, filters Filters) ([]*PlayerStats, Metadata, error)
+		Leaders(metric string, filter PlayerStatsFilter, limit int) ([]*PlayerStatsLeader, error)
+	}
+	TeamEvents interface {
+		Stream(ctx context.Context, cursor int64) (<-chan *TeamEvent, <-chan error)
+	}
+
+	// feeds are the hubs Listen() wakes up when another process commits to them.
+	feeds []*feedHub
 }
 
 // For ease of use, we also add a New() method which returns a Models struct containing
-// the initialized MovieModel.
+// the initialized MovieModel. The team and team event models share a hub, which is
+// how changes made through Teams wake up the streams opened through TeamEvents; see
+// Listen() for changes made by other processes. Matches and MatchEvents share another
+// with MatchUpdates in the same way, and tell PlayerStats when its view needs
+// refreshing.
 func NewModels(db *sql.DB) Models {
+	hub := newFeedHub(teamEventsChannel)
+	updates := newFeedHub(matchUpdatesChannel)
+	stats := newStaleSignal()
 	return Models{
-		Teams: TeamModel{DB: db},
+		Teams:        TeamModel{DB: db, events: hub},
+		TeamEvents:   TeamEventModel{DB: db, hub: hub},
+		Players:      PlayerModel{DB: db},
+		Transfers:    TransferModel{DB: db},
+		Stadiums:     StadiumModel{DB: db},
+		Matches:      MatchModel{DB: db, updates: updates, stats: stats},
+		Seasons:      SeasonModel{DB: db, stats: stats},
+		MatchEvents:  MatchEventModel{DB: db, updates: updates, stats: stats},
+		MatchUpdates: MatchUpdateModel{DB: db, hub: updates},
+		PlayerStats:  PlayerStatsModel{DB: db, stale: stats},
+		feeds:        []*feedHub{hub, updates},
 	}
 }
 
 func NewMockModels() Models {
 	return Models{
-		Teams: MockTeamModel{},
+		Teams:        MockTeamModel{},
+		TeamEvents:   MockTeamEventModel{},
+		Players:      MockPlayerModel{},
+		Transfers:    MockTransferModel{},
+		Stadiums:     MockStadiumModel{},
+		Matches:      MockMatchModel{},
+		Seasons:      MockSeasonModel{},
+		MatchEvents:  MockMatchEventModel{},
+		MatchUpdates: MockMatchUpdateModel{},
+		PlayerStats:  MockPlayerStatsModel{},
 	}
 }
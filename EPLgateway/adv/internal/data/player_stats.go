@@ -0,0 +1,214 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PlayerStatMetrics lists the statistics that players can be ranked by.
+var PlayerStatMetrics = []string{"appearances", "goals", "assists", "minutes_played", "yellow_cards", "red_cards", "goals_per_90"}
+
+// PlayerStats are a player's totals for a team, over one season or all of them. They
+// are read from the player_stats materialized view, so they can lag a few seconds
+// behind the match events they are built from.
+type PlayerStats struct {
+	PlayerID      int64   `json:"player_id"`
+	PlayerName    string  `json:"player_name"`
+	TeamID        int64   `json:"team_id"`
+	TeamName      string  `json:"team_name"`
+	Appearances   int     `json:"appearances"`
+	Goals         int     `json:"goals"`
+	Assists       int     `json:"assists"`
+	MinutesPlayed int     `json:"minutes_played"`
+	YellowCards   int     `json:"yellow_cards"`
+	RedCards      int     `json:"red_cards"`
+	GoalsPer90    float64 `json:"goals_per_90"`
+}
+
+// A PlayerStatsLeader is one entry of a leaderboard. Players with the same value share
+// a rank.
+type PlayerStatsLeader struct {
+	Rank int `json:"rank"`
+	PlayerStats
+}
+
+// PlayerStatsFilter narrows down the stats. Zero values match everything.
+type PlayerStatsFilter struct {
+	SeasonID int64
+	TeamID   int64
+}
+
+// staleSignal tells the stats refresher that match events have changed. It holds at
+// most one pending signal, so a burst of changes leads to a single refresh.
+type staleSignal chan struct{}
+
+func newStaleSignal() staleSignal {
+	return make(staleSignal, 1)
+}
+
+// mark never blocks. A nil signal, as in models built by hand in tests, is ignored.
+func (s staleSignal) mark() {
+	select {
+	case s <- struct{}{}:
+	default:
+	}
+}
+
+type PlayerStatsModel struct {
+	DB    *sql.DB
+	stale staleSignal
+}
+
+// Stale returns a channel that receives a value whenever the stats need to be
+// refreshed.
+func (m PlayerStatsModel) Stale() <-chan struct{} {
+	return m.stale
+}
+
+// Refresh rebuilds the player_stats view. CONCURRENTLY lets readers carry on using the
+// old contents while it runs.
+func (m PlayerStatsModel) Refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY player_stats`)
+	return err
+}
+
+// playerStatsQuery totals the view over the filter's seasons, by player and team.
+// $1 and $2 are the season and team IDs.
+const playerStatsQuery = `
+        SELECT ps.player_id, p.name AS player_name, ps.team_id, t.name AS team_name,
+               sum(ps.appearances) AS appearances,
+               sum(ps.goals) AS goals,
+               sum(ps.assists) AS assists,
+               sum(ps.minutes_played) AS minutes_played,
+               sum(ps.yellow_cards) AS yellow_cards,
+               sum(ps.red_cards) AS red_cards,
+               CASE WHEN sum(ps.minutes_played) > 0
+                    THEN sum(ps.goals) * 90.0 / sum(ps.minutes_played)
+                    ELSE 0 END AS goals_per_90
+        FROM player_stats ps
+        JOIN players p ON p.id = ps.player_id
+        JOIN teams t ON t.id = ps.team_id
+        WHERE ($1 = 0 OR ps.season_id = $1)
+        AND ($2 = 0 OR ps.team_id = $2)
+        GROUP BY ps.player_id, p.name, ps.team_id, t.name`
+
+func scanPlayerStats(rows *sql.Rows, dest ...any) (*PlayerStats, error) {
+	var stats PlayerStats
+	err := rows.Scan(append(dest,
+		&stats.PlayerID,
+		&stats.PlayerName,
+		&stats.TeamID,
+		&stats.TeamName,
+		&stats.Appearances,
+		&stats.Goals,
+		&stats.Assists,
+		&stats.MinutesPlayed,
+		&stats.YellowCards,
+		&stats.RedCards,
+		&stats.GoalsPer90,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetAll returns one page of player stats, sorted by any of the PlayerStatMetrics or
+// by player_name.
+func (m PlayerStatsModel) GetAll(filter PlayerStatsFilter, filters Filters) ([]*PlayerStats, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), *
+        FROM (%s) stats
+        ORDER BY %s %s, player_id ASC
+        LIMIT $3 OFFSET $4`, playerStatsQuery, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.SeasonID, filter.TeamID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	all := []*PlayerStats{}
+	for rows.Next() {
+		stats, err := scanPlayerStats(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		all = append(all, stats)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return all, metadata, nil
+}
+
+// Leaders returns the players with the highest value of metric, which must be one of
+// the PlayerStatMetrics. Everybody tied with the last of the top limit players is
+// included, so more than limit players can come back. Players with nothing to their
+// name for the metric are left out.
+func (m PlayerStatsModel) Leaders(metric string, filter PlayerStatsFilter, limit int) ([]*PlayerStatsLeader, error) {
+	if !slices.Contains(PlayerStatMetrics, metric) {
+		panic("unsafe stats metric: " + metric)
+	}
+
+	query := fmt.Sprintf(`
+        SELECT *
+        FROM (
+            SELECT rank() OVER (ORDER BY %[1]s DESC) AS rank, *
+            FROM (%[2]s) stats
+            WHERE %[1]s > 0
+        ) ranked
+        WHERE rank <= $3
+        ORDER BY rank, player_name, player_id`, metric, playerStatsQuery)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filter.SeasonID, filter.TeamID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaders := []*PlayerStatsLeader{}
+	for rows.Next() {
+		var rank int
+		stats, err := scanPlayerStats(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		leaders = append(leaders, &PlayerStatsLeader{Rank: rank, PlayerStats: *stats})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return leaders, nil
+}
+
+type MockPlayerStatsModel struct{}
+
+func (m MockPlayerStatsModel) Stale() <-chan struct{} {
+	return nil
+}
+func (m MockPlayerStatsModel) Refresh() error {
+	return nil
+}
+func (m MockPlayerStatsModel) GetAll(filter PlayerStatsFilter, filters Filters) ([]*PlayerStats, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+func (m MockPlayerStatsModel) Leaders(metric string, filter PlayerStatsFilter, limit int) ([]*PlayerStatsLeader, error) {
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+)
+
+var playerStatsColumns = []string{"player_id", "player_name", "team_id", "team_name", "appearances", "goals", "assists", "minutes_played", "yellow_cards", "red_cards", "goals_per_90"}
+
+func TestPlayerStatsModel_GetAll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.PlayerStatsModel{DB: db}
+
+	rows := sqlmock.NewRows(append([]string{"count"}, playerStatsColumns...)).
+		AddRow(2, 9, "Erling Haaland", 3, "Manchester City", 31, 27, 5, 2690, 1, 0, 0.9).
+		AddRow(2, 11, "Mohamed Salah", 4, "Liverpool", 38, 18, 10, 3290, 2, 0, 0.49)
+	mock.ExpectQuery(`(?s)FROM player_stats .* ORDER BY goals DESC, player_id ASC`).WithArgs(int64(5), int64(0), 20, 0).WillReturnRows(rows)
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "-goals", SortSafelist: []string{"goals", "-goals"}}
+
+	stats, metadata, err := model.GetAll(data.PlayerStatsFilter{SeasonID: 5}, filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(stats) != 2 || metadata.TotalRecords != 2 {
+		t.Fatalf("unexpected result: %+v %+v", stats, metadata)
+	}
+	if stats[0].PlayerName != "Erling Haaland" || stats[0].GoalsPer90 != 0.9 {
+		t.Errorf("unexpected first row: %+v", stats[0])
+	}
+}
+
+func TestPlayerStatsModel_Leaders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.PlayerStatsModel{DB: db}
+
+	// Two players share second place, so a top two holds three players.
+	rows := sqlmock.NewRows(append([]string{"rank"}, playerStatsColumns...)).
+		AddRow(1, 11, "Mohamed Salah", 4, "Liverpool", 38, 18, 10, 3290, 2, 0, 0.49).
+		AddRow(2, 7, "Bukayo Saka", 1, "Arsenal", 35, 16, 9, 2900, 4, 0, 0.5).
+		AddRow(2, 10, "Cole Palmer", 2, "Chelsea", 34, 22, 9, 2950, 7, 0, 0.67)
+	mock.ExpectQuery(`(?s)rank\(\) OVER \(ORDER BY assists DESC\).* WHERE assists > 0`).WithArgs(int64(0), int64(0), 2).WillReturnRows(rows)
+
+	leaders, err := model.Leaders("assists", data.PlayerStatsFilter{}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(leaders) != 3 {
+		t.Fatalf("expected 3 leaders, got %d", len(leaders))
+	}
+	if leaders[1].Rank != 2 || leaders[2].Rank != 2 || leaders[2].PlayerName != "Cole Palmer" {
+		t.Errorf("unexpected leaders: %+v %+v", leaders[1], leaders[2])
+	}
+}
+
+func TestPlayerStatsModel_LeadersUnsafeMetric(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected Leaders to panic on an unknown metric")
+		}
+	}()
+
+	data.PlayerStatsModel{}.Leaders("goals; DROP TABLE players", data.PlayerStatsFilter{}, 10)
+}
@@ -0,0 +1,287 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDuplicateShirtNumber is returned when a player is given a shirt number that is
+// already worn by somebody else in the same squad.
+var ErrDuplicateShirtNumber = errors.New("duplicate shirt number")
+
+// PlayerPositions lists the positions a player can be registered in.
+var PlayerPositions = []string{"goalkeeper", "defender", "midfielder", "forward"}
+
+type Player struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	TeamID      int64     `json:"team_id"`
+	Name        string    `json:"name"`
+	ShirtNumber int       `json:"shirt_number"`
+	Position    string    `json:"position"`
+	Nationality string    `json:"nationality"`
+	DateOfBirth Date      `json:"date_of_birth"`
+	Version     int32     `json:"version"`
+}
+
+func ValidatePlayer(v *validator.Validator, player *Player) {
+	v.Check(player.Name != "", "name", "must be provided")
+	v.Check(len(player.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(player.ShirtNumber >= 1, "shirt_number", "must be greater than zero")
+	v.Check(player.ShirtNumber <= 99, "shirt_number", "must not be greater than 99")
+
+	v.Check(validator.PermittedValue(player.Position, PlayerPositions...), "position", "must be one of goalkeeper, defender, midfielder or forward")
+
+	v.Check(player.Nationality != "", "nationality", "must be provided")
+	v.Check(len(player.Nationality) <= 60, "nationality", "must not be more than 60 bytes long")
+
+	dob := time.Time(player.DateOfBirth)
+	v.Check(!dob.IsZero(), "date_of_birth", "must be provided")
+	v.Check(dob.Before(time.Now()), "date_of_birth", "must not be in the future")
+	v.Check(dob.Year() >= 1900, "date_of_birth", "must be 1900 or later")
+}
+
+type PlayerModel struct {
+	DB *sql.DB
+}
+
+// isDuplicateShirtNumber reports whether err is a violation of the unique constraint
+// on the team_id and shirt_number columns.
+func isDuplicateShirtNumber(err error) bool {
+	return err != nil && err.Error() == `pq: duplicate key value violates unique constraint "players_team_id_shirt_number_key"`
+}
+
+// firstSpellStart is when a player's first spell at a club is taken to have started.
+// Players are often registered after they have played for the club, so the spell is
+// open-ended rather than starting on the day of registration, which would rule them
+// out of the matches they played before it. It matches the backfill in the
+// player_memberships migration.
+var firstSpellStart = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Insert registers a player with a team. The player's first spell at the club starts
+// at firstSpellStart; TransferModel.Insert() moves them on from there.
+func (m PlayerModel) Insert(player *Player) error {
+	query := `
+        WITH player AS (
+            INSERT INTO players (team_id, name, shirt_number, position, nationality, date_of_birth)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, created_at, team_id, version
+        ), membership AS (
+            INSERT INTO player_memberships (player_id, team_id, joined_on)
+            SELECT id, team_id, $7::date
+            FROM player
+        )
+        SELECT id, created_at, version
+        FROM player`
+
+	args := []any{
+		player.TeamID,
+		player.Name,
+		player.ShirtNumber,
+		player.Position,
+		player.Nationality,
+		time.Time(player.DateOfBirth),
+		firstSpellStart,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&player.ID, &player.CreatedAt, &player.Version)
+	if err != nil {
+		switch {
+		case isDuplicateShirtNumber(err):
+			return ErrDuplicateShirtNumber
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PlayerModel) Get(id int64) (*Player, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT id, created_at, team_id, name, shirt_number, position, nationality, date_of_birth, version
+        FROM players
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var player Player
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&player.ID,
+		&player.CreatedAt,
+		&player.TeamID,
+		&player.Name,
+		&player.ShirtNumber,
+		&player.Position,
+		&player.Nationality,
+		(*time.Time)(&player.DateOfBirth),
+		&player.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &player, nil
+}
+
+// Update saves a player, using the version number for optimistic concurrency control
+// in the same way as TeamModel.Update().
+func (m PlayerModel) Update(player *Player) error {
+	query := `
+        UPDATE players
+        SET name = $1, shirt_number = $2, position = $3, nationality = $4, date_of_birth = $5, version = version + 1
+        WHERE id = $6 AND version = $7
+        RETURNING version`
+
+	args := []any{
+		player.Name,
+		player.ShirtNumber,
+		player.Position,
+		player.Nationality,
+		time.Time(player.DateOfBirth),
+		player.ID,
+		player.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&player.Version)
+	if err != nil {
+		switch {
+		case isDuplicateShirtNumber(err):
+			return ErrDuplicateShirtNumber
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PlayerModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM players
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForTeam returns one page of a team's squad. The position and nationality
+// filters are exact, case-insensitive matches; empty values match every player. If
+// asOf isn't zero, the squad is the one the team had on that day, going by the
+// players' spells at the club. The players themselves are as they are now, so a
+// player who has since moved on shows their current team and shirt number.
+func (m PlayerModel) GetAllForTeam(teamID int64, position, nationality string, asOf time.Time, filters Filters) ([]*Player, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, team_id, name, shirt_number, position, nationality, date_of_birth, version
+        FROM players p
+        WHERE CASE WHEN $4::date IS NULL THEN p.team_id = $1
+            ELSE EXISTS (
+                SELECT 1
+                FROM player_memberships pm
+                WHERE pm.player_id = p.id AND pm.team_id = $1
+                AND pm.joined_on <= $4 AND (pm.left_on IS NULL OR pm.left_on > $4)
+            ) END
+        AND (LOWER(position) = LOWER($2) OR $2 = '')
+        AND (LOWER(nationality) = LOWER($3) OR $3 = '')
+        ORDER BY %s %s, id ASC
+        LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{teamID, position, nationality, nullTime(asOf), filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	players := []*Player{}
+	for rows.Next() {
+		var player Player
+		err := rows.Scan(
+			&totalRecords,
+			&player.ID,
+			&player.CreatedAt,
+			&player.TeamID,
+			&player.Name,
+			&player.ShirtNumber,
+			&player.Position,
+			&player.Nationality,
+			(*time.Time)(&player.DateOfBirth),
+			&player.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		players = append(players, &player)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return players, metadata, nil
+}
+
+type MockPlayerModel struct{}
+
+func (m MockPlayerModel) Insert(player *Player) error {
+	return nil
+}
+func (m MockPlayerModel) Get(id int64) (*Player, error) {
+	return nil, nil
+}
+func (m MockPlayerModel) Update(player *Player) error {
+	return nil
+}
+func (m MockPlayerModel) Delete(id int64) error {
+	return nil
+}
+func (m MockPlayerModel) GetAllForTeam(teamID int64, position, nationality string, asOf time.Time, filters Filters) ([]*Player, Metadata, error) {
+	return nil, Metadata{}, nil
+}
@@ -0,0 +1,146 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+func TestPlayerModel_InsertDuplicateShirtNumber(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.PlayerModel{DB: db}
+
+	dob := time.Date(1999, time.May, 7, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("INSERT INTO players").WithArgs(1, "Bukayo Saka", 7, "forward", "England", dob, sqlmock.AnyArg()).
+		WillReturnError(errors.New(`pq: duplicate key value violates unique constraint "players_team_id_shirt_number_key"`))
+
+	player := &data.Player{
+		TeamID:      1,
+		Name:        "Bukayo Saka",
+		ShirtNumber: 7,
+		Position:    "forward",
+		Nationality: "England",
+		DateOfBirth: data.Date(dob),
+	}
+
+	err = model.Insert(player)
+	if !errors.Is(err, data.ErrDuplicateShirtNumber) {
+		t.Errorf("expected ErrDuplicateShirtNumber, got %v", err)
+	}
+}
+
+// joinedBefore matches a date argument before the time it holds.
+type joinedBefore time.Time
+
+func (d joinedBefore) Match(v driver.Value) bool {
+	joined, ok := v.(time.Time)
+	return ok && joined.Before(time.Time(d))
+}
+
+func TestPlayerModel_InsertFirstSpellCoversEarlierMatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.PlayerModel{DB: db}
+
+	// The player is registered a season after a match they played for the club. Their
+	// first spell has to start before that match's kickoff, or the events they were in
+	// couldn't be recorded.
+	kickoff := time.Date(2023, time.August, 12, 12, 30, 0, 0, time.UTC)
+	registered := time.Date(2024, time.July, 1, 9, 0, 0, 0, time.UTC)
+	dob := time.Date(1999, time.May, 7, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("INSERT INTO player_memberships").
+		WithArgs(1, "Bukayo Saka", 7, "forward", "England", dob, joinedBefore(kickoff)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).AddRow(9, registered, 1))
+
+	player := &data.Player{
+		TeamID:      1,
+		Name:        "Bukayo Saka",
+		ShirtNumber: 7,
+		Position:    "forward",
+		Nationality: "England",
+		DateOfBirth: data.Date(dob),
+	}
+
+	err = model.Insert(player)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if player.ID != 9 {
+		t.Errorf("expected ID 9, got %d", player.ID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPlayerModel_GetAllForTeam(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.PlayerModel{DB: db}
+
+	rows := sqlmock.NewRows([]string{"count", "id", "created_at", "team_id", "name", "shirt_number", "position", "nationality", "date_of_birth", "version"}).
+		AddRow(1, 3, time.Now(), 1, "Bukayo Saka", 7, "forward", "England", time.Date(2001, time.September, 5, 0, 0, 0, 0, time.UTC), 1)
+	mock.ExpectQuery(`ORDER BY shirt_number ASC, id ASC`).WithArgs(1, "forward", "england", nil, 20, 0).WillReturnRows(rows)
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "shirt_number", SortSafelist: []string{"shirt_number"}}
+
+	players, metadata, err := model.GetAllForTeam(1, "forward", "england", time.Time{}, filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(players) != 1 || metadata.TotalRecords != 1 {
+		t.Fatalf("unexpected result: %+v %+v", players, metadata)
+	}
+
+	js, err := json.Marshal(players[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	json.Unmarshal(js, &decoded)
+	if decoded["date_of_birth"] != "2001-09-05" {
+		t.Errorf(`expected date_of_birth "2001-09-05", got %v`, decoded["date_of_birth"])
+	}
+}
+
+func TestValidatePlayer(t *testing.T) {
+	player := &data.Player{
+		Name:        "Bukayo Saka",
+		ShirtNumber: 100,
+		Position:    "winger",
+		Nationality: "England",
+		DateOfBirth: data.Date(time.Now().AddDate(1, 0, 0)),
+	}
+
+	v := validator.New()
+	data.ValidatePlayer(v, player)
+
+	for _, key := range []string{"shirt_number", "position", "date_of_birth"} {
+		if _, ok := v.Errors[key]; !ok {
+			t.Errorf("expected a validation error for %s", key)
+		}
+	}
+	if _, ok := v.Errors["name"]; ok {
+		t.Errorf("unexpected validation error for name: %s", v.Errors["name"])
+	}
+}
@@ -0,0 +1,481 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"slices"
+	"time"
+)
+
+const (
+	SeasonOpen   = "open"
+	SeasonClosed = "closed"
+)
+
+// RelegationPlaces is the number of teams that go down at the end of a season, and so
+// the number that must come up to replace them.
+const RelegationPlaces = 3
+
+var (
+	ErrDuplicateSeason     = errors.New("duplicate season")
+	ErrSeasonAlreadyOpen   = errors.New("another season is already open")
+	ErrSeasonClosed        = errors.New("season is closed")
+	ErrUnplayedMatches     = errors.New("season has unplayed matches")
+	ErrUnknownTeam         = errors.New("team does not exist")
+	ErrTeamAlreadyInSeason = errors.New("team is already in the season")
+)
+
+// A Season is a league season and the teams that play in it. Teams lists the
+// membership when a single season is fetched; it is left out of listings.
+type Season struct {
+	ID        int64         `json:"id"`
+	CreatedAt time.Time     `json:"-"`
+	Name      string        `json:"name"`
+	StartsOn  Date          `json:"starts_on"`
+	EndsOn    Date          `json:"ends_on"`
+	Status    string        `json:"status"`
+	ClosedAt  *time.Time    `json:"closed_at,omitempty"`
+	Teams     []*SeasonTeam `json:"teams,omitempty"`
+	Version   int32         `json:"version"`
+}
+
+// A SeasonTeam is a team's membership of a season. Promoted is set for the teams that
+// came up from the division below, and Relegated for those that went down at the end.
+type SeasonTeam struct {
+	TeamID    int64  `json:"team_id"`
+	TeamName  string `json:"team_name"`
+	Promoted  bool   `json:"promoted"`
+	Relegated bool   `json:"relegated"`
+}
+
+// Window returns the time range the season's matches kick off in: from the start of
+// its first day to the start of the day after its last, UK time.
+func (s *Season) Window() (from, to time.Time) {
+	start, end := time.Time(s.StartsOn), time.Time(s.EndsOn)
+	from = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, MatchdayLocation)
+	to = time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, MatchdayLocation)
+	return from, to
+}
+
+func ValidateSeason(v *validator.Validator, season *Season) {
+	v.Check(season.Name != "", "name", "must be provided")
+	v.Check(len(season.Name) <= 20, "name", "must not be more than 20 bytes long")
+
+	v.Check(!time.Time(season.StartsOn).IsZero(), "starts_on", "must be provided")
+	v.Check(!time.Time(season.EndsOn).IsZero(), "ends_on", "must be provided")
+	v.Check(time.Time(season.StartsOn).Before(time.Time(season.EndsOn)), "ends_on", "must be after starts_on")
+}
+
+// ValidateSeasonTeams checks a list of team IDs for the given key. A season needs
+// more teams than there are relegation places.
+func ValidateSeasonTeams(v *validator.Validator, key string, teamIDs []int64) {
+	v.Check(len(teamIDs) > RelegationPlaces, key, "must contain more than three teams")
+	v.Check(validator.Unique(teamIDs), key, "must not contain duplicate values")
+}
+
+// ValidatePromotedTeams checks the teams coming up at the end of a season: exactly one
+// for each relegation place.
+func ValidatePromotedTeams(v *validator.Validator, teamIDs []int64) {
+	v.Check(len(teamIDs) == RelegationPlaces, "promoted_team_ids", "must contain exactly three teams")
+	v.Check(validator.Unique(teamIDs), "promoted_team_ids", "must not contain duplicate values")
+}
+
+type SeasonModel struct {
+	DB    *sql.DB
+	stats staleSignal
+}
+
+// seasonInsertError maps the errors from violating the seasons table's unique
+// constraints.
+func seasonInsertError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err.Error() == `pq: duplicate key value violates unique constraint "seasons_name_key"`:
+		return ErrDuplicateSeason
+	case err.Error() == `pq: duplicate key value violates unique constraint "seasons_open_idx"`:
+		return ErrSeasonAlreadyOpen
+	default:
+		return err
+	}
+}
+
+// insertSeason writes an open season and its membership as part of tx. Every team in
+// teamIDs must exist and not be in the trash, otherwise ErrUnknownTeam is returned;
+// those in promoted are marked as promoted.
+func insertSeason(ctx context.Context, tx *sql.Tx, season *Season, teamIDs, promoted []int64) error {
+	query := `
+        INSERT INTO seasons (name, starts_on, ends_on)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at, status, version`
+
+	args := []any{season.Name, time.Time(season.StartsOn), time.Time(season.EndsOn)}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&season.ID, &season.CreatedAt, &season.Status, &season.Version)
+	if err != nil {
+		return seasonInsertError(err)
+	}
+
+	query = `
+        INSERT INTO season_teams (season_id, team_id, promoted)
+        SELECT $1, id, id = ANY($3)
+        FROM teams
+        WHERE id = ANY($2) AND deleted_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, query, season.ID, pq.Array(teamIDs), pq.Array(promoted))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(teamIDs)) {
+		return ErrUnknownTeam
+	}
+
+	return nil
+}
+
+// Insert opens a new season with the given teams.
+func (m SeasonModel) Insert(season *Season, teamIDs []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertSeason(ctx, tx, season, teamIDs, nil)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Player stats are kept per season, so matches already played within the new
+	// season's dates have to move into it.
+	m.stats.mark()
+	return nil
+}
+
+// Get fetches a season with its teams.
+func (m SeasonModel) Get(id int64) (*Season, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	return m.get(`id = $1`, id)
+}
+
+// GetByName fetches a season, with its teams, by a name such as "2024-25".
+func (m SeasonModel) GetByName(name string) (*Season, error) {
+	return m.get(`name = $1`, name)
+}
+
+func (m SeasonModel) get(where string, arg any) (*Season, error) {
+	query := `
+        SELECT id, created_at, name, starts_on, ends_on, status, closed_at, version
+        FROM seasons
+        WHERE ` + where
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var season Season
+	err := m.DB.QueryRowContext(ctx, query, arg).Scan(
+		&season.ID,
+		&season.CreatedAt,
+		&season.Name,
+		(*time.Time)(&season.StartsOn),
+		(*time.Time)(&season.EndsOn),
+		&season.Status,
+		&season.ClosedAt,
+		&season.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	season.Teams, err = seasonTeams(ctx, m.DB, season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+// seasonTeams lists the membership of a season, by team name. q is either the
+// database or a transaction.
+func seasonTeams(ctx context.Context, q queryer, seasonID int64) ([]*SeasonTeam, error) {
+	query := `
+        SELECT st.team_id, t.name, st.promoted, st.relegated
+        FROM season_teams st
+        JOIN teams t ON t.id = st.team_id
+        WHERE st.season_id = $1
+        ORDER BY t.name, st.team_id`
+
+	rows, err := q.QueryContext(ctx, query, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*SeasonTeam{}
+	for rows.Next() {
+		var team SeasonTeam
+		err := rows.Scan(&team.TeamID, &team.TeamName, &team.Promoted, &team.Relegated)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, &team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// GetAll lists seasons, newest first, without their teams.
+func (m SeasonModel) GetAll(filters Filters) ([]*Season, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), id, created_at, name, starts_on, ends_on, status, closed_at, version
+        FROM seasons
+        ORDER BY starts_on DESC, id DESC
+        LIMIT $1 OFFSET $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	seasons := []*Season{}
+	for rows.Next() {
+		var season Season
+		err := rows.Scan(
+			&totalRecords,
+			&season.ID,
+			&season.CreatedAt,
+			&season.Name,
+			(*time.Time)(&season.StartsOn),
+			(*time.Time)(&season.EndsOn),
+			&season.Status,
+			&season.ClosedAt,
+			&season.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		seasons = append(seasons, &season)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return seasons, metadata, nil
+}
+
+// A SeasonClosure is the outcome of closing a season: the closed season, its final
+// table, and the season opened in its place.
+type SeasonClosure struct {
+	Season    *Season     `json:"season"`
+	Standings []*Standing `json:"standings"`
+	Next      *Season     `json:"next_season"`
+}
+
+// Close ends an open season. It computes the final table from the season's results,
+// relegates the bottom three, and opens next with the surviving teams plus the three
+// promoted ones, all in one transaction. The season must have no matches left to play.
+func (m SeasonModel) Close(id int64, promoted []int64, next *Season) (*SeasonClosure, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the season so that it can only be closed once.
+	query := `
+        SELECT id, created_at, name, starts_on, ends_on, status, version
+        FROM seasons
+        WHERE id = $1
+        FOR UPDATE`
+
+	var season Season
+	err = tx.QueryRowContext(ctx, query, id).Scan(
+		&season.ID,
+		&season.CreatedAt,
+		&season.Name,
+		(*time.Time)(&season.StartsOn),
+		(*time.Time)(&season.EndsOn),
+		&season.Status,
+		&season.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	if season.Status != SeasonOpen {
+		return nil, ErrSeasonClosed
+	}
+
+	members, err := seasonTeams(ctx, tx, season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	memberIDs := make([]int64, 0, len(members))
+	names := make(map[int64]string, len(members))
+	for _, t := range members {
+		memberIDs = append(memberIDs, t.TeamID)
+		names[t.TeamID] = t.TeamName
+	}
+	for _, teamID := range promoted {
+		if _, ok := names[teamID]; ok {
+			return nil, ErrTeamAlreadyInSeason
+		}
+	}
+
+	from, to := season.Window()
+
+	query = `
+        SELECT count(*)
+        FROM matches
+        WHERE home_team_id = ANY($1) AND away_team_id = ANY($1)
+        AND kickoff >= $2 AND kickoff < $3
+        AND status <> 'finished'`
+
+	var unplayed int
+	err = tx.QueryRowContext(ctx, query, pq.Array(memberIDs), from, to).Scan(&unplayed)
+	if err != nil {
+		return nil, err
+	}
+	if unplayed > 0 {
+		return nil, ErrUnplayedMatches
+	}
+
+	query = `
+        SELECT m.id, m.kickoff, m.home_team_id, h.name, m.away_team_id, a.name, m.home_score, m.away_score
+        FROM matches m
+        JOIN teams h ON h.id = m.home_team_id
+        JOIN teams a ON a.id = m.away_team_id
+        WHERE m.status = 'finished'
+        AND m.home_team_id = ANY($1) AND m.away_team_id = ANY($1)
+        AND m.kickoff >= $2 AND m.kickoff < $3
+        ORDER BY m.kickoff, m.id`
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(memberIDs), from, to)
+	if err != nil {
+		return nil, err
+	}
+	results, err := scanResults(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := computeStandings(results, names)
+
+	relegated := []int64{}
+	for _, s := range standings[len(standings)-RelegationPlaces:] {
+		relegated = append(relegated, s.TeamID)
+	}
+
+	query = `
+        UPDATE season_teams
+        SET relegated = true
+        WHERE season_id = $1 AND team_id = ANY($2)`
+
+	_, err = tx.ExecContext(ctx, query, season.ID, pq.Array(relegated))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+        UPDATE seasons
+        SET status = 'closed', closed_at = NOW(), version = version + 1
+        WHERE id = $1
+        RETURNING status, closed_at, version`
+
+	err = tx.QueryRowContext(ctx, query, season.ID).Scan(&season.Status, &season.ClosedAt, &season.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	nextIDs := append([]int64{}, promoted...)
+	for _, teamID := range memberIDs {
+		if !slices.Contains(relegated, teamID) {
+			nextIDs = append(nextIDs, teamID)
+		}
+	}
+
+	err = insertSeason(ctx, tx, next, nextIDs, promoted)
+	if err != nil {
+		return nil, err
+	}
+
+	season.Teams, err = seasonTeams(ctx, tx, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	next.Teams, err = seasonTeams(ctx, tx, next.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	m.stats.mark()
+	return &SeasonClosure{Season: &season, Standings: standings, Next: next}, nil
+}
+
+type MockSeasonModel struct{}
+
+func (m MockSeasonModel) Insert(season *Season, teamIDs []int64) error {
+	return nil
+}
+func (m MockSeasonModel) Get(id int64) (*Season, error) {
+	return nil, nil
+}
+func (m MockSeasonModel) GetByName(name string) (*Season, error) {
+	return nil, nil
+}
+func (m MockSeasonModel) GetAll(filters Filters) ([]*Season, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+func (m MockSeasonModel) Close(id int64, promoted []int64, next *Season) (*SeasonClosure, error) {
+	return nil, nil
+}
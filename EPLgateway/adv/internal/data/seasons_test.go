@@ -0,0 +1,107 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"testing"
+	"time"
+)
+
+func TestSeasonModel_Close(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.SeasonModel{DB: db}
+
+	startsOn := time.Date(2024, time.August, 16, 0, 0, 0, 0, time.UTC)
+	endsOn := time.Date(2025, time.May, 25, 0, 0, 0, 0, time.UTC)
+	kickoff := time.Date(2024, time.August, 17, 14, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM seasons .* FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "name", "starts_on", "ends_on", "status", "version"}).
+			AddRow(1, time.Now(), "2024-25", startsOn, endsOn, "open", 1))
+
+	members := sqlmock.NewRows([]string{"team_id", "name", "promoted", "relegated"}).
+		AddRow(1, "Arsenal", false, false).
+		AddRow(2, "Chelsea", false, false).
+		AddRow(3, "Everton", false, false).
+		AddRow(4, "Fulham", false, false).
+		AddRow(5, "Liverpool", false, false)
+	mock.ExpectQuery(`FROM season_teams`).WithArgs(1).WillReturnRows(members)
+
+	memberIDs := pq.Array([]int64{1, 2, 3, 4, 5})
+	mock.ExpectQuery(`SELECT count\(\*\)`).WithArgs(memberIDs, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	// Only Arsenal and Liverpool have won, so Chelsea, Everton and Fulham go down.
+	// Fulham haven't played, but still finish above the two teams that lost.
+	results := sqlmock.NewRows([]string{"id", "kickoff", "home_team_id", "home_name", "away_team_id", "away_name", "home_score", "away_score"}).
+		AddRow(1, kickoff, 1, "Arsenal", 2, "Chelsea", 2, 0).
+		AddRow(2, kickoff, 5, "Liverpool", 3, "Everton", 1, 0)
+	mock.ExpectQuery(`FROM matches m`).WithArgs(memberIDs, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnRows(results)
+
+	mock.ExpectExec(`UPDATE season_teams SET relegated = true`).WithArgs(1, pq.Array([]int64{4, 3, 2})).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`UPDATE seasons`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "closed_at", "version"}).AddRow("closed", time.Now(), 2))
+
+	mock.ExpectQuery(`INSERT INTO seasons`).WithArgs("2025-26", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "status", "version"}).AddRow(2, time.Now(), "open", 1))
+	mock.ExpectExec(`INSERT INTO season_teams`).WithArgs(2, pq.Array([]int64{6, 7, 8, 1, 5}), pq.Array([]int64{6, 7, 8})).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	mock.ExpectQuery(`FROM season_teams`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"team_id", "name", "promoted", "relegated"}))
+	mock.ExpectQuery(`FROM season_teams`).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"team_id", "name", "promoted", "relegated"}))
+	mock.ExpectCommit()
+
+	next := &data.Season{
+		Name:     "2025-26",
+		StartsOn: data.Date(startsOn.AddDate(1, 0, 0)),
+		EndsOn:   data.Date(endsOn.AddDate(1, 0, 0)),
+	}
+
+	closure, err := model.Close(1, []int64{6, 7, 8}, next)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if closure.Season.Status != data.SeasonClosed || closure.Next.ID != 2 {
+		t.Errorf("unexpected closure: %+v", closure)
+	}
+	if len(closure.Standings) != 5 || closure.Standings[2].TeamName != "Fulham" {
+		t.Errorf("expected every team in the final table, got %+v", closure.Standings)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSeasonModel_CloseWithUnplayedMatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.SeasonModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM seasons .* FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "name", "starts_on", "ends_on", "status", "version"}).
+			AddRow(1, time.Now(), "2024-25", time.Now(), time.Now(), "open", 1))
+	mock.ExpectQuery(`FROM season_teams`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"team_id", "name", "promoted", "relegated"}).AddRow(1, "Arsenal", false, false))
+	mock.ExpectQuery(`SELECT count\(\*\)`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	_, err = model.Close(1, []int64{6, 7, 8}, &data.Season{Name: "2025-26"})
+	if !errors.Is(err, data.ErrUnplayedMatches) {
+		t.Errorf("expected ErrUnplayedMatches, got %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrDuplicateStadium is returned when a stadium is given the name of another one.
+	ErrDuplicateStadium = errors.New("duplicate stadium")
+	// ErrStadiumInUse is returned when a stadium that is still some team's ground is
+	// deleted.
+	ErrStadiumInUse = errors.New("stadium in use")
+	// ErrUnknownStadium is returned when a team is given a stadium that doesn't exist.
+	ErrUnknownStadium = errors.New("unknown stadium")
+)
+
+// StadiumSurfaces lists the kinds of pitch a stadium can have.
+var StadiumSurfaces = []string{"grass", "hybrid", "artificial"}
+
+// earthRadiusKm is the mean radius of the Earth, used for great-circle distances.
+const earthRadiusKm = 6371.0
+
+// A Stadium is a ground that one or more teams play their home matches at. Stadiums
+// created from the free-text stadium names teams used to have don't have a capacity,
+// opening year or coordinates until somebody fills them in; those fields are null
+// until then.
+type Stadium struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Name       string    `json:"name"`
+	City       string    `json:"city"`
+	Capacity   *int      `json:"capacity"`
+	OpenedYear *int      `json:"opened_year"`
+	Latitude   *float64  `json:"latitude"`
+	Longitude  *float64  `json:"longitude"`
+	Surface    string    `json:"surface"`
+	Version    int32     `json:"version"`
+}
+
+// A NearbyStadium is a stadium with its distance from the point that was searched
+// around.
+type NearbyStadium struct {
+	Stadium
+	DistanceKm float64 `json:"distance_km"`
+}
+
+func ValidateStadium(v *validator.Validator, stadium *Stadium) {
+	v.Check(stadium.Name != "", "name", "must be provided")
+	v.Check(len(stadium.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(stadium.City != "", "city", "must be provided")
+	v.Check(len(stadium.City) <= 100, "city", "must not be more than 100 bytes long")
+
+	v.Check(stadium.Capacity != nil, "capacity", "must be provided")
+	if stadium.Capacity != nil {
+		v.Check(*stadium.Capacity > 0, "capacity", "must be greater than zero")
+		v.Check(*stadium.Capacity <= 200_000, "capacity", "must not be greater than 200000")
+	}
+
+	v.Check(stadium.OpenedYear != nil, "opened_year", "must be provided")
+	if stadium.OpenedYear != nil {
+		v.Check(*stadium.OpenedYear >= 1800, "opened_year", "must be 1800 or later")
+		v.Check(*stadium.OpenedYear <= time.Now().Year()+10, "opened_year", "must not be more than 10 years in the future")
+	}
+
+	ValidateCoordinates(v, stadium.Latitude, stadium.Longitude, "latitude", "longitude")
+
+	v.Check(validator.PermittedValue(stadium.Surface, StadiumSurfaces...), "surface", "must be one of grass, hybrid or artificial")
+}
+
+// ValidateCoordinates checks a latitude and longitude in decimal degrees. Both must be
+// provided; the keys are the names of the input fields they came from.
+func ValidateCoordinates(v *validator.Validator, lat, lon *float64, latKey, lonKey string) {
+	v.Check(lat != nil, latKey, "must be provided")
+	if lat != nil {
+		v.Check(*lat >= -90 && *lat <= 90, latKey, "must be between -90 and 90")
+	}
+
+	v.Check(lon != nil, lonKey, "must be provided")
+	if lon != nil {
+		v.Check(*lon >= -180 && *lon <= 180, lonKey, "must be between -180 and 180")
+	}
+}
+
+type StadiumModel struct {
+	DB *sql.DB
+}
+
+// isDuplicateStadium reports whether err is a violation of the unique index on the
+// stadiums' names.
+func isDuplicateStadium(err error) bool {
+	return err != nil && err.Error() == `pq: duplicate key value violates unique constraint "stadiums_name_key"`
+}
+
+// isStadiumInUse reports whether err is a violation of the foreign key from teams to
+// their stadium.
+func isStadiumInUse(err error) bool {
+	return err != nil && err.Error() == `pq: update or delete on table "stadiums" violates foreign key constraint "teams_stadium_id_fkey" on table "teams"`
+}
+
+func (m StadiumModel) Insert(stadium *Stadium) error {
+	query := `
+        INSERT INTO stadiums (name, city, capacity, opened_year, latitude, longitude, surface)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, created_at, version`
+
+	args := []any{
+		stadium.Name,
+		stadium.City,
+		stadium.Capacity,
+		stadium.OpenedYear,
+		stadium.Latitude,
+		stadium.Longitude,
+		stadium.Surface,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&stadium.ID, &stadium.CreatedAt, &stadium.Version)
+	if err != nil {
+		switch {
+		case isDuplicateStadium(err):
+			return ErrDuplicateStadium
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+const stadiumColumns = `id, created_at, name, city, capacity, opened_year, latitude, longitude, surface, version`
+
+func scanStadium(row interface{ Scan(...any) error }, dest ...any) (*Stadium, error) {
+	var stadium Stadium
+	err := row.Scan(append(dest,
+		&stadium.ID,
+		&stadium.CreatedAt,
+		&stadium.Name,
+		&stadium.City,
+		&stadium.Capacity,
+		&stadium.OpenedYear,
+		&stadium.Latitude,
+		&stadium.Longitude,
+		&stadium.Surface,
+		&stadium.Version,
+	)...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &stadium, nil
+}
+
+func (m StadiumModel) Get(id int64) (*Stadium, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT ` + stadiumColumns + `
+        FROM stadiums
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanStadium(m.DB.QueryRowContext(ctx, query, id))
+}
+
+// GetByName looks a stadium up by its name, ignoring case.
+func (m StadiumModel) GetByName(name string) (*Stadium, error) {
+	query := `
+        SELECT ` + stadiumColumns + `
+        FROM stadiums
+        WHERE LOWER(name) = LOWER($1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanStadium(m.DB.QueryRowContext(ctx, query, name))
+}
+
+// Update saves a stadium, using the version number for optimistic concurrency control
+// in the same way as TeamModel.Update().
+func (m StadiumModel) Update(stadium *Stadium) error {
+	query := `
+        UPDATE stadiums
+        SET name = $1, city = $2, capacity = $3, opened_year = $4, latitude = $5, longitude = $6, surface = $7, version = version + 1
+        WHERE id = $8 AND version = $9
+        RETURNING version`
+
+	args := []any{
+		stadium.Name,
+		stadium.City,
+		stadium.Capacity,
+		stadium.OpenedYear,
+		stadium.Latitude,
+		stadium.Longitude,
+		stadium.Surface,
+		stadium.ID,
+		stadium.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&stadium.Version)
+	if err != nil {
+		switch {
+		case isDuplicateStadium(err):
+			return ErrDuplicateStadium
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a stadium. A stadium can't be deleted while any team, including a
+// team in the trash, has it as its ground.
+func (m StadiumModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM stadiums
+        WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case isStadiumInUse(err):
+			return ErrStadiumInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll returns one page of the stadiums whose name and city contain the given
+// filters, ignoring case. Empty filters match every stadium.
+func (m StadiumModel) GetAll(name, city string, filters Filters) ([]*Stadium, Metadata, error) {
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), `+stadiumColumns+`
+        FROM stadiums
+        WHERE (strpos(LOWER(name), LOWER($1)) > 0 OR $1 = '')
+        AND (strpos(LOWER(city), LOWER($2)) > 0 OR $2 = '')
+        ORDER BY %s %s NULLS LAST, id ASC
+        LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, city, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	stadiums := []*Stadium{}
+	for rows.Next() {
+		stadium, err := scanStadium(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		stadiums = append(stadiums, stadium)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return stadiums, metadata, nil
+}
+
+// Nearby returns the stadiums within radiusKm of a point, nearest first. Distances are
+// great-circle distances given by the haversine formula. Stadiums without coordinates
+// are never returned.
+func (m StadiumModel) Nearby(lat, lon, radiusKm float64) ([]*NearbyStadium, error) {
+	// A degree of latitude is the same length everywhere, so the latitude index can
+	// rule out most stadiums before any distance is worked out. LEAST() keeps rounding
+	// errors from taking asin() out of its domain for antipodal points.
+	query := `
+        SELECT distance_km, ` + stadiumColumns + `
+        FROM (
+            SELECT *, 2 * $4::double precision * asin(LEAST(1, sqrt(
+                       power(sin(radians(latitude - $1) / 2), 2) +
+                       cos(radians($1)) * cos(radians(latitude)) * power(sin(radians(longitude - $2) / 2), 2)
+                   ))) AS distance_km
+            FROM stadiums
+            WHERE latitude BETWEEN $1 - degrees($3 / $4) AND $1 + degrees($3 / $4)
+        ) s
+        WHERE distance_km <= $3
+        ORDER BY distance_km, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, lat, lon, radiusKm, earthRadiusKm)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stadiums := []*NearbyStadium{}
+	for rows.Next() {
+		var distance float64
+		stadium, err := scanStadium(rows, &distance)
+		if err != nil {
+			return nil, err
+		}
+		stadiums = append(stadiums, &NearbyStadium{Stadium: *stadium, DistanceKm: distance})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stadiums, nil
+}
+
+type MockStadiumModel struct{}
+
+func (m MockStadiumModel) Insert(stadium *Stadium) error {
+	return nil
+}
+func (m MockStadiumModel) Get(id int64) (*Stadium, error) {
+	return nil, nil
+}
+func (m MockStadiumModel) GetByName(name string) (*Stadium, error) {
+	return nil, nil
+}
+func (m MockStadiumModel) Update(stadium *Stadium) error {
+	return nil
+}
+func (m MockStadiumModel) Delete(id int64) error {
+	return nil
+}
+func (m MockStadiumModel) GetAll(name, city string, filters Filters) ([]*Stadium, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+func (m MockStadiumModel) Nearby(lat, lon, radiusKm float64) ([]*NearbyStadium, error) {
+	return nil, nil
+}
@@ -0,0 +1,107 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+func TestStadiumModel_Nearby(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.StadiumModel{DB: db}
+
+	rows := sqlmock.NewRows([]string{"distance_km", "id", "created_at", "name", "city", "capacity", "opened_year", "latitude", "longitude", "surface", "version"}).
+		AddRow(0.8, 2, time.Now(), "Emirates Stadium", "London", 60704, 2006, 51.555, -0.108611, "hybrid", 1).
+		AddRow(9.4, 7, time.Now(), "Stamford Bridge", "London", 40343, 1877, 51.481667, -0.191111, "hybrid", 1)
+	mock.ExpectQuery(`(?s)asin\(.*WHERE distance_km <= \$3\s+ORDER BY distance_km`).
+		WithArgs(51.5560, -0.1, 10.0, 6371.0).WillReturnRows(rows)
+
+	stadiums, err := model.Nearby(51.5560, -0.1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(stadiums) != 2 {
+		t.Fatalf("expected 2 stadiums, got %d", len(stadiums))
+	}
+	if stadiums[0].Name != "Emirates Stadium" || stadiums[0].DistanceKm != 0.8 || *stadiums[0].Capacity != 60704 {
+		t.Errorf("unexpected nearest stadium: %+v", stadiums[0])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStadiumModel_DeleteInUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.StadiumModel{DB: db}
+
+	mock.ExpectExec("^DELETE FROM stadiums").WithArgs(2).
+		WillReturnError(errors.New(`pq: update or delete on table "stadiums" violates foreign key constraint "teams_stadium_id_fkey" on table "teams"`))
+
+	err = model.Delete(2)
+	if !errors.Is(err, data.ErrStadiumInUse) {
+		t.Errorf("expected ErrStadiumInUse, got %v", err)
+	}
+}
+
+func TestValidateStadium(t *testing.T) {
+	number := func(n int) *int { return &n }
+	degrees := func(f float64) *float64 { return &f }
+
+	valid := func() data.Stadium {
+		return data.Stadium{
+			Name:       "Anfield",
+			City:       "Liverpool",
+			Capacity:   number(61276),
+			OpenedYear: number(1884),
+			Latitude:   degrees(53.430819),
+			Longitude:  degrees(-2.960828),
+			Surface:    "hybrid",
+		}
+	}
+
+	v := validator.New()
+	stadium := valid()
+	if data.ValidateStadium(v, &stadium); !v.Valid() {
+		t.Fatalf("unexpected errors: %v", v.Errors)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *data.Stadium)
+		field  string
+	}{
+		{"no capacity", func(s *data.Stadium) { s.Capacity = nil }, "capacity"},
+		{"latitude out of range", func(s *data.Stadium) { s.Latitude = degrees(91) }, "latitude"},
+		{"no longitude", func(s *data.Stadium) { s.Longitude = nil }, "longitude"},
+		{"unknown surface", func(s *data.Stadium) { s.Surface = "astroturf" }, "surface"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stadium := valid()
+			tt.change(&stadium)
+
+			v := validator.New()
+			data.ValidateStadium(v, &stadium)
+
+			if _, ok := v.Errors[tt.field]; !ok {
+				t.Errorf("expected an error for %q, got %v", tt.field, v.Errors)
+			}
+		})
+	}
+}
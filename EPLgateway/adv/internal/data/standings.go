@@ -0,0 +1,303 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSeason is returned by ParseSeason when a season isn't written like
+// "2024-25".
+var ErrInvalidSeason = errors.New(`invalid season, expected "YYYY-YY"`)
+
+// A SeasonYear is a calendar season, identified by the year it starts in. It runs from
+// the 1st of July to the 30th of June, UK time, which comfortably covers every fixture
+// from August to the end of May. Seasons that have been opened through the seasons
+// endpoints carry their own dates instead.
+type SeasonYear int
+
+// ParseSeason parses a season written as "2024-25" or "2024/25".
+func ParseSeason(s string) (SeasonYear, error) {
+	start, end, ok := strings.Cut(strings.Replace(s, "/", "-", 1), "-")
+	if !ok || len(start) != 4 || len(end) != 2 {
+		return 0, ErrInvalidSeason
+	}
+
+	year, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, ErrInvalidSeason
+	}
+	next, err := strconv.Atoi(end)
+	if err != nil || next != (year+1)%100 {
+		return 0, ErrInvalidSeason
+	}
+
+	return SeasonYear(year), nil
+}
+
+// SeasonOf returns the season that t falls in.
+func SeasonOf(t time.Time) SeasonYear {
+	t = t.In(MatchdayLocation)
+	if t.Month() < time.July {
+		return SeasonYear(t.Year() - 1)
+	}
+	return SeasonYear(t.Year())
+}
+
+func (s SeasonYear) String() string {
+	return fmt.Sprintf("%d-%02d", int(s), (int(s)+1)%100)
+}
+
+// Start returns the first moment of the season.
+func (s SeasonYear) Start() time.Time {
+	return time.Date(int(s), time.July, 1, 0, 0, 0, 0, MatchdayLocation)
+}
+
+// End returns the first moment after the season.
+func (s SeasonYear) End() time.Time {
+	return time.Date(int(s)+1, time.July, 1, 0, 0, 0, 0, MatchdayLocation)
+}
+
+// A MatchResult is the outcome of a finished match, with just the fields the league
+// table needs.
+type MatchResult struct {
+	MatchID      int64
+	Kickoff      time.Time
+	HomeTeamID   int64
+	HomeTeamName string
+	AwayTeamID   int64
+	AwayTeamName string
+	HomeScore    int
+	AwayScore    int
+}
+
+// A Standing is one row of the league table. Form holds the results of the team's
+// last five matches, most recent first, as "W", "D" or "L".
+type Standing struct {
+	Position       int      `json:"position"`
+	TeamID         int64    `json:"team_id"`
+	TeamName       string   `json:"team_name"`
+	Played         int      `json:"played"`
+	Won            int      `json:"won"`
+	Drawn          int      `json:"drawn"`
+	Lost           int      `json:"lost"`
+	GoalsFor       int      `json:"goals_for"`
+	GoalsAgainst   int      `json:"goals_against"`
+	GoalDifference int      `json:"goal_difference"`
+	Points         int      `json:"points"`
+	Form           []string `json:"form"`
+}
+
+const formLength = 5
+
+func (s *Standing) record(scored, conceded int) {
+	s.Played++
+	s.GoalsFor += scored
+	s.GoalsAgainst += conceded
+	s.GoalDifference = s.GoalsFor - s.GoalsAgainst
+
+	var result string
+	switch {
+	case scored > conceded:
+		s.Won++
+		s.Points += 3
+		result = "W"
+	case scored == conceded:
+		s.Drawn++
+		s.Points++
+		result = "D"
+	default:
+		s.Lost++
+		result = "L"
+	}
+
+	s.Form = append([]string{result}, s.Form...)
+	if len(s.Form) > formLength {
+		s.Form = s.Form[:formLength]
+	}
+}
+
+// ComputeStandings builds the league table from a list of results, which must be in
+// kickoff order for the form to be right. Teams are ranked by the Premier League
+// tie-breakers: points, goal difference and goals scored, then points and away goals
+// in the matches between the teams still level. Teams that can't be separated are
+// listed by name.
+func ComputeStandings(results []MatchResult) []*Standing {
+	return computeStandings(results, nil)
+}
+
+// computeStandings is ComputeStandings with a set of teams, by ID and name, that are
+// included even if they haven't played yet.
+func computeStandings(results []MatchResult, teams map[int64]string) []*Standing {
+	byTeam := map[int64]*Standing{}
+	team := func(id int64, name string) *Standing {
+		s, ok := byTeam[id]
+		if !ok {
+			s = &Standing{TeamID: id, TeamName: name, Form: []string{}}
+			byTeam[id] = s
+		}
+		return s
+	}
+
+	for id, name := range teams {
+		team(id, name)
+	}
+	for _, r := range results {
+		team(r.HomeTeamID, r.HomeTeamName).record(r.HomeScore, r.AwayScore)
+		team(r.AwayTeamID, r.AwayTeamName).record(r.AwayScore, r.HomeScore)
+	}
+
+	table := make([]*Standing, 0, len(byTeam))
+	for _, s := range byTeam {
+		table = append(table, s)
+	}
+
+	sort.Slice(table, func(i, j int) bool {
+		return compareOverall(table[i], table[j]) < 0
+	})
+
+	// Break the remaining ties one group of level teams at a time.
+	for i := 0; i < len(table); {
+		j := i + 1
+		for j < len(table) && compareOverall(table[i], table[j]) == 0 {
+			j++
+		}
+		if j-i > 1 {
+			breakTies(table[i:j], results)
+		}
+		i = j
+	}
+
+	for i, s := range table {
+		s.Position = i + 1
+	}
+
+	return table
+}
+
+// compareOverall orders two standings by points, goal difference and goals scored,
+// best first.
+func compareOverall(a, b *Standing) int {
+	switch {
+	case a.Points != b.Points:
+		return b.Points - a.Points
+	case a.GoalDifference != b.GoalDifference:
+		return b.GoalDifference - a.GoalDifference
+	default:
+		return b.GoalsFor - a.GoalsFor
+	}
+}
+
+// breakTies orders a group of teams that are level on points, goal difference and
+// goals scored, using a mini-table of the matches between them.
+func breakTies(group []*Standing, results []MatchResult) {
+	type headToHead struct {
+		points    int
+		awayGoals int
+	}
+
+	inGroup := map[int64]bool{}
+	for _, s := range group {
+		inGroup[s.TeamID] = true
+	}
+
+	h2h := map[int64]*headToHead{}
+	for _, s := range group {
+		h2h[s.TeamID] = &headToHead{}
+	}
+	for _, r := range results {
+		if !inGroup[r.HomeTeamID] || !inGroup[r.AwayTeamID] {
+			continue
+		}
+		home, away := h2h[r.HomeTeamID], h2h[r.AwayTeamID]
+		away.awayGoals += r.AwayScore
+		switch {
+		case r.HomeScore > r.AwayScore:
+			home.points += 3
+		case r.HomeScore < r.AwayScore:
+			away.points += 3
+		default:
+			home.points++
+			away.points++
+		}
+	}
+
+	sort.SliceStable(group, func(i, j int) bool {
+		a, b := h2h[group[i].TeamID], h2h[group[j].TeamID]
+		switch {
+		case a.points != b.points:
+			return a.points > b.points
+		case a.awayGoals != b.awayGoals:
+			return a.awayGoals > b.awayGoals
+		default:
+			return group[i].TeamName < group[j].TeamName
+		}
+	})
+}
+
+// GetResults returns the finished matches that kicked off in [from, to), in kickoff
+// order, for building the league table. For an opened season, only the matches
+// between the season's teams count, as they do when SeasonModel.Close() computes the
+// final table; a seasonID of 0 counts every match.
+func (m MatchModel) GetResults(seasonID int64, from, to time.Time) ([]MatchResult, error) {
+	query := `
+        SELECT m.id, m.kickoff, m.home_team_id, h.name, m.away_team_id, a.name, m.home_score, m.away_score
+        FROM matches m
+        JOIN teams h ON h.id = m.home_team_id
+        JOIN teams a ON a.id = m.away_team_id
+        WHERE m.status = 'finished'
+        AND m.kickoff >= $1 AND m.kickoff < $2
+        AND ($3::bigint = 0 OR (
+            m.home_team_id IN (SELECT team_id FROM season_teams WHERE season_id = $3)
+            AND m.away_team_id IN (SELECT team_id FROM season_teams WHERE season_id = $3)
+        ))
+        ORDER BY m.kickoff, m.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanResults(rows)
+}
+
+// scanResults reads the rows of a results query and closes them.
+func scanResults(rows *sql.Rows) ([]MatchResult, error) {
+	defer rows.Close()
+
+	results := []MatchResult{}
+	for rows.Next() {
+		var r MatchResult
+		err := rows.Scan(
+			&r.MatchID,
+			&r.Kickoff,
+			&r.HomeTeamID,
+			&r.HomeTeamName,
+			&r.AwayTeamID,
+			&r.AwayTeamName,
+			&r.HomeScore,
+			&r.AwayScore,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (m MockMatchModel) GetResults(seasonID int64, from, to time.Time) ([]MatchResult, error) {
+	return nil, nil
+}
@@ -0,0 +1,76 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSeason(t *testing.T) {
+	for _, s := range []string{"2024-25", "2024/25"} {
+		season, err := data.ParseSeason(s)
+		if err != nil {
+			t.Fatalf("ParseSeason(%q): unexpected error: %s", s, err)
+		}
+		if season.String() != "2024-25" {
+			t.Errorf("ParseSeason(%q) = %s", s, season)
+		}
+	}
+
+	for _, s := range []string{"2024", "2024-26", "24-25", "abcd-ef"} {
+		if _, err := data.ParseSeason(s); err == nil {
+			t.Errorf("ParseSeason(%q): expected an error", s)
+		}
+	}
+
+	may := time.Date(2025, time.May, 25, 16, 0, 0, 0, data.MatchdayLocation)
+	if got := data.SeasonOf(may).String(); got != "2024-25" {
+		t.Errorf("SeasonOf(%s) = %s", may, got)
+	}
+}
+
+func TestComputeStandings(t *testing.T) {
+	kickoff := time.Date(2024, time.August, 17, 15, 0, 0, 0, time.UTC)
+	result := func(day int, home, away int64, homeScore, awayScore int) data.MatchResult {
+		names := map[int64]string{1: "Arsenal", 2: "Chelsea", 3: "Liverpool", 4: "Everton"}
+		return data.MatchResult{
+			Kickoff:      kickoff.AddDate(0, 0, day),
+			HomeTeamID:   home,
+			HomeTeamName: names[home],
+			AwayTeamID:   away,
+			AwayTeamName: names[away],
+			HomeScore:    homeScore,
+			AwayScore:    awayScore,
+		}
+	}
+
+	// Arsenal and Chelsea finish level on points, goal difference and goals scored;
+	// Chelsea won the meeting between them, so they go above.
+	results := []data.MatchResult{
+		result(0, 1, 2, 0, 1),
+		result(0, 3, 4, 1, 1),
+		result(7, 1, 4, 1, 0),
+		result(7, 2, 3, 0, 1),
+		result(14, 3, 1, 0, 1),
+		result(14, 4, 2, 0, 1),
+	}
+
+	table := data.ComputeStandings(results)
+
+	var order []string
+	for _, s := range table {
+		order = append(order, s.TeamName)
+	}
+	if want := []string{"Chelsea", "Arsenal", "Liverpool", "Everton"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("expected order %v, got %v", want, order)
+	}
+
+	chelsea := table[0]
+	if chelsea.Position != 1 || chelsea.Played != 3 || chelsea.Points != 6 || chelsea.GoalDifference != 1 {
+		t.Errorf("unexpected standing for Chelsea: %+v", chelsea)
+	}
+	if want := []string{"W", "L", "W"}; !reflect.DeepEqual(chelsea.Form, want) {
+		t.Errorf("expected form %v, got %v", want, chelsea.Form)
+	}
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/crest"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CrestURLPrefix is the path under which the API serves crests, by blob key.
+const CrestURLPrefix = "/v1/crests/"
+
+// setCrest points the crest fields of a team at the crest stored under key. An empty
+// key clears them.
+func (t *Team) setCrest(key string) {
+	t.crestKey = key
+	t.CrestURL = ""
+	t.CrestURLs = nil
+	if key == "" {
+		return
+	}
+
+	t.CrestURL = CrestURLPrefix + key
+	t.CrestURLs = make(map[int]string, len(crest.Sizes))
+	for _, size := range crest.Sizes {
+		t.CrestURLs[size] = CrestURLPrefix + crest.VariantKey(key, size)
+	}
+}
+
+// CrestKey returns the blob key of the team's crest, or "" if it has none.
+func (t *Team) CrestKey() string {
+	return t.crestKey
+}
+
+// crestColumn scans the teams.crest column, which holds the blob key of the team's
+// crest or NULL, into the crest fields of a team.
+type crestColumn struct {
+	team *Team
+}
+
+func (c crestColumn) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		c.team.setCrest("")
+	case string:
+		c.team.setCrest(value)
+	case []byte:
+		c.team.setCrest(string(value))
+	default:
+		return fmt.Errorf("cannot scan %T into a team crest", value)
+	}
+	return nil
+}
+
+// SetCrest gives a team the crest stored under key, or removes its crest if key is
+// empty, as a new revision. It returns the key of the crest the team had before, so
+// that the caller can delete it once it is no longer used. team.Version is the
+// version the caller read; if it is no longer current, ErrEditConflict is returned.
+func (m TeamModel) SetCrest(team *Team, key string, actorID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := lockTeam(ctx, tx, team.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return "", ErrEditConflict
+		default:
+			return "", err
+		}
+	}
+	if before.DeletedAt != nil || before.Version != team.Version {
+		return "", ErrEditConflict
+	}
+
+	query := `
+        UPDATE teams
+        SET crest = NULLIF($1, ''), version = version + 1, updated_at = NOW()
+        WHERE id = $2
+        RETURNING updated_at, version`
+
+	after := *before
+	after.setCrest(key)
+	err = tx.QueryRowContext(ctx, query, key, team.ID).Scan(&after.UpdatedAt, &after.Version)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.commitChange(ctx, tx, TeamUpdated, before, &after, actorID)
+	if err != nil {
+		return "", err
+	}
+
+	*team = after
+	return before.crestKey, nil
+}
+
+func (m MockTeamModel) SetCrest(team *Team, key string, actorID int64) (string, error) {
+	return "", nil
+}
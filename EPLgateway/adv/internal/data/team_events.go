@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	TeamCreated  = "created"
+	TeamUpdated  = "updated"
+	TeamDeleted  = "deleted"
+	TeamRestored = "restored"
+)
+
+// A TeamEvent records a change made through TeamModel. Events are numbered by a
+// bigserial ID, handed out in commit order, which doubles as the resume cursor for
+// clients following the feed.
+type TeamEvent struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	TeamID    int64     `json:"team_id"`
+	Team      *Team     `json:"team"`
+}
+
+// teamEventsChannel is the channel team events are notified under. The feed isn't
+// split into topics.
+const teamEventsChannel = "team_events"
+
+// insertTeamEvent writes an event for the team as part of the caller's transaction,
+// so an event exists if and only if the change it describes was committed. The feed
+// stays locked until the transaction ends, which keeps the event IDs in commit order.
+func insertTeamEvent(ctx context.Context, tx *sql.Tx, eventType string, team *Team) error {
+	snapshot, err := json.Marshal(team)
+	if err != nil {
+		return err
+	}
+
+	err = lockFeed(ctx, tx, teamEventsChannel, 0)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO team_events (type, team_id, team)
+        VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, eventType, team.ID, snapshot)
+	return err
+}
+
+// insertTeamEvents writes one event of the same type for each of the teams in a
+// single statement, as insertTeamEvent does for one. Bulk writers call it last, just
+// before committing, so that the feed is only locked for as long as that takes.
+func insertTeamEvents(ctx context.Context, tx *sql.Tx, eventType string, teams []*Team) error {
+	ids := make([]int64, len(teams))
+	snapshots := make([]string, len(teams))
+	for i, team := range teams {
+		snapshot, err := json.Marshal(team)
+		if err != nil {
+			return err
+		}
+		ids[i], snapshots[i] = team.ID, string(snapshot)
+	}
+
+	err := lockFeed(ctx, tx, teamEventsChannel, 0)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO team_events (type, team_id, team)
+        SELECT $1, t.team_id, t.team
+        FROM unnest($2::bigint[], $3::jsonb[]) WITH ORDINALITY AS t(team_id, team, n)
+        ORDER BY t.n`
+
+	_, err = tx.ExecContext(ctx, query, eventType, pq.Array(ids), pq.Array(snapshots))
+	return err
+}
+
+type TeamEventModel struct {
+	DB  *sql.DB
+	hub *feedHub
+}
+
+// LatestID returns the ID of the most recent event, or 0 if there are none. The reader
+// of the feed starts from here.
+func (m TeamEventModel) LatestID() (int64, error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM team_events`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+	err := m.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// GetAfter returns up to limit events with an ID greater than cursor, oldest first.
+func (m TeamEventModel) GetAfter(cursor int64, limit int) ([]*TeamEvent, error) {
+	query := `
+        SELECT id, created_at, type, team_id, team
+        FROM team_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*TeamEvent{}
+	for rows.Next() {
+		var event TeamEvent
+		var snapshot []byte
+
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.Type, &event.TeamID, &snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Team = &Team{}
+		err = json.Unmarshal(snapshot, event.Team)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Stream delivers every event after cursor, or from now on if cursor is negative,
+// first from the database and then as changes are committed, until ctx is cancelled
+// or an error occurs. The events channel is closed when the stream ends; if it ended
+// because of an error, the error is sent on the error channel first.
+func (m TeamEventModel) Stream(ctx context.Context, cursor int64) (<-chan *TeamEvent, <-chan error) {
+	id := func(event *TeamEvent) int64 { return event.ID }
+	return streamFeed(ctx, m.hub, 0, cursor, id, m.GetAfter, m.LatestID)
+}
+
+type MockTeamEventModel struct{}
+
+func (m MockTeamEventModel) Stream(ctx context.Context, cursor int64) (<-chan *TeamEvent, <-chan error) {
+	events := make(chan *TeamEvent)
+	close(events)
+	return events, make(chan error)
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// TeamExportCounts are the related counts that can be added to each team in an export.
+var TeamExportCounts = []string{"players", "matches"}
+
+// teamExportBatch is the number of rows fetched from the export cursor at a time.
+const teamExportBatch = 500
+
+// A TeamExport is one team in an export. The counts are only set if they were asked
+// for.
+type TeamExport struct {
+	Team
+	Players *int `json:"players,omitempty"`
+	Matches *int `json:"matches,omitempty"`
+}
+
+// Export calls fn for every team matching the name, location and stadium filters of
+// GetAll(), sorted by filters.Sort; the page and page size are ignored. The teams are
+// read through a database cursor a batch at a time, as fn consumes them, so an export
+// of the whole catalogue is never held in memory. counts lists the TeamExportCounts to
+// include. Export stops at the first error returned by fn, and when ctx is done.
+func (m TeamModel) Export(ctx context.Context, name, location, stadium string, filters Filters, counts []string, fn func(*TeamExport) error) error {
+	// Counts that weren't asked for are selected as NULL, so the rows always have the
+	// same shape.
+	players, matches := "NULL::bigint", "NULL::bigint"
+	if slices.Contains(counts, "players") {
+		players = "(SELECT count(*) FROM players p WHERE p.team_id = t.id)"
+	}
+	if slices.Contains(counts, "matches") {
+		matches = "(SELECT count(*) FROM matches mt WHERE mt.home_team_id = t.id OR mt.away_team_id = t.id)"
+	}
+
+	query := fmt.Sprintf(`
+        DECLARE team_export NO SCROLL CURSOR FOR
+        SELECT t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name AS stadium, t.crest, t.history, t.version,
+            %s, %s
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE t.deleted_at IS NULL
+        AND (strpos(LOWER(t.name), LOWER($1)) > 0 OR $1 = '')
+        AND (strpos(LOWER(t.location), LOWER($2)) > 0 OR $2 = '')
+        AND (strpos(LOWER(s.name), LOWER($3)) > 0 OR $3 = '')
+        ORDER BY %s %s, id ASC`, players, matches, filters.sortColumn(), filters.sortDirection())
+
+	// Cursors only live as long as their transaction. A read-only one also gives the
+	// export a consistent snapshot, however long it takes.
+	tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, name, location, stadium)
+	if err != nil {
+		return err
+	}
+
+	for {
+		n, err := m.exportBatch(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+		if n < teamExportBatch {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// exportBatch fetches the next batch of rows from the export cursor and passes each
+// of them to fn. It returns the number of rows fetched.
+func (m TeamModel) exportBatch(ctx context.Context, tx *sql.Tx, fn func(*TeamExport) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH %d FROM team_export", teamExportBatch))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var team TeamExport
+		err := rows.Scan(
+			&team.ID,
+			&team.CreatedAt,
+			&team.UpdatedAt,
+			&team.Name,
+			&team.Location,
+			&team.StadiumID,
+			&team.Stadium,
+			crestColumn{&team.Team},
+			&team.History,
+			&team.Version,
+			&team.Players,
+			&team.Matches,
+		)
+		if err != nil {
+			return 0, err
+		}
+		n++
+
+		err = fn(&team)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return n, rows.Err()
+}
+
+func (m MockTeamModel) Export(ctx context.Context, name, location, stadium string, filters Filters, counts []string, fn func(*TeamExport) error) error {
+	return nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxTeamImport is the largest number of teams that can be imported at once.
+const MaxTeamImport = 5000
+
+// Import inserts teams in bulk, recording a revision and an event for each as Insert()
+// does. Everything happens in one transaction, with each team written under its own
+// savepoint: a team that can't be written, because its stadium has just been deleted,
+// doesn't stop the others. The returned slice holds the error for each team, nil for
+// the ones that were written.
+//
+// The events are written together once every team has been, so the team events feed,
+// which every change to a team waits on, is only locked for the end of the import.
+//
+// If atomic is set, nothing is committed unless every team could be written. If
+// dryRun is set, nothing is committed at all, which checks the teams against the
+// database without changing it, and without locking the feed. Either way, teams that
+// weren't committed keep the IDs they were given inside the transaction, which mean
+// nothing.
+func (m TeamModel) Import(teams []*Team, atomic, dryRun bool, actorID int64) ([]error, error) {
+	// Large imports take a while; allow for them.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second+time.Duration(len(teams))*20*time.Millisecond)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rowErrs := make([]error, len(teams))
+	failed := false
+	written := make([]*Team, 0, len(teams))
+
+	for i, team := range teams {
+		_, err := tx.ExecContext(ctx, "SAVEPOINT import_team")
+		if err != nil {
+			return nil, err
+		}
+
+		err = m.insert(ctx, tx, team)
+		if err != nil {
+			if !errors.Is(err, ErrUnknownStadium) {
+				return nil, err
+			}
+			rowErrs[i] = err
+			failed = true
+
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_team")
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			err = insertTeamRevision(ctx, tx, TeamCreated, nil, team, actorID)
+			if err != nil {
+				return nil, err
+			}
+			written = append(written, team)
+		}
+
+		_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT import_team")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun || (atomic && failed) {
+		return rowErrs, nil
+	}
+
+	if len(written) > 0 {
+		err = insertTeamEvents(ctx, tx, TeamCreated, written)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	m.events.wake(0)
+	return rowErrs, nil
+}
+
+func (m MockTeamModel) Import(teams []*Team, atomic, dryRun bool, actorID int64) ([]error, error) {
+	return make([]error, len(teams)), nil
+}
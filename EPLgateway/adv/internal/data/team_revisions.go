@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// RevisionReverted is the action recorded for a revert. Every other revision is named
+// after the event the change produced (created, updated, deleted, restored).
+const RevisionReverted = "reverted"
+
+// A TeamRevision records one change to a team: who made it, when, and the team as it
+// was before and after. The revision number is the team's version after the change.
+type TeamRevision struct {
+	TeamID    int64         `json:"team_id"`
+	Revision  int32         `json:"revision"`
+	Action    string        `json:"action"`
+	ActorID   *int64        `json:"actor_id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Before    *Team         `json:"before,omitempty"`
+	After     *Team         `json:"after"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// A FieldChange is one field that differs between the before and after snapshots of
+// a revision.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// diffTeams lists the fields that differ between two snapshots. A nil before means
+// the team was created, so every field counts as changed.
+func diffTeams(before, after *Team) []FieldChange {
+	if before == nil {
+		before = &Team{}
+	}
+
+	changes := []FieldChange{}
+	for _, f := range []struct {
+		name     string
+		from, to string
+	}{
+		{"name", before.Name, after.Name},
+		{"location", before.Location, after.Location},
+		{"stadium", before.Stadium, after.Stadium},
+		{"crest_url", before.CrestURL, after.CrestURL},
+		{"history", before.History, after.History},
+	} {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	if (before.DeletedAt == nil) != (after.DeletedAt == nil) {
+		changes = append(changes, FieldChange{Field: "deleted_at", From: before.DeletedAt, To: after.DeletedAt})
+	}
+
+	return changes
+}
+
+// insertTeamRevision writes a revision as part of the caller's transaction. An actorID
+// of 0 is stored as NULL.
+func insertTeamRevision(ctx context.Context, tx *sql.Tx, action string, before, after *Team, actorID int64) error {
+	// A created team has no before snapshot; the column is left NULL.
+	var beforeJSON any
+	if before != nil {
+		js, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeJSON = js
+	}
+
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+
+	actor := sql.NullInt64{Int64: actorID, Valid: actorID != 0}
+
+	query := `
+        INSERT INTO team_revisions (team_id, revision, action, actor_id, before, after)
+        VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = tx.ExecContext(ctx, query, after.ID, after.Version, action, actor, beforeJSON, afterJSON)
+	return err
+}
+
+// GetRevisions returns one page of a team's revisions, newest first, each with the
+// field-level changes it made. Revisions outlive the team, so this works for teams in
+// the trash and for purged teams too.
+func (m TeamModel) GetRevisions(teamID int64, filters Filters) ([]*TeamRevision, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), team_id, revision, action, actor_id, created_at, before, after
+        FROM team_revisions
+        WHERE team_id = $1
+        ORDER BY revision DESC
+        LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, teamID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*TeamRevision{}
+	for rows.Next() {
+		var revision TeamRevision
+		var beforeJSON, afterJSON []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&revision.TeamID,
+			&revision.Revision,
+			&revision.Action,
+			&revision.ActorID,
+			&revision.CreatedAt,
+			&beforeJSON,
+			&afterJSON,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if beforeJSON != nil {
+			revision.Before = &Team{}
+			err = json.Unmarshal(beforeJSON, revision.Before)
+			if err != nil {
+				return nil, Metadata{}, err
+			}
+		}
+		revision.After = &Team{}
+		err = json.Unmarshal(afterJSON, revision.After)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revision.Changes = diffTeams(revision.Before, revision.After)
+		revisions = append(revisions, &revision)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+// Revert puts a team's name, location, stadium and history back to what they were
+// after the given revision, as a new revision. If the stadium has since been deleted,
+// ErrUnknownStadium is returned. version is the version of the team the
+// caller read; if it is no longer current, ErrEditConflict is returned. Teams in the
+// trash have to be restored before they can be reverted.
+func (m TeamModel) Revert(id int64, version int32, revision int32, actorID int64) (*Team, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockTeam(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.DeletedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+	if before.Version != version {
+		return nil, ErrEditConflict
+	}
+
+	query := `
+        SELECT after
+        FROM team_revisions
+        WHERE team_id = $1 AND revision = $2`
+
+	var snapshotJSON []byte
+	err = tx.QueryRowContext(ctx, query, id, revision).Scan(&snapshotJSON)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	var snapshot Team
+	err = json.Unmarshal(snapshotJSON, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	team := *before
+	team.Name = snapshot.Name
+	team.Location = snapshot.Location
+	team.StadiumID = snapshot.StadiumID
+	team.History = snapshot.History
+
+	err = m.update(ctx, tx, &team)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.commitChange(ctx, tx, RevisionReverted, before, &team, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+func (m MockTeamModel) GetRevisions(teamID int64, filters Filters) ([]*TeamRevision, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+func (m MockTeamModel) Revert(id int64, version int32, revision int32, actorID int64) (*Team, error) {
+	return nil, nil
+}
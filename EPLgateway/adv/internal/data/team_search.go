@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+)
+
+// Headlines are highlighted with these private-use characters, which team histories
+// have no reason to contain, rather than with <mark> tags directly. The history is
+// stored as it was written, so it has to be HTML-escaped before the tags go in.
+const (
+	headlineStart = "\ue000"
+	headlineStop  = "\ue001"
+)
+
+var headlineMarks = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>")
+
+// A TeamSearchResult is a team matching a full-text search, with its rank and a
+// highlighted snippet of the history text that matched.
+type TeamSearchResult struct {
+	Team
+	Rank     float64 `json:"rank"`
+	Headline string  `json:"headline"`
+}
+
+// Search runs a full-text search over the teams' name, stadium, location and history
+// using the generated search columns of the teams and their stadiums. The query uses
+// web-search syntax, so quoted phrases ("FA Cup") and exclusions (-relegated) work as
+// users expect. Results are ordered by rank, best match first.
+func (m TeamModel) Search(q string, filters Filters) ([]*TeamSearchResult, Metadata, error) {
+	// websearch_to_tsquery() never fails on malformed input, unlike to_tsquery(), so
+	// we can pass the user's query straight through. The team's and the stadium's
+	// columns are matched as one document, so that every term and exclusion applies
+	// across both. ts_headline() marks the matching words in the history text, which
+	// are turned into <mark> tags once the rest has been escaped.
+	query := `
+        SELECT count(*) OVER(), t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name, t.crest, t.history, t.version,
+            ts_rank_cd(t.search || s.search, query) AS rank,
+            ts_headline('english', t.history, query, $4)
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id,
+            websearch_to_tsquery('english', $1) query
+        WHERE (t.search || s.search) @@ query AND t.deleted_at IS NULL
+        ORDER BY rank DESC, t.id ASC
+        LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	options := "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MaxFragments=2, MaxWords=30, MinWords=10"
+
+	rows, err := m.DB.QueryContext(ctx, query, q, filters.limit(), filters.offset(), options)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	results := []*TeamSearchResult{}
+	for rows.Next() {
+		var result TeamSearchResult
+		err := rows.Scan(
+			&totalRecords,
+			&result.ID,
+			&result.CreatedAt,
+			&result.UpdatedAt,
+			&result.Name,
+			&result.Location,
+			&result.StadiumID,
+			&result.Stadium,
+			crestColumn{&result.Team},
+			&result.History,
+			&result.Version,
+			&result.Rank,
+			&result.Headline,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		result.Headline = headlineMarks.Replace(html.EscapeString(result.Headline))
+		results = append(results, &result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return results, metadata, nil
+}
+
+func (m MockTeamModel) Search(q string, filters Filters) ([]*TeamSearchResult, Metadata, error) {
+	return nil, Metadata{}, nil
+}
@@ -0,0 +1,145 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// GetTrash returns one page of the soft-deleted teams, most recently deleted first.
+func (m TeamModel) GetTrash(filters Filters) ([]*Team, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name, t.crest, t.history, t.version, t.deleted_at
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE t.deleted_at IS NOT NULL
+        ORDER BY t.deleted_at DESC, t.id ASC
+        LIMIT $1 OFFSET $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	teams := []*Team{}
+	for rows.Next() {
+		var team Team
+		err := rows.Scan(
+			&totalRecords,
+			&team.ID,
+			&team.CreatedAt,
+			&team.UpdatedAt,
+			&team.Name,
+			&team.Location,
+			&team.StadiumID,
+			&team.Stadium,
+			crestColumn{&team},
+			&team.History,
+			&team.Version,
+			&team.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		teams = append(teams, &team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return teams, metadata, nil
+}
+
+// Restore takes a team out of the trash and returns it. It returns ErrRecordNotFound
+// if there is no soft-deleted team with the given ID, including when it has already
+// been purged.
+func (m TeamModel) Restore(id int64, actorID int64) (*Team, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockTeam(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.DeletedAt == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        UPDATE teams
+        SET deleted_at = NULL, version = version + 1, updated_at = NOW()
+        WHERE id = $1
+        RETURNING updated_at, version`
+
+	team := *before
+	team.DeletedAt = nil
+	err = tx.QueryRowContext(ctx, query, id).Scan(&team.UpdatedAt, &team.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.commitChange(ctx, tx, TeamRestored, before, &team, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &team, nil
+}
+
+// Purge permanently removes the teams that were soft-deleted before the cutoff, and
+// returns how many were removed. Teams that matches, seasons, players or transfers
+// still refer to are kept in the trash, since removing them would rewrite the history
+// of every team they played or dealt with; kept is how many of those there were.
+func (m TeamModel) Purge(cutoff time.Time) (purged, kept int64, err error) {
+	query := `
+        WITH expired AS (
+            SELECT id
+            FROM teams
+            WHERE deleted_at IS NOT NULL AND deleted_at < $1
+        ), referenced AS (
+            SELECT e.id
+            FROM expired e
+            WHERE EXISTS (SELECT 1 FROM matches WHERE home_team_id = e.id OR away_team_id = e.id)
+            OR EXISTS (SELECT 1 FROM match_events WHERE team_id = e.id)
+            OR EXISTS (SELECT 1 FROM season_teams WHERE team_id = e.id)
+            OR EXISTS (SELECT 1 FROM players WHERE team_id = e.id)
+            OR EXISTS (SELECT 1 FROM player_memberships WHERE team_id = e.id)
+            OR EXISTS (SELECT 1 FROM transfers WHERE from_team_id = e.id OR to_team_id = e.id)
+        ), purged AS (
+            DELETE FROM teams
+            WHERE id IN (SELECT id FROM expired EXCEPT SELECT id FROM referenced)
+            RETURNING id
+        )
+        SELECT (SELECT count(*) FROM purged), (SELECT count(*) FROM referenced)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, cutoff).Scan(&purged, &kept)
+	return purged, kept, err
+}
+
+func (m MockTeamModel) GetTrash(filters Filters) ([]*Team, Metadata, error) {
+	return nil, Metadata{}, nil
+}
+func (m MockTeamModel) Restore(id int64, actorID int64) (*Team, error) {
+	return nil, nil
+}
+func (m MockTeamModel) Purge(cutoff time.Time) (int64, int64, error) {
+	return 0, 0, nil
+}
@@ -2,19 +2,30 @@ package data
 
 import (
 	"adv.erakaisar.net/internal/validator"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// Stadium is the name of the team's stadium. It is read-only: teams move ground by
+// changing their StadiumID. The crest fields are read-only too, and are set through
+// SetCrest(); they are left out for teams without a crest.
 type Team struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
-	Name      string    `json:"name"`
-	Location  string    `json:"location"`
-	Stadium   string    `json:"stadium"`
-	History   string    `json:"history"`
+	ID        int64          `json:"id"`
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"-"`
+	Name      string         `json:"name"`
+	Location  string         `json:"location"`
+	StadiumID int64          `json:"stadium_id"`
+	Stadium   string         `json:"stadium"`
+	CrestURL  string         `json:"crest_url,omitempty"`
+	CrestURLs map[int]string `json:"crest_urls,omitempty"`
+	History   string         `json:"history"`
+	Version   int32          `json:"version"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty"`
+	crestKey  string
 }
 
 func ValidateTeam(v *validator.Validator, team *Team) {
@@ -24,8 +35,7 @@ func ValidateTeam(v *validator.Validator, team *Team) {
 	v.Check(team.Location != "", "location", "must be provided")
 	v.Check(len(team.Location) <= 100, "location", "must not be more than 500 bytes long")
 
-	v.Check(team.Stadium != "", "stadium", "must be provided")
-	v.Check(len(team.Stadium) <= 100, "stadium", "must not be more than 500 bytes long")
+	v.Check(team.StadiumID > 0, "stadium_id", "must be provided")
 
 	v.Check(team.History != "", "history", "must be provided")
 	v.Check(len(team.History) <= 1000, "history", "must not be more than 2000 bytes long")
@@ -33,25 +43,143 @@ func ValidateTeam(v *validator.Validator, team *Team) {
 
 // Define a MovieModel struct type which wraps a sql.DB connection pool.
 type TeamModel struct {
-	DB *sql.DB
+	DB     *sql.DB
+	events *feedHub
 }
 
-// Add a placeholder method for inserting a new record in the movies table.
-func (m TeamModel) Insert(team *Team) error {
+// Add a placeholder method for inserting a new record in the movies table. The actor
+// is the ID of the user making the change, recorded in the team's revision history;
+// pass 0 for changes that aren't made on behalf of a user.
+func (m TeamModel) Insert(team *Team, actorID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// The insert, its revision and its team_events row are written in one
+	// transaction, so that followers of the event feed never see a change that was
+	// rolled back.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.insert(ctx, tx, team)
+	if err != nil {
+		return err
+	}
+
+	return m.commitChange(ctx, tx, TeamCreated, nil, team, actorID)
+}
+
+// insert writes a new team as part of tx, filling in its stadium's name and the
+// system-generated fields.
+func (m TeamModel) insert(ctx context.Context, tx *sql.Tx, team *Team) error {
 	// Define the SQL query for inserting a new record in the movies table and returning
 	// the system-generated data.
 	query := `
-        INSERT INTO teams (name, location, stadium, history) 
+        INSERT INTO teams (name, location, stadium_id, history) 
         VALUES ($1, $2, $3, $4)
-        RETURNING id, created_at `
+        RETURNING id, created_at, updated_at, version`
 	// Create an args slice containing the values for the placeholder parameters from
 	// the movie struct. Declaring this slice immediately next to our SQL query helps to
 	// make it nice and clear *what values are being used where* in the query.
-	args := []any{team.Name, team.Location, team.Stadium, team.History}
+	args := []any{team.Name, team.Location, team.StadiumID, team.History}
+
+	stadium, err := teamStadium(ctx, tx, team.StadiumID)
+	if err != nil {
+		return err
+	}
+	team.Stadium = stadium
 	// Use the QueryRow() method to execute the SQL query on our connection pool,
 	// passing in the args slice as a variadic parameter and scanning the system
 	// generated id, created_at and version values into the movie struct.
-	return m.DB.QueryRow(query, args...).Scan(&team.ID, &team.CreatedAt)
+	return tx.QueryRowContext(ctx, query, args...).Scan(&team.ID, &team.CreatedAt, &team.UpdatedAt, &team.Version)
+}
+
+// commitChange records the revision and the event for a change inside tx, commits,
+// and only then wakes up the streams following the events.
+func (m TeamModel) commitChange(ctx context.Context, tx *sql.Tx, action string, before, after *Team, actorID int64) error {
+	err := insertTeamRevision(ctx, tx, action, before, after, actorID)
+	if err != nil {
+		return err
+	}
+
+	eventType := action
+	if action == RevisionReverted {
+		eventType = TeamUpdated
+	}
+
+	err = insertTeamEvent(ctx, tx, eventType, after)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.events.wake(0)
+	return nil
+}
+
+// teamStadium returns the name of the stadium a team is being given, and stops the
+// stadium from being deleted until tx ends. It returns ErrUnknownStadium if there is
+// no such stadium.
+func teamStadium(ctx context.Context, tx *sql.Tx, id int64) (string, error) {
+	query := `
+        SELECT name
+        FROM stadiums
+        WHERE id = $1
+        FOR KEY SHARE`
+
+	var name string
+	err := tx.QueryRowContext(ctx, query, id).Scan(&name)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrUnknownStadium
+		default:
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+// lockTeam reads a team, deleted or not, and locks its row until tx ends. Writes use
+// it to take the "before" snapshot for the revision history.
+func lockTeam(ctx context.Context, tx *sql.Tx, id int64) (*Team, error) {
+	query := `
+        SELECT t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name, t.crest, t.history, t.version, t.deleted_at
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE t.id = $1
+        FOR UPDATE OF t`
+
+	var team Team
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&team.ID,
+		&team.CreatedAt,
+		&team.UpdatedAt,
+		&team.Name,
+		&team.Location,
+		&team.StadiumID,
+		&team.Stadium,
+		crestColumn{&team},
+		&team.History,
+		&team.Version,
+		&team.DeletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &team, nil
 }
 
 // Add a placeholder method for fetching a specific record from the movies table.
@@ -65,18 +193,23 @@ func (m TeamModel) Get(id int64) (*Team, error) {
 	}
 	// Define the SQL query for retrieving the movie data.
 	query := `
-        SELECT id, created_at, name, location, stadium, history
-        FROM teams
-        WHERE id = $1`
+        SELECT t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name, t.crest, t.history, t.version
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE t.id = $1 AND t.deleted_at IS NULL`
 	// Declare a Movie struct to hold the data returned by the query.
 	var team Team
 	err := m.DB.QueryRow(query, id).Scan(
 		&team.ID,
 		&team.CreatedAt,
+		&team.UpdatedAt,
 		&team.Name,
 		&team.Location,
+		&team.StadiumID,
 		&team.Stadium,
+		crestColumn{&team},
 		&team.History,
+		&team.Version,
 	)
 	// Handle any errors. If there was no matching movie found, Scan() will return
 	// a sql.ErrNoRows error. We check for this and return our custom ErrRecordNotFound
@@ -94,109 +227,197 @@ func (m TeamModel) Get(id int64) (*Team, error) {
 }
 
 // Add a placeholder method for updating a specific record in the movies table.
-func (m TeamModel) Update(team *Team) error {
+func (m TeamModel) Update(team *Team, actorID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// The version the caller read must still be the current one. If somebody else
+	// has changed (or deleted) the team since then, we return our custom
+	// ErrEditConflict error.
+	before, err := lockTeam(ctx, tx, team.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	if before.DeletedAt != nil || before.Version != team.Version {
+		return ErrEditConflict
+	}
+
+	err = m.update(ctx, tx, team)
+	if err != nil {
+		return err
+	}
+
+	return m.commitChange(ctx, tx, TeamUpdated, before, team, actorID)
+}
+
+// update writes the editable fields of a team whose row is locked by tx, and bumps
+// its version.
+func (m TeamModel) update(ctx context.Context, tx *sql.Tx, team *Team) error {
+	stadium, err := teamStadium(ctx, tx, team.StadiumID)
+	if err != nil {
+		return err
+	}
+	team.Stadium = stadium
 	// Declare the SQL query for updating the record and returning the new version
 	// number.
 	query := `
         UPDATE teams 
-        SET name = $1, location = $2, stadium = $3, history = $4
-        WHERE id = $5
-        RETURNING id`
+        SET name = $1, location = $2, stadium_id = $3, history = $4, version = version + 1, updated_at = NOW()
+        WHERE id = $5 AND version = $6
+        RETURNING created_at, updated_at, version`
 	// Create an args slice containing the values for the placeholder parameters.
 	args := []any{
 		team.Name,
 		team.Location,
-		team.Stadium,
+		team.StadiumID,
 		team.History,
 		team.ID,
+		team.Version,
 	}
-	// Use the QueryRow() method to execute the query, passing in the args slice as a
-	// variadic parameter and scanning the new version value into the movie struct.
-	return m.DB.QueryRow(query, args...).Scan(&team.ID)
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&team.CreatedAt, &team.UpdatedAt, &team.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Add a placeholder method for deleting a specific record from the movies table.
-func (m TeamModel) Delete(id int64) error {
+func (m TeamModel) Delete(id int64, actorID int64) error {
 	// Return an ErrRecordNotFound error if the movie ID is less than 1.
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	// Construct the SQL query to delete the record.
-	query := `
-        DELETE FROM teams
-        WHERE id = $1`
-	// Execute the SQL query using the Exec() method, passing in the id variable as
-	// the value for the placeholder parameter. The Exec() method returns a sql.Result
-	// object.
-	result, err := m.DB.Exec(query, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	// Call the RowsAffected() method on the sql.Result object to get the number of rows
-	// affected by the query.
-	rowsAffected, err := result.RowsAffected()
+	defer tx.Rollback()
+	// If the teams table doesn't contain a live record with the provided ID, we
+	// return an ErrRecordNotFound error.
+	before, err := lockTeam(ctx, tx, id)
 	if err != nil {
 		return err
 	}
-	// If no rows were affected, we know that the movies table didn't contain a record
-	// with the provided ID at the moment we tried to delete it. In that case we
-	// return an ErrRecordNotFound error.
-	if rowsAffected == 0 {
+	if before.DeletedAt != nil {
 		return ErrRecordNotFound
 	}
-	return nil
+	// Teams are only soft-deleted: the row stays in the trash, along with the comments
+	// and ratings that reference it, until Purge() removes it for good.
+	query := `
+        UPDATE teams
+        SET deleted_at = NOW(), version = version + 1, updated_at = NOW()
+        WHERE id = $1
+        RETURNING deleted_at, updated_at, version`
+
+	after := *before
+	err = tx.QueryRowContext(ctx, query, id).Scan(&after.DeletedAt, &after.UpdatedAt, &after.Version)
+	if err != nil {
+		return err
+	}
+
+	return m.commitChange(ctx, tx, TeamDeleted, before, &after, actorID)
+}
+
+// GetAll returns one page of the teams matching the name, location and stadium
+// filters, together with the pagination metadata for all of the matching records.
+// An empty filter matches every team.
+func (m TeamModel) GetAll(name, location, stadium string, filters Filters) ([]*Team, Metadata, error) {
+	// The window function count(*) OVER() gives us the total number of matching
+	// records alongside each row, so we don't need a second query for the metadata.
+	// The filters are case-insensitive substring matches; strpos() is used rather
+	// than LIKE so that % and _ in the input have no special meaning. We sort on the
+	// id column as well, so that rows with equal sort values come back in a stable
+	// order from page to page. ORDER BY refers to the output columns, so sorting on
+	// stadium sorts on the stadium's name.
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), t.id, t.created_at, t.updated_at, t.name, t.location, t.stadium_id, s.name AS stadium, t.crest, t.history, t.version
+        FROM teams t
+        JOIN stadiums s ON s.id = t.stadium_id
+        WHERE t.deleted_at IS NULL
+        AND (strpos(LOWER(t.name), LOWER($1)) > 0 OR $1 = '')
+        AND (strpos(LOWER(t.location), LOWER($2)) > 0 OR $2 = '')
+        AND (strpos(LOWER(s.name), LOWER($3)) > 0 OR $3 = '')
+        ORDER BY %s %s, id ASC
+        LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{name, location, stadium, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	teams := []*Team{}
+	for rows.Next() {
+		var team Team
+		err := rows.Scan(
+			&totalRecords,
+			&team.ID,
+			&team.CreatedAt,
+			&team.UpdatedAt,
+			&team.Name,
+			&team.Location,
+			&team.StadiumID,
+			&team.Stadium,
+			crestColumn{&team},
+			&team.History,
+			&team.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		teams = append(teams, &team)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return teams, metadata, nil
 }
 
 type MockTeamModel struct{}
 
-func (m MockTeamModel) Insert(team *Team) error {
+func (m MockTeamModel) Insert(team *Team, actorID int64) error {
 	// Mock the action...
 	return nil
 }
 func (m MockTeamModel) Get(id int64) (*Team, error) {
 	return nil, nil
 }
-func (m MockTeamModel) Update(team *Team) error {
+func (m MockTeamModel) Update(team *Team, actorID int64) error {
 	// Mock the action...
 	return nil
 }
-func (m MockTeamModel) Delete(id int64) error {
+func (m MockTeamModel) Delete(id int64, actorID int64) error {
 	return nil
 }
-
-//func (m TeamModel) GetAll(name string, filters Filters) ([]*Team, error) {
-//	// Update the SQL query to include the filter conditions.
-//	query := `
-//        SELECT id, created_at, name, location, stadium, history
-//        FROM teams
-//        WHERE (LOWER(name) = LOWER($1) OR $1 = '')
-//        ORDER BY id`
-//	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-//	defer cancel()
-//	// Pass the title and genres as the placeholder parameter values.
-//	rows, err := m.DB.QueryContext(ctx, query, name)
-//	if err != nil {
-//		return nil, err
-//	}
-//	defer rows.Close()
-//	teams := []*Team{}
-//	for rows.Next() {
-//		var team Team
-//		err := rows.Scan(
-//			&team.ID,
-//			&team.CreatedAt,
-//			&team.Name,
-//			&team.Location,
-//			&team.Stadium,
-//			&team.History,
-//		)
-//		if err != nil {
-//			return nil, err
-//		}
-//		teams = append(teams, &team)
-//	}
-//	if err = rows.Err(); err != nil {
-//		return nil, err
-//	}
-//	return teams, nil
-//}
+func (m MockTeamModel) GetAll(name, location, stadium string, filters Filters) ([]*Team, Metadata, error) {
+	return nil, Metadata{}, nil
+}
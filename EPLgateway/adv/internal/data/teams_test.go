@@ -2,11 +2,26 @@ package data_test
 
 import (
 	"adv.erakaisar.net/internal/data"
+	"context"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"testing"
 	"time"
 )
 
+// lockedTeam returns the row that the write methods read (and lock) before changing
+// a team.
+func lockedTeam(id int64, version int32, deletedAt any) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "location", "stadium_id", "stadium", "crest", "history", "version", "deleted_at"}).
+		AddRow(id, time.Now(), time.Now(), "Test Team", "Location", 3, "Stadium", nil, "History", version, deletedAt)
+}
+
+// stadiumRow is the row read when a team is given stadium 3.
+func stadiumRow() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"name"}).AddRow("Stadium")
+}
+
 func TestTeamModel_Insert(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -18,21 +33,127 @@ func TestTeamModel_Insert(t *testing.T) {
 	model := data.TeamModel{DB: db}
 
 	// Expectations for the mock DB.
-	mock.ExpectExec("^INSERT INTO teams").WithArgs("Test Team", "Location", "Stadium", "History").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR KEY SHARE$").WithArgs(3).WillReturnRows(stadiumRow())
+	mock.ExpectQuery("^INSERT INTO teams").WithArgs("Test Team", "Location", 3, "History").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(1, time.Now(), time.Now(), 1))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 1, data.TeamCreated, 7, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamCreated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
 
 	// Create a new team.
 	team := &data.Team{
-		Name:     "Test Team",
-		Location: "Location",
-		Stadium:  "Stadium",
-		History:  "History",
+		Name:      "Test Team",
+		Location:  "Location",
+		StadiumID: 3,
+		History:   "History",
 	}
 
 	// Insert the team.
-	err = model.Insert(team)
+	err = model.Insert(team, 7)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
+	if team.Stadium != "Stadium" {
+		t.Errorf("expected the stadium's name to be filled in, got %q", team.Stadium)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_InsertUnknownStadium(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR KEY SHARE$").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+	mock.ExpectRollback()
+
+	team := &data.Team{Name: "Test Team", Location: "Location", StadiumID: 9, History: "History"}
+
+	err = model.Insert(team, 7)
+	if !errors.Is(err, data.ErrUnknownStadium) {
+		t.Errorf("expected ErrUnknownStadium, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_Import(t *testing.T) {
+	tests := []struct {
+		name   string
+		atomic bool
+		dryRun bool
+	}{
+		{"partial", false, false},
+		{"atomic", true, false},
+		{"dry run", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			model := data.TeamModel{DB: db}
+
+			// The first team is written; the second names a stadium that doesn't
+			// exist, so only its savepoint is rolled back. An atomic import or a dry
+			// run then writes nothing, and doesn't lock the feed; otherwise the events
+			// are written at the end.
+			mock.ExpectBegin()
+			mock.ExpectExec("^SAVEPOINT import_team$").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("FOR KEY SHARE$").WithArgs(3).WillReturnRows(stadiumRow())
+			mock.ExpectQuery("^INSERT INTO teams").WithArgs("Arsenal", "London", 3, "History").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(1, time.Now(), time.Now(), 1))
+			mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 1, data.TeamCreated, 7, nil, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec("^RELEASE SAVEPOINT import_team$").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("^SAVEPOINT import_team$").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery("FOR KEY SHARE$").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			mock.ExpectExec("^ROLLBACK TO SAVEPOINT import_team$").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("^RELEASE SAVEPOINT import_team$").WillReturnResult(sqlmock.NewResult(0, 0))
+			if tt.atomic || tt.dryRun {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamCreated, pq.Array([]int64{1}), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectCommit()
+			}
+
+			teams := []*data.Team{
+				{Name: "Arsenal", Location: "London", StadiumID: 3, History: "History"},
+				{Name: "Everton", Location: "Liverpool", StadiumID: 9, History: "History"},
+			}
+
+			rowErrs, err := model.Import(teams, tt.atomic, tt.dryRun, 7)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if rowErrs[0] != nil || !errors.Is(rowErrs[1], data.ErrUnknownStadium) {
+				t.Errorf("unexpected row errors: %v", rowErrs)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
 }
 
 func TestTeamModel_Get(t *testing.T) {
@@ -46,14 +167,17 @@ func TestTeamModel_Get(t *testing.T) {
 	model := data.TeamModel{DB: db}
 
 	// Expectations for the mock DB.
-	rows := sqlmock.NewRows([]string{"id", "created_at", "name", "location", "stadium", "history"}).
-		AddRow(1, time.Now(), "Test Team", "Location", "Stadium", "History")
+	rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "location", "stadium_id", "stadium", "crest", "history", "version"}).
+		AddRow(1, time.Now(), time.Now(), "Test Team", "Location", 3, "Stadium", "1-0a1b2c3d4e5f.png", "History", 1)
 	mock.ExpectQuery("^SELECT").WithArgs(1).WillReturnRows(rows)
 
 	// Get the team.
-	_, err = model.Get(1)
+	team, err := model.Get(1)
 	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if team.CrestURLs[64] != "/v1/crests/1-0a1b2c3d4e5f-64.png" {
+		t.Errorf("unexpected crest URLs: %v", team.CrestURLs)
 	}
 }
 
@@ -68,22 +192,113 @@ func TestTeamModel_Update(t *testing.T) {
 	model := data.TeamModel{DB: db}
 
 	// Expectations for the mock DB.
-	mock.ExpectExec("^UPDATE teams").WithArgs("Updated Team", "Location", "Stadium", "History", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 1, nil))
+	mock.ExpectQuery("FOR KEY SHARE$").WithArgs(3).WillReturnRows(stadiumRow())
+	mock.ExpectQuery("^UPDATE teams").WithArgs("Updated Team", "Location", 3, "History", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(time.Now(), time.Now(), 2))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 2, data.TeamUpdated, 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamUpdated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
 
 	// Create a new team.
 	team := &data.Team{
-		ID:       1,
-		Name:     "Updated Team",
-		Location: "Location",
-		Stadium:  "Stadium",
-		History:  "History",
+		ID:        1,
+		Name:      "Updated Team",
+		Location:  "Location",
+		StadiumID: 3,
+		History:   "History",
+		Version:   1,
 	}
 
 	// Update the team.
-	err = model.Update(team)
+	err = model.Update(team, 7)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
+	if team.Version != 2 {
+		t.Errorf("expected version 2, got %d", team.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_SetCrest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 1, nil))
+	mock.ExpectQuery("^UPDATE teams").WithArgs("1-0a1b2c3d4e5f.svg", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(time.Now(), 2))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 2, data.TeamUpdated, 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamUpdated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	team := &data.Team{ID: 1, Version: 1}
+
+	previous, err := model.SetCrest(team, "1-0a1b2c3d4e5f.svg", 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if previous != "" {
+		t.Errorf("expected no previous crest, got %q", previous)
+	}
+	if team.Version != 2 || team.Name != "Test Team" {
+		t.Errorf("expected the team to be refreshed, got %+v", team)
+	}
+	// SVG crests have no PNG variants; every size is the original.
+	if team.CrestURLs[256] != "/v1/crests/1-0a1b2c3d4e5f.svg" {
+		t.Errorf("unexpected crest URLs: %v", team.CrestURLs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_UpdateEditConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	// Somebody else has already moved the team on to version 2.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 2, nil))
+	mock.ExpectRollback()
+
+	team := &data.Team{
+		ID:        1,
+		Name:      "Updated Team",
+		Location:  "Location",
+		StadiumID: 3,
+		History:   "History",
+		Version:   1,
+	}
+
+	err = model.Update(team, 7)
+	if !errors.Is(err, data.ErrEditConflict) {
+		t.Errorf("expected ErrEditConflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestTeamModel_Delete(t *testing.T) {
@@ -97,11 +312,341 @@ func TestTeamModel_Delete(t *testing.T) {
 	model := data.TeamModel{DB: db}
 
 	// Expectations for the mock DB.
-	mock.ExpectExec("^DELETE FROM teams").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 1, nil))
+	mock.ExpectQuery("^UPDATE teams SET deleted_at = NOW()").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at", "updated_at", "version"}).AddRow(time.Now(), time.Now(), 2))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 2, data.TeamDeleted, 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamDeleted, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
 
 	// Delete the team.
-	err = model.Delete(1)
+	err = model.Delete(1, 7)
 	if err != nil {
 		t.Errorf("unexpected error: %s", err)
 	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_GetAll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	// The second page of two teams per page, sorted by name descending.
+	rows := sqlmock.NewRows([]string{"count", "id", "created_at", "updated_at", "name", "location", "stadium_id", "stadium", "crest", "history", "version"}).
+		AddRow(3, 2, time.Now(), time.Now(), "Arsenal", "London", 1, "Emirates Stadium", nil, "History", 1)
+	mock.ExpectQuery(`ORDER BY name DESC, id ASC`).WithArgs("", "london", "", 2, 2).WillReturnRows(rows)
+
+	filters := data.Filters{Page: 2, PageSize: 2, Sort: "-name", SortSafelist: []string{"name", "-name"}}
+
+	teams, metadata, err := model.GetAll("", "london", "", filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(teams) != 1 || teams[0].Name != "Arsenal" {
+		t.Errorf("unexpected teams: %+v", teams)
+	}
+
+	want := data.Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 3}
+	if metadata != want {
+		t.Errorf("expected metadata %+v, got %+v", want, metadata)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_Export(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	columns := []string{"id", "created_at", "updated_at", "name", "location", "stadium_id", "stadium", "crest", "history", "version", "players", "matches"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE team_export .*\(SELECT count\(\*\) FROM players .* NULL::bigint`).WithArgs("", "London", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("^FETCH 500 FROM team_export$").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(1, time.Now(), time.Now(), "Arsenal", "London", 1, "Emirates Stadium", nil, "History", 1, 25, nil).
+		AddRow(5, time.Now(), time.Now(), "Fulham", "London", 6, "Craven Cottage", nil, "History", 2, 24, nil))
+	mock.ExpectCommit()
+
+	filters := data.Filters{Sort: "name", SortSafelist: []string{"name"}}
+
+	var names []string
+	err = model.Export(context.Background(), "", "London", "", filters, []string{"players"}, func(team *data.TeamExport) error {
+		if team.Players == nil || team.Matches != nil {
+			t.Errorf("expected only the player count, got %+v", team)
+		}
+		names = append(names, team.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(names) != 2 {
+		t.Errorf("expected 2 teams, got %v", names)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_Search(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	rows := sqlmock.NewRows([]string{"count", "id", "created_at", "updated_at", "name", "location", "stadium_id", "stadium", "crest", "history", "version", "rank", "ts_headline"}).
+		AddRow(1, 4, time.Now(), time.Now(), "Everton", "Liverpool", 5, "Goodison Park", nil, "Founded 1878.<script>", 1, 0.1, "\ue000Founded\ue001 \ue0001878\ue001.<script>")
+	mock.ExpectQuery(`WHERE \(t.search \|\| s.search\) @@ query`).WithArgs(`"founded 1878" -relegated`, 20, 0, sqlmock.AnyArg()).WillReturnRows(rows)
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "rank", SortSafelist: []string{"rank"}}
+
+	results, metadata, err := model.Search(`"founded 1878" -relegated`, filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(results) != 1 || results[0].Name != "Everton" || results[0].Headline != "<mark>Founded</mark> <mark>1878</mark>.&lt;script&gt;" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if metadata.TotalRecords != 1 {
+		t.Errorf("expected 1 total record, got %d", metadata.TotalRecords)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_Restore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 2, time.Now()))
+	mock.ExpectQuery("^UPDATE teams SET deleted_at = NULL").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(time.Now(), 3))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 3, data.TeamRestored, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamRestored, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	team, err := model.Restore(1, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if team.DeletedAt != nil || team.Version != 3 {
+		t.Errorf("unexpected team: %+v", team)
+	}
+
+	// A team that isn't in the trash can't be restored.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(2).WillReturnRows(lockedTeam(2, 1, nil))
+	mock.ExpectRollback()
+
+	_, err = model.Restore(2, 0)
+	if !errors.Is(err, data.ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_Purge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+
+	// Teams that are still referenced are left out of the delete and counted instead.
+	mock.ExpectQuery(`(?s)referenced AS .* EXCEPT SELECT id FROM referenced`).WithArgs(cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"purged", "kept"}).AddRow(3, 2))
+
+	purged, kept, err := model.Purge(cutoff)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if purged != 3 || kept != 2 {
+		t.Errorf("got %d purged and %d kept, want 3 and 2", purged, kept)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamModel_GetRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	rows := sqlmock.NewRows([]string{"count", "team_id", "revision", "action", "actor_id", "created_at", "before", "after"}).
+		AddRow(2, 1, 2, data.TeamUpdated, 7, time.Now(),
+			[]byte(`{"id":1,"name":"Arsenal","location":"London","stadium":"Highbury","history":"H","version":1}`),
+			[]byte(`{"id":1,"name":"Arsenal","location":"London","stadium":"Emirates Stadium","history":"H","version":2}`)).
+		AddRow(2, 1, 1, data.TeamCreated, nil, time.Now(), nil,
+			[]byte(`{"id":1,"name":"Arsenal","location":"London","stadium":"Highbury","history":"H","version":1}`))
+	mock.ExpectQuery("FROM team_revisions").WithArgs(1, 20, 0).WillReturnRows(rows)
+
+	filters := data.Filters{Page: 1, PageSize: 20, Sort: "-revision", SortSafelist: []string{"-revision"}}
+
+	revisions, _, err := model.GetRevisions(1, filters)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(revisions))
+	}
+
+	changes := revisions[0].Changes
+	if len(changes) != 1 || changes[0].Field != "stadium" || changes[0].From != "Highbury" || changes[0].To != "Emirates Stadium" {
+		t.Errorf("unexpected changes for revision 2: %+v", changes)
+	}
+	if revisions[1].ActorID != nil || len(revisions[1].Changes) != 4 {
+		t.Errorf("unexpected revision 1: %+v", revisions[1])
+	}
+}
+
+func TestTeamModel_Revert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	model := data.TeamModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE OF t$").WithArgs(1).WillReturnRows(lockedTeam(1, 2, nil))
+	mock.ExpectQuery("^SELECT after FROM team_revisions").WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"after"}).
+			AddRow([]byte(`{"id":1,"name":"Old Name","location":"Location","stadium_id":3,"stadium":"Stadium","history":"History","version":1}`)))
+	mock.ExpectQuery("FOR KEY SHARE$").WithArgs(3).WillReturnRows(stadiumRow())
+	mock.ExpectQuery("^UPDATE teams").WithArgs("Old Name", "Location", 3, "History", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(time.Now(), time.Now(), 3))
+	mock.ExpectExec("^INSERT INTO team_revisions").WithArgs(1, 3, data.RevisionReverted, 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WithArgs(data.TeamUpdated, 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	team, err := model.Revert(1, 2, 1, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if team.Name != "Old Name" || team.Version != 3 {
+		t.Errorf("unexpected team: %+v", team)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTeamEventModel_StreamWakesOnCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	models := data.NewModels(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "created_at", "type", "team_id", "team"})
+	}
+	snapshot := []byte(`{"id":1,"name":"Test Team"}`)
+
+	// The topic starts at the latest event, and each stream catches up on the backlog
+	// from the database on its own.
+	mock.ExpectQuery(`SELECT COALESCE\(MAX\(id\), 0\) FROM team_events`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("FROM team_events").WithArgs(0, 100).
+			WillReturnRows(eventRows().AddRow(1, time.Now(), data.TeamCreated, 1, snapshot))
+	}
+
+	first, _ := models.TeamEvents.Stream(ctx, 0)
+	second, _ := models.TeamEvents.Stream(ctx, 0)
+	for _, events := range []<-chan *data.TeamEvent{first, second} {
+		if event := <-events; event.ID != 1 {
+			t.Fatalf("unexpected backlog event: %+v", event)
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR KEY SHARE$").WithArgs(3).WillReturnRows(stadiumRow())
+	mock.ExpectQuery("^INSERT INTO teams").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).AddRow(2, time.Now(), time.Now(), 1))
+	mock.ExpectExec("^INSERT INTO team_revisions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("team_events", 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("^INSERT INTO team_events").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	// Once the insert has committed, the topic is woken and reads what is new from
+	// the database once, picking up where it left off, for both streams.
+	mock.ExpectQuery("FROM team_events").WithArgs(1, 100).
+		WillReturnRows(eventRows().AddRow(2, time.Now(), data.TeamCreated, 2, snapshot))
+
+	err = models.Teams.Insert(&data.Team{Name: "Test Team", Location: "Location", StadiumID: 3, History: "History"}, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, events := range []<-chan *data.TeamEvent{first, second} {
+		select {
+		case event := <-events:
+			if event.ID != 2 {
+				t.Errorf("expected event 2, got %+v", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("stream wasn't woken by the commit")
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
@@ -0,0 +1,468 @@
+package data
+
+import (
+	"adv.erakaisar.net/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrDuplicateTransferWindow   = errors.New("duplicate transfer window")
+	ErrOverlappingTransferWindow = errors.New("transfer window overlaps another")
+	// ErrOutsideTransferWindow is returned for a transfer dated outside every transfer
+	// window that isn't flagged as exceptional.
+	ErrOutsideTransferWindow = errors.New("outside transfer window")
+	// ErrWrongFromTeam is returned when a transfer names a from team that isn't the
+	// player's current club.
+	ErrWrongFromTeam = errors.New("player is not at the from team")
+	// ErrTransferToSameTeam is returned for a transfer to the player's current club.
+	ErrTransferToSameTeam = errors.New("player is already at the to team")
+	// ErrTransferOutOfOrder is returned for a transfer dated on or before the day the
+	// player joined their current club. Transfers can't be back-dated past the
+	// player's last move.
+	ErrTransferOutOfOrder = errors.New("transfer is dated before the player's last move")
+)
+
+// TransferTypes lists the kinds of transfer. The end of a loan is recorded as another
+// loan transfer, back to the parent club.
+var TransferTypes = []string{"permanent", "loan", "free"}
+
+// CurrencyRX matches ISO 4217 currency codes.
+var CurrencyRX = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// A TransferWindow is a period in which clubs may register transfers.
+type TransferWindow struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	OpensOn   Date      `json:"opens_on"`
+	ClosesOn  Date      `json:"closes_on"`
+}
+
+func ValidateTransferWindow(v *validator.Validator, window *TransferWindow) {
+	v.Check(window.Name != "", "name", "must be provided")
+	v.Check(len(window.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	opens, closes := time.Time(window.OpensOn), time.Time(window.ClosesOn)
+	v.Check(!opens.IsZero(), "opens_on", "must be provided")
+	v.Check(!closes.IsZero(), "closes_on", "must be provided")
+	v.Check(!closes.Before(opens), "closes_on", "must not be before opens_on")
+}
+
+// A Transfer moves a player from one club to another. FromTeamID is the club the
+// player was at; it is filled in by Insert() when it isn't given. Fee and Currency are
+// nil for undisclosed fees. Window is the name of the transfer window the transfer
+// falls in, and is read-only; exceptional transfers outside a window have none.
+type Transfer struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	PlayerID    int64     `json:"player_id"`
+	FromTeamID  int64     `json:"from_team_id"`
+	ToTeamID    int64     `json:"to_team_id"`
+	Type        string    `json:"type"`
+	Fee         *int64    `json:"fee"`
+	Currency    *string   `json:"currency"`
+	Date        Date      `json:"date"`
+	WindowID    *int64    `json:"window_id"`
+	Window      *string   `json:"window"`
+	Exceptional bool      `json:"exceptional"`
+}
+
+func ValidateTransfer(v *validator.Validator, transfer *Transfer) {
+	v.Check(transfer.PlayerID > 0, "player_id", "must be provided")
+	v.Check(transfer.ToTeamID > 0, "to_team_id", "must be provided")
+	v.Check(transfer.FromTeamID >= 0, "from_team_id", "must be a positive integer")
+	v.Check(transfer.FromTeamID == 0 || transfer.FromTeamID != transfer.ToTeamID, "to_team_id", "must be different from from_team_id")
+
+	v.Check(validator.PermittedValue(transfer.Type, TransferTypes...), "type", "must be one of permanent, loan or free")
+
+	if transfer.Fee != nil {
+		v.Check(*transfer.Fee >= 0, "fee", "must not be negative")
+		v.Check(transfer.Type != "free" || *transfer.Fee == 0, "fee", "must be zero or omitted for a free transfer")
+		v.Check(transfer.Currency != nil, "currency", "must be provided with a fee")
+	}
+	if transfer.Currency != nil {
+		v.Check(transfer.Fee != nil, "fee", "must be provided with a currency")
+		v.Check(validator.Matches(*transfer.Currency, CurrencyRX), "currency", "must be a three-letter ISO 4217 code")
+	}
+
+	date := time.Time(transfer.Date)
+	v.Check(!date.IsZero(), "date", "must be provided")
+	v.Check(!date.After(time.Now()), "date", "must not be in the future")
+	v.Check(date.Year() >= 1900, "date", "must be 1900 or later")
+}
+
+type TransferModel struct {
+	DB *sql.DB
+}
+
+// InsertWindow adds a transfer window. Windows can't overlap, so that each transfer
+// falls in at most one.
+func (m TransferModel) InsertWindow(window *TransferWindow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Stop two overlapping windows from being added at the same time.
+	_, err = tx.ExecContext(ctx, "LOCK TABLE transfer_windows IN SHARE ROW EXCLUSIVE MODE")
+	if err != nil {
+		return err
+	}
+
+	query := `
+        SELECT EXISTS (
+            SELECT 1
+            FROM transfer_windows
+            WHERE opens_on <= $2 AND closes_on >= $1
+        )`
+
+	var overlaps bool
+	err = tx.QueryRowContext(ctx, query, time.Time(window.OpensOn), time.Time(window.ClosesOn)).Scan(&overlaps)
+	if err != nil {
+		return err
+	}
+	if overlaps {
+		return ErrOverlappingTransferWindow
+	}
+
+	query = `
+        INSERT INTO transfer_windows (name, opens_on, closes_on)
+        VALUES ($1, $2, $3)
+        RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, query, window.Name, time.Time(window.OpensOn), time.Time(window.ClosesOn)).Scan(&window.ID, &window.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "transfer_windows_name_key"`:
+			return ErrDuplicateTransferWindow
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetWindows lists the transfer windows, most recent first.
+func (m TransferModel) GetWindows() ([]*TransferWindow, error) {
+	query := `
+        SELECT id, created_at, name, opens_on, closes_on
+        FROM transfer_windows
+        ORDER BY opens_on DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []*TransferWindow{}
+	for rows.Next() {
+		var window TransferWindow
+		err := rows.Scan(
+			&window.ID,
+			&window.CreatedAt,
+			&window.Name,
+			(*time.Time)(&window.OpensOn),
+			(*time.Time)(&window.ClosesOn),
+		)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, &window)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return windows, nil
+}
+
+// Insert records a transfer and moves the player to the new club: their current spell
+// ends on the day of the transfer and a new one starts. shirtNumber is the player's
+// number at the new club; 0 keeps the one they have, and ErrDuplicateShirtNumber is
+// returned if it is taken there. The player must exist (ErrRecordNotFound), as must
+// the club they move to (ErrUnknownTeam).
+func (m TransferModel) Insert(transfer *Transfer, shirtNumber int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the player's current spell, so that two transfers of the same player
+	// can't both end it.
+	query := `
+        SELECT p.team_id, pm.joined_on
+        FROM players p
+        JOIN player_memberships pm ON pm.player_id = p.id AND pm.left_on IS NULL
+        WHERE p.id = $1
+        FOR UPDATE`
+
+	var currentTeamID int64
+	var joinedOn time.Time
+	err = tx.QueryRowContext(ctx, query, transfer.PlayerID).Scan(&currentTeamID, &joinedOn)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	switch {
+	case transfer.FromTeamID != 0 && transfer.FromTeamID != currentTeamID:
+		return ErrWrongFromTeam
+	case transfer.ToTeamID == currentTeamID:
+		return ErrTransferToSameTeam
+	case !time.Time(transfer.Date).After(joinedOn):
+		return ErrTransferOutOfOrder
+	}
+	transfer.FromTeamID = currentTeamID
+
+	query = `
+        SELECT id
+        FROM teams
+        WHERE id = $1 AND deleted_at IS NULL
+        FOR KEY SHARE`
+
+	var toTeamID int64
+	err = tx.QueryRowContext(ctx, query, transfer.ToTeamID).Scan(&toTeamID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrUnknownTeam
+		default:
+			return err
+		}
+	}
+
+	query = `
+        SELECT id, name
+        FROM transfer_windows
+        WHERE $1 BETWEEN opens_on AND closes_on`
+
+	transfer.WindowID, transfer.Window = nil, nil
+	var windowID int64
+	var window string
+	err = tx.QueryRowContext(ctx, query, time.Time(transfer.Date)).Scan(&windowID, &window)
+	switch {
+	case err == nil:
+		transfer.WindowID, transfer.Window = &windowID, &window
+	case errors.Is(err, sql.ErrNoRows):
+		if !transfer.Exceptional {
+			return ErrOutsideTransferWindow
+		}
+	default:
+		return err
+	}
+
+	query = `
+        UPDATE player_memberships
+        SET left_on = $2
+        WHERE player_id = $1 AND left_on IS NULL`
+
+	_, err = tx.ExecContext(ctx, query, transfer.PlayerID, time.Time(transfer.Date))
+	if err != nil {
+		return err
+	}
+
+	query = `
+        INSERT INTO player_memberships (player_id, team_id, joined_on)
+        VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, transfer.PlayerID, transfer.ToTeamID, time.Time(transfer.Date))
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE players
+        SET team_id = $2, shirt_number = COALESCE(NULLIF($3, 0), shirt_number), version = version + 1
+        WHERE id = $1`
+
+	_, err = tx.ExecContext(ctx, query, transfer.PlayerID, transfer.ToTeamID, shirtNumber)
+	if err != nil {
+		switch {
+		case isDuplicateShirtNumber(err):
+			return ErrDuplicateShirtNumber
+		default:
+			return err
+		}
+	}
+
+	query = `
+        INSERT INTO transfers (player_id, from_team_id, to_team_id, type, fee, currency, transferred_on, window_id, exceptional)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at`
+
+	args := []any{
+		transfer.PlayerID,
+		transfer.FromTeamID,
+		transfer.ToTeamID,
+		transfer.Type,
+		transfer.Fee,
+		transfer.Currency,
+		time.Time(transfer.Date),
+		transfer.WindowID,
+		transfer.Exceptional,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// transferColumns are the columns of a transfer, in the order scanTransfer() reads
+// them. The window name comes from a LEFT JOIN on transfer_windows w.
+const transferColumns = `t.id, t.created_at, t.player_id, t.from_team_id, t.to_team_id, t.type, t.fee, t.currency,
+            t.transferred_on AS date, t.window_id, w.name AS window, t.exceptional`
+
+func scanTransfer(row interface{ Scan(...any) error }, transfer *Transfer, dest ...any) error {
+	return row.Scan(append(dest,
+		&transfer.ID,
+		&transfer.CreatedAt,
+		&transfer.PlayerID,
+		&transfer.FromTeamID,
+		&transfer.ToTeamID,
+		&transfer.Type,
+		&transfer.Fee,
+		&transfer.Currency,
+		(*time.Time)(&transfer.Date),
+		&transfer.WindowID,
+		&transfer.Window,
+		&transfer.Exceptional,
+	)...)
+}
+
+func (m TransferModel) Get(id int64) (*Transfer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+        SELECT ` + transferColumns + `
+        FROM transfers t
+        LEFT JOIN transfer_windows w ON w.id = t.window_id
+        WHERE t.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var transfer Transfer
+	err := scanTransfer(m.DB.QueryRowContext(ctx, query, id), &transfer)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &transfer, nil
+}
+
+// TransferFilter narrows down GetAll(). Zero values match every transfer. A team
+// matches transfers both to and from it; From and To are inclusive dates.
+type TransferFilter struct {
+	PlayerID int64
+	TeamID   int64
+	WindowID int64
+	Type     string
+	From     time.Time
+	To       time.Time
+}
+
+// GetAll returns one page of the transfers matching the filter.
+func (m TransferModel) GetAll(filter TransferFilter, filters Filters) ([]*Transfer, Metadata, error) {
+	// As in MatchModel.GetAll(), nullable parameters keep a single query for every
+	// combination of filters. ORDER BY refers to the output columns, so sorting on
+	// date sorts on transferred_on.
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), `+transferColumns+`
+        FROM transfers t
+        LEFT JOIN transfer_windows w ON w.id = t.window_id
+        WHERE ($1 = 0 OR t.player_id = $1)
+        AND ($2 = 0 OR t.from_team_id = $2 OR t.to_team_id = $2)
+        AND ($3 = 0 OR t.window_id = $3)
+        AND ($4 = '' OR t.type = $4)
+        AND ($5::date IS NULL OR t.transferred_on >= $5)
+        AND ($6::date IS NULL OR t.transferred_on <= $6)
+        ORDER BY %s %s, id ASC
+        LIMIT $7 OFFSET $8`, filters.sortColumn(), filters.sortDirection())
+
+	args := []any{
+		filter.PlayerID,
+		filter.TeamID,
+		filter.WindowID,
+		filter.Type,
+		nullTime(filter.From),
+		nullTime(filter.To),
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	transfers := []*Transfer{}
+	for rows.Next() {
+		var transfer Transfer
+		err := scanTransfer(rows, &transfer, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		transfers = append(transfers, &transfer)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return transfers, metadata, nil
+}
+
+type MockTransferModel struct{}
+
+func (m MockTransferModel) InsertWindow(window *TransferWindow) error {
+	return nil
+}
+func (m MockTransferModel) GetWindows() ([]*TransferWindow, error) {
+	return nil, nil
+}
+func (m MockTransferModel) Insert(transfer *Transfer, shirtNumber int) error {
+	return nil
+}
+func (m MockTransferModel) Get(id int64) (*Transfer, error) {
+	return nil, nil
+}
+func (m MockTransferModel) GetAll(filter TransferFilter, filters Filters) ([]*Transfer, Metadata, error) {
+	return nil, Metadata{}, nil
+}
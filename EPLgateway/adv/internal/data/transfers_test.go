@@ -0,0 +1,155 @@
+package data_test
+
+import (
+	"adv.erakaisar.net/internal/data"
+	"adv.erakaisar.net/internal/validator"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"testing"
+	"time"
+)
+
+func TestTransferModel_Insert(t *testing.T) {
+	joined := time.Date(2020, time.July, 1, 0, 0, 0, 0, time.UTC)
+	inWindow := time.Date(2023, time.August, 10, 0, 0, 0, 0, time.UTC)
+	outsideWindow := time.Date(2023, time.October, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		transfer data.Transfer
+		setup    func(mock sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name:     "unknown player",
+			transfer: data.Transfer{PlayerID: 9, ToTeamID: 2, Type: "permanent", Date: data.Date(inWindow)},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM players p").WithArgs(9).
+					WillReturnRows(sqlmock.NewRows([]string{"team_id", "joined_on"}))
+				mock.ExpectRollback()
+			},
+			wantErr: data.ErrRecordNotFound,
+		},
+		{
+			name:     "wrong from team",
+			transfer: data.Transfer{PlayerID: 1, FromTeamID: 3, ToTeamID: 2, Type: "permanent", Date: data.Date(inWindow)},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM players p").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"team_id", "joined_on"}).AddRow(1, joined))
+				mock.ExpectRollback()
+			},
+			wantErr: data.ErrWrongFromTeam,
+		},
+		{
+			name:     "before the player joined",
+			transfer: data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "permanent", Date: data.Date(joined)},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM players p").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"team_id", "joined_on"}).AddRow(1, joined))
+				mock.ExpectRollback()
+			},
+			wantErr: data.ErrTransferOutOfOrder,
+		},
+		{
+			name:     "outside every window",
+			transfer: data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "permanent", Date: data.Date(outsideWindow)},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM players p").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"team_id", "joined_on"}).AddRow(1, joined))
+				mock.ExpectQuery("FROM teams").WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectQuery("FROM transfer_windows").WithArgs(outsideWindow).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+				mock.ExpectRollback()
+			},
+			wantErr: data.ErrOutsideTransferWindow,
+		},
+		{
+			name:     "inside a window",
+			transfer: data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "permanent", Date: data.Date(inWindow)},
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery("FROM players p").WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"team_id", "joined_on"}).AddRow(1, joined))
+				mock.ExpectQuery("FROM teams").WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+				mock.ExpectQuery("FROM transfer_windows").WithArgs(inWindow).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Summer 2023"))
+				mock.ExpectExec("UPDATE player_memberships").WithArgs(1, inWindow).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO player_memberships").WithArgs(1, 2, inWindow).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("UPDATE players").WithArgs(1, 2, 0).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery("INSERT INTO transfers").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+				mock.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			tt.setup(mock)
+
+			model := data.TransferModel{DB: db}
+			transfer := tt.transfer
+			err = model.Insert(&transfer, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && (transfer.ID != 7 || transfer.FromTeamID != 1 || transfer.WindowID == nil || *transfer.WindowID != 4) {
+				t.Errorf("unexpected transfer %+v", transfer)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestValidateTransfer(t *testing.T) {
+	fee := int64(1000000)
+	gbp, bad := "GBP", "pounds"
+	date := data.Date(time.Date(2023, time.August, 10, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		transfer data.Transfer
+		field    string
+	}{
+		{"valid", data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "permanent", Fee: &fee, Currency: &gbp, Date: date}, ""},
+		{"fee without currency", data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "permanent", Fee: &fee, Date: date}, "currency"},
+		{"bad currency", data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "loan", Fee: &fee, Currency: &bad, Date: date}, "currency"},
+		{"free with a fee", data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "free", Fee: &fee, Currency: &gbp, Date: date}, "fee"},
+		{"same team", data.Transfer{PlayerID: 1, FromTeamID: 2, ToTeamID: 2, Type: "loan", Date: date}, "to_team_id"},
+		{"future", data.Transfer{PlayerID: 1, ToTeamID: 2, Type: "loan", Date: data.Date(time.Now().AddDate(0, 1, 0))}, "date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateTransfer(v, &tt.transfer)
+			if tt.field == "" {
+				if !v.Valid() {
+					t.Errorf("expected no errors, got %v", v.Errors)
+				}
+				return
+			}
+			if _, ok := v.Errors[tt.field]; !ok {
+				t.Errorf("expected an error for %q, got %v", tt.field, v.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Package fixtures draws up double round-robin schedules: every team plays every
+// other once at home and once away. Schedules are built with the circle method,
+// oriented so that teams alternate between home and away as far as possible, and
+// laid out so that two teams sharing a stadium are never at home in the same round.
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrTooFewTeams is returned when there are fewer than two teams to schedule.
+	ErrTooFewTeams = errors.New("at least two teams are needed")
+	// ErrDuplicateTeam is returned when a team is listed more than once.
+	ErrDuplicateTeam = errors.New("teams must not be listed more than once")
+	// ErrNotEnoughMatchdays is returned when the rounds don't fit before the last
+	// day allowed, once blackout dates are skipped.
+	ErrNotEnoughMatchdays = errors.New("not enough matchdays for every round")
+)
+
+// A StadiumConflictError is returned when the teams sharing a stadium can't all be
+// kept from being at home in the same round. Only two teams can share a stadium.
+type StadiumConflictError struct {
+	StadiumID int64
+	TeamIDs   []int64
+}
+
+func (e *StadiumConflictError) Error() string {
+	return fmt.Sprintf("teams %v share stadium %d and can't be scheduled so that only one of them is at home each round", e.TeamIDs, e.StadiumID)
+}
+
+// A Team is a team to schedule. Teams with the same non-zero StadiumID share a
+// stadium.
+type Team struct {
+	ID        int64
+	StadiumID int64
+}
+
+// A Fixture is one match of a round.
+type Fixture struct {
+	HomeTeamID int64
+	AwayTeamID int64
+}
+
+// maxConsecutiveHome is the longest run of home matches a team may have.
+const maxConsecutiveHome = 2
+
+// Generate draws up a double round-robin between teams, returning its rounds in
+// order. With n teams there are 2(n-1) rounds of n/2 matches; with an odd number of
+// teams one of them sits each round out. The second half of the season replays the
+// first with home and away swapped, starting from its second round so that no two
+// teams meet in consecutive rounds. No team plays more than two home matches in a
+// row. seed picks which schedule is drawn from those that meet the constraints.
+func Generate(teams []Team, seed int64) ([][]Fixture, error) {
+	if len(teams) < 2 {
+		return nil, ErrTooFewTeams
+	}
+	seen := map[int64]bool{}
+	for _, t := range teams {
+		if seen[t.ID] {
+			return nil, ErrDuplicateTeam
+		}
+		seen[t.ID] = true
+	}
+
+	pairs, singles, err := stadiumGroups(teams)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := len(teams)
+	if slots%2 == 1 {
+		// The team drawn against the extra slot rests that round.
+		slots++
+	}
+	schedule := circle(slots)
+	complements := complementarySlots(schedule, slots)
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+	rng.Shuffle(len(singles), func(i, j int) { singles[i], singles[j] = singles[j], singles[i] })
+	rng.Shuffle(len(complements), func(i, j int) { complements[i], complements[j] = complements[j], complements[i] })
+
+	// With an odd number of teams, any slot could be the rest slot. Not every choice
+	// keeps the runs of home matches short once the rests are skipped, so each is
+	// tried in turn.
+	byes := []int{-1}
+	if slots > len(teams) {
+		byes = rng.Perm(slots)
+	}
+
+	var lastErr error
+	for _, bye := range byes {
+		assigned, err := assignSlots(slots, complements, pairs, singles, bye)
+		if err != nil {
+			return nil, err
+		}
+
+		rounds := make([][]Fixture, len(schedule))
+		for r, round := range schedule {
+			for _, f := range round {
+				home, away := assigned[f[0]], assigned[f[1]]
+				if home == 0 || away == 0 {
+					continue
+				}
+				rounds[r] = append(rounds[r], Fixture{HomeTeamID: home, AwayTeamID: away})
+			}
+		}
+
+		lastErr = Check(rounds, teams)
+		if lastErr == nil {
+			return rounds, nil
+		}
+	}
+
+	return nil, lastErr
+}
+
+// circle builds a double round-robin between slots 0 to n-1, n even, as pairs of
+// home and away slots. The last slot stays put while the others rotate around it;
+// the orientation of each match alternates with its distance across the circle,
+// which keeps each half down to n-2 breaks, the fewest possible.
+func circle(n int) [][][2]int {
+	m := n - 1
+	first := make([][][2]int, m)
+	for r := 0; r < m; r++ {
+		if r%2 == 0 {
+			first[r] = append(first[r], [2]int{n - 1, r})
+		} else {
+			first[r] = append(first[r], [2]int{r, n - 1})
+		}
+		for k := 1; k < n/2; k++ {
+			a, b := (r+k)%m, (r-k+m)%m
+			if k%2 == 1 {
+				first[r] = append(first[r], [2]int{a, b})
+			} else {
+				first[r] = append(first[r], [2]int{b, a})
+			}
+		}
+	}
+
+	rounds := slices.Clone(first)
+	for r := 0; r < m; r++ {
+		var second [][2]int
+		for _, f := range first[(r+1)%m] {
+			second = append(second, [2]int{f[1], f[0]})
+		}
+		rounds = append(rounds, second)
+	}
+	return rounds
+}
+
+// complementarySlots pairs up slots that are never at home in the same round, and so
+// can be given two teams that share a stadium.
+func complementarySlots(schedule [][][2]int, n int) [][2]int {
+	home := make([][]bool, n)
+	for s := range home {
+		home[s] = make([]bool, len(schedule))
+	}
+	for r, round := range schedule {
+		for _, f := range round {
+			home[f[0]][r] = true
+		}
+	}
+
+	matched := make([]bool, n)
+	var pairs [][2]int
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n && !matched[a]; b++ {
+			if matched[b] {
+				continue
+			}
+			complementary := true
+			for r := range schedule {
+				if home[a][r] == home[b][r] {
+					complementary = false
+					break
+				}
+			}
+			if complementary {
+				matched[a], matched[b] = true, true
+				pairs = append(pairs, [2]int{a, b})
+			}
+		}
+	}
+	return pairs
+}
+
+// stadiumGroups splits teams into pairs that share a stadium and teams that don't.
+func stadiumGroups(teams []Team) ([][2]int64, []int64, error) {
+	byStadium := map[int64][]int64{}
+	var order []int64
+	for _, t := range teams {
+		if t.StadiumID == 0 {
+			continue
+		}
+		if _, ok := byStadium[t.StadiumID]; !ok {
+			order = append(order, t.StadiumID)
+		}
+		byStadium[t.StadiumID] = append(byStadium[t.StadiumID], t.ID)
+	}
+
+	shared := map[int64]bool{}
+	var pairs [][2]int64
+	for _, stadiumID := range order {
+		ids := byStadium[stadiumID]
+		switch {
+		case len(ids) > 2:
+			return nil, nil, &StadiumConflictError{StadiumID: stadiumID, TeamIDs: ids}
+		case len(ids) == 2:
+			pairs = append(pairs, [2]int64{ids[0], ids[1]})
+			shared[ids[0]], shared[ids[1]] = true, true
+		}
+	}
+
+	var singles []int64
+	for _, t := range teams {
+		if !shared[t.ID] {
+			singles = append(singles, t.ID)
+		}
+	}
+	return pairs, singles, nil
+}
+
+// assignSlots gives each team a slot, putting the teams of each stadium-sharing pair
+// in complementary slots. bye is the slot left empty, or -1 if there isn't one. The
+// result maps slots to team IDs, with 0 for the bye.
+func assignSlots(n int, complements [][2]int, pairs [][2]int64, singles []int64, bye int) ([]int64, error) {
+	assigned := make([]int64, n)
+	var free []int
+
+	i := 0
+	for _, c := range complements {
+		if i < len(pairs) && c[0] != bye && c[1] != bye {
+			assigned[c[0]], assigned[c[1]] = pairs[i][0], pairs[i][1]
+			i++
+			continue
+		}
+		free = append(free, c[0], c[1])
+	}
+	if i < len(pairs) {
+		return nil, &StadiumConflictError{TeamIDs: pairs[i][:]}
+	}
+
+	// Slots that aren't in a complementary pair are still free for other teams.
+	inPair := make([]bool, n)
+	for _, c := range complements {
+		inPair[c[0]], inPair[c[1]] = true, true
+	}
+	for s := 0; s < n; s++ {
+		if !inPair[s] {
+			free = append(free, s)
+		}
+	}
+
+	j := 0
+	for _, s := range free {
+		if s == bye {
+			continue
+		}
+		assigned[s] = singles[j]
+		j++
+	}
+	return assigned, nil
+}
+
+// Check verifies that rounds are a double round-robin between teams: each team plays
+// each other once at home and once away, at most once a round, never more than two
+// home matches in a row, and never at home in the same round as a team it shares a
+// stadium with.
+func Check(rounds [][]Fixture, teams []Team) error {
+	stadium := map[int64]int64{}
+	for _, t := range teams {
+		stadium[t.ID] = t.StadiumID
+	}
+
+	played := map[Fixture]bool{}
+	homeRun := map[int64]int{}
+	for r, round := range rounds {
+		playing := map[int64]bool{}
+		homeAt := map[int64]int64{}
+		for _, f := range round {
+			for _, id := range []int64{f.HomeTeamID, f.AwayTeamID} {
+				if _, ok := stadium[id]; !ok {
+					return fmt.Errorf("round %d: team %d isn't being scheduled", r+1, id)
+				}
+				if playing[id] {
+					return fmt.Errorf("round %d: team %d plays twice", r+1, id)
+				}
+				playing[id] = true
+			}
+			if played[f] {
+				return fmt.Errorf("round %d: team %d is at home to team %d again", r+1, f.HomeTeamID, f.AwayTeamID)
+			}
+			played[f] = true
+
+			if s := stadium[f.HomeTeamID]; s != 0 {
+				if other, ok := homeAt[s]; ok {
+					return fmt.Errorf("round %d: teams %d and %d are both at home at stadium %d", r+1, other, f.HomeTeamID, s)
+				}
+				homeAt[s] = f.HomeTeamID
+			}
+
+			homeRun[f.HomeTeamID]++
+			homeRun[f.AwayTeamID] = 0
+			if homeRun[f.HomeTeamID] > maxConsecutiveHome {
+				return fmt.Errorf("round %d: team %d has %d home matches in a row", r+1, f.HomeTeamID, homeRun[f.HomeTeamID])
+			}
+		}
+	}
+
+	if want := len(teams) * (len(teams) - 1); len(played) != want {
+		return fmt.Errorf("%d matches scheduled, want %d", len(played), want)
+	}
+	return nil
+}
+
+// Matchdays returns the date of each of n rounds: first, then every interval days,
+// skipping any date in blackouts. It returns ErrNotEnoughMatchdays if the last round
+// would fall after last. All dates are taken as calendar days.
+func Matchdays(first time.Time, interval int, blackouts []time.Time, n int, last time.Time) ([]time.Time, error) {
+	skip := map[string]bool{}
+	for _, d := range blackouts {
+		skip[d.Format(time.DateOnly)] = true
+	}
+
+	days := make([]time.Time, 0, n)
+	for d := first; len(days) < n; d = d.AddDate(0, 0, interval) {
+		if d.After(last) {
+			return nil, ErrNotEnoughMatchdays
+		}
+		if !skip[d.Format(time.DateOnly)] {
+			days = append(days, d)
+		}
+	}
+	return days, nil
+}
@@ -0,0 +1,161 @@
+package fixtures_test
+
+import (
+	"adv.erakaisar.net/internal/fixtures"
+	"errors"
+	"testing"
+	"time"
+)
+
+func league(n int) []fixtures.Team {
+	teams := make([]fixtures.Team, n)
+	for i := range teams {
+		teams[i] = fixtures.Team{ID: int64(i + 1), StadiumID: int64(100 + i)}
+	}
+	return teams
+}
+
+func TestGenerate(t *testing.T) {
+	shared := league(20)
+	// Teams 1 and 2, and 3 and 4, share a ground.
+	shared[1].StadiumID = shared[0].StadiumID
+	shared[3].StadiumID = shared[2].StadiumID
+
+	odd := league(5)
+	odd[1].StadiumID = odd[0].StadiumID
+
+	tests := []struct {
+		name  string
+		teams []fixtures.Team
+	}{
+		{"two teams", league(2)},
+		{"twenty teams", league(20)},
+		{"shared stadiums", shared},
+		{"odd number of teams", odd},
+		{"no stadiums", []fixtures.Team{{ID: 7}, {ID: 8}, {ID: 9}, {ID: 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 5; seed++ {
+				rounds, err := fixtures.Generate(tt.teams, seed)
+				if err != nil {
+					t.Fatalf("seed %d: unexpected error: %v", seed, err)
+				}
+				n := len(tt.teams)
+				if n%2 == 1 {
+					n++
+				}
+				if len(rounds) != 2*(n-1) {
+					t.Errorf("seed %d: %d rounds, want %d", seed, len(rounds), 2*(n-1))
+				}
+				if err := fixtures.Check(rounds, tt.teams); err != nil {
+					t.Errorf("seed %d: %v", seed, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate_NoImmediateRematch(t *testing.T) {
+	rounds, err := fixtures.Generate(league(20), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for r := 1; r < len(rounds); r++ {
+		previous := map[[2]int64]bool{}
+		for _, f := range rounds[r-1] {
+			previous[[2]int64{f.AwayTeamID, f.HomeTeamID}] = true
+		}
+		for _, f := range rounds[r] {
+			if previous[[2]int64{f.HomeTeamID, f.AwayTeamID}] {
+				t.Errorf("round %d: %d and %d meet in consecutive rounds", r+1, f.HomeTeamID, f.AwayTeamID)
+			}
+		}
+	}
+}
+
+func TestGenerate_Seed(t *testing.T) {
+	a, _ := fixtures.Generate(league(10), 42)
+	b, _ := fixtures.Generate(league(10), 42)
+	for r := range a {
+		for i := range a[r] {
+			if a[r][i] != b[r][i] {
+				t.Fatalf("round %d differs between runs with the same seed", r+1)
+			}
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	crowded := league(6)
+	crowded[1].StadiumID = crowded[0].StadiumID
+	crowded[2].StadiumID = crowded[0].StadiumID
+
+	if _, err := fixtures.Generate(league(1), 0); !errors.Is(err, fixtures.ErrTooFewTeams) {
+		t.Errorf("one team: got %v, want ErrTooFewTeams", err)
+	}
+	if _, err := fixtures.Generate([]fixtures.Team{{ID: 1}, {ID: 1}}, 0); !errors.Is(err, fixtures.ErrDuplicateTeam) {
+		t.Errorf("duplicate team: got %v, want ErrDuplicateTeam", err)
+	}
+
+	_, err := fixtures.Generate(crowded, 0)
+	var conflict *fixtures.StadiumConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("three teams at one stadium: got %v, want a StadiumConflictError", err)
+	}
+	if conflict.StadiumID != 100 || len(conflict.TeamIDs) != 3 {
+		t.Errorf("unexpected conflict %+v", conflict)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	teams := []fixtures.Team{{ID: 1, StadiumID: 9}, {ID: 2, StadiumID: 9}, {ID: 3}, {ID: 4}}
+
+	tests := []struct {
+		name   string
+		rounds [][]fixtures.Fixture
+	}{
+		{"missing matches", [][]fixtures.Fixture{{{1, 3}, {2, 4}}}},
+		{"plays twice", [][]fixtures.Fixture{{{1, 3}, {3, 4}}}},
+		{"unknown team", [][]fixtures.Fixture{{{1, 5}}}},
+		{"shared stadium", [][]fixtures.Fixture{{{1, 3}, {2, 4}}}},
+		{"three home matches", [][]fixtures.Fixture{{{3, 1}}, {{3, 2}}, {{3, 4}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := fixtures.Check(tt.rounds, teams); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestMatchdays(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	blackouts := []time.Time{date("2025-08-23")}
+
+	days, err := fixtures.Matchdays(date("2025-08-16"), 7, blackouts, 3, date("2026-05-24"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-08-16", "2025-08-30", "2025-09-06"}
+	for i, d := range days {
+		if got := d.Format(time.DateOnly); got != want[i] {
+			t.Errorf("matchday %d = %s, want %s", i+1, got, want[i])
+		}
+	}
+
+	_, err = fixtures.Matchdays(date("2025-08-16"), 7, blackouts, 3, date("2025-08-31"))
+	if !errors.Is(err, fixtures.ErrNotEnoughMatchdays) {
+		t.Errorf("got %v, want ErrNotEnoughMatchdays", err)
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS team_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    type text NOT NULL,
+    team_id bigint NOT NULL,
+    team jsonb NOT NULL
+);
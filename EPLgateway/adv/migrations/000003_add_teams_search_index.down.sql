@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS teams_search_idx;
+ALTER TABLE teams DROP COLUMN IF EXISTS search;
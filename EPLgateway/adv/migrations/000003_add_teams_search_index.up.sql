@@ -0,0 +1,8 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', stadium), 'B') ||
+    setweight(to_tsvector('english', location), 'B') ||
+    setweight(to_tsvector('english', history), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS teams_search_idx ON teams USING GIN (search);
@@ -0,0 +1,3 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS teams_deleted_at_idx ON teams (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS team_revisions;
+ALTER TABLE teams DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,28 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
+UPDATE teams SET updated_at = created_at;
+
+CREATE TABLE IF NOT EXISTS team_revisions (
+    id bigserial PRIMARY KEY,
+    team_id bigint NOT NULL,
+    revision integer NOT NULL,
+    action text NOT NULL,
+    actor_id bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    before jsonb,
+    after jsonb NOT NULL,
+    UNIQUE (team_id, revision)
+);
+
+-- Existing teams start their history with a created revision at their current version,
+-- holding a snapshot of the team as it stands.
+INSERT INTO team_revisions (team_id, revision, action, created_at, after)
+SELECT id, version, 'created', updated_at, jsonb_strip_nulls(jsonb_build_object(
+    'id', id,
+    'name', name,
+    'location', location,
+    'stadium', stadium,
+    'history', history,
+    'version', version,
+    'deleted_at', deleted_at
+))
+FROM teams;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS players (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    name text NOT NULL,
+    shirt_number integer NOT NULL,
+    position text NOT NULL,
+    nationality text NOT NULL,
+    date_of_birth date NOT NULL,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT players_team_id_shirt_number_key UNIQUE (team_id, shirt_number)
+);
+
+CREATE INDEX IF NOT EXISTS players_team_id_idx ON players (team_id);
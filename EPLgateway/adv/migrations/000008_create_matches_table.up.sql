@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS matches (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    home_team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    away_team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    kickoff timestamp(0) with time zone NOT NULL,
+    venue text NOT NULL,
+    status text NOT NULL DEFAULT 'scheduled',
+    home_score integer,
+    away_score integer,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT matches_different_teams_check CHECK (home_team_id <> away_team_id),
+    CONSTRAINT matches_score_check CHECK (home_score >= 0 AND away_score >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS matches_home_team_id_idx ON matches (home_team_id);
+CREATE INDEX IF NOT EXISTS matches_away_team_id_idx ON matches (away_team_id);
+CREATE INDEX IF NOT EXISTS matches_kickoff_idx ON matches (kickoff);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS seasons (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL UNIQUE,
+    starts_on date NOT NULL,
+    ends_on date NOT NULL,
+    status text NOT NULL DEFAULT 'open',
+    closed_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT seasons_dates_check CHECK (starts_on < ends_on)
+);
+
+-- Only one season can be open at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS seasons_open_idx ON seasons (status) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS season_teams (
+    season_id bigint NOT NULL REFERENCES seasons ON DELETE CASCADE,
+    team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    promoted boolean NOT NULL DEFAULT false,
+    relegated boolean NOT NULL DEFAULT false,
+    PRIMARY KEY (season_id, team_id)
+);
+
+CREATE INDEX IF NOT EXISTS season_teams_team_id_idx ON season_teams (team_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS match_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    match_id bigint NOT NULL REFERENCES matches ON DELETE CASCADE,
+    team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    type text NOT NULL,
+    minute integer NOT NULL,
+    added_time integer NOT NULL DEFAULT 0,
+    player_id bigint REFERENCES players ON DELETE SET NULL,
+    related_player_id bigint REFERENCES players ON DELETE SET NULL,
+    CONSTRAINT match_events_minute_check CHECK (minute BETWEEN 1 AND 120),
+    CONSTRAINT match_events_added_time_check CHECK (added_time >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS match_events_match_id_idx ON match_events (match_id, minute, added_time);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS match_updates (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    type text NOT NULL,
+    match_id bigint NOT NULL REFERENCES matches ON DELETE CASCADE,
+    match jsonb NOT NULL,
+    event jsonb
+);
+
+CREATE INDEX IF NOT EXISTS match_updates_match_id_idx ON match_updates (match_id, id);
@@ -0,0 +1,58 @@
+-- One row per player, team and season, built from the match event timeline. Lineups
+-- aren't recorded, so a player only makes an appearance in a match when they take part
+-- in one of its events. They are taken to have played from kick-off, or from the
+-- minute they came on, until the minute they were substituted or sent off, or the end
+-- of normal time. Matches outside every season have a season_id of 0.
+CREATE MATERIALIZED VIEW IF NOT EXISTS player_stats AS
+WITH involvement AS (
+    SELECT id, match_id, team_id, player_id, type, minute, added_time, 'player' AS role
+    FROM match_events
+    WHERE player_id IS NOT NULL
+    UNION ALL
+    SELECT id, match_id, team_id, related_player_id, type, minute, added_time, 'related'
+    FROM match_events
+    WHERE related_player_id IS NOT NULL
+),
+second_yellows AS (
+    SELECT match_id, player_id, minute
+    FROM (
+        SELECT match_id, player_id, minute,
+               row_number() OVER (PARTITION BY match_id, player_id ORDER BY minute, added_time, id) AS n
+        FROM involvement
+        WHERE type = 'yellow_card' AND role = 'player'
+    ) y
+    WHERE n = 2
+),
+appearances AS (
+    SELECT i.match_id, i.player_id, min(i.team_id) AS team_id,
+           count(*) FILTER (WHERE i.role = 'player' AND i.type IN ('goal', 'penalty')) AS goals,
+           count(*) FILTER (WHERE i.role = 'related' AND i.type = 'goal') AS assists,
+           count(*) FILTER (WHERE i.role = 'player' AND i.type = 'yellow_card') AS yellow_cards,
+           count(*) FILTER (WHERE i.role = 'player' AND i.type = 'red_card') AS red_cards,
+           COALESCE(min(i.minute) FILTER (WHERE i.role = 'related' AND i.type = 'substitution'), 0) AS came_on,
+           LEAST(
+               COALESCE(min(i.minute) FILTER (WHERE i.role = 'player' AND i.type IN ('substitution', 'red_card')), 90),
+               COALESCE(min(y.minute), 90),
+               90
+           ) AS went_off
+    FROM involvement i
+    LEFT JOIN second_yellows y ON y.match_id = i.match_id AND y.player_id = i.player_id
+    GROUP BY i.match_id, i.player_id
+)
+SELECT COALESCE(s.id, 0) AS season_id,
+       a.player_id,
+       a.team_id,
+       count(*) AS appearances,
+       sum(a.goals) AS goals,
+       sum(a.assists) AS assists,
+       sum(GREATEST(a.went_off - a.came_on, 0)) AS minutes_played,
+       sum(a.yellow_cards) AS yellow_cards,
+       sum(a.red_cards) AS red_cards
+FROM appearances a
+JOIN matches m ON m.id = a.match_id
+LEFT JOIN seasons s ON (m.kickoff AT TIME ZONE 'Europe/London')::date BETWEEN s.starts_on AND s.ends_on
+GROUP BY COALESCE(s.id, 0), a.player_id, a.team_id;
+
+-- REFRESH MATERIALIZED VIEW CONCURRENTLY needs a unique index.
+CREATE UNIQUE INDEX IF NOT EXISTS player_stats_key ON player_stats (season_id, player_id, team_id);
+CREATE INDEX IF NOT EXISTS player_stats_team_id_idx ON player_stats (team_id);
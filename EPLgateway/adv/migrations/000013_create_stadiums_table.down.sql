@@ -0,0 +1,23 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS stadium text;
+
+UPDATE teams t
+SET stadium = s.name
+FROM stadiums s
+WHERE s.id = t.stadium_id;
+
+ALTER TABLE teams ALTER COLUMN stadium SET NOT NULL;
+
+DROP INDEX IF EXISTS teams_search_idx;
+ALTER TABLE teams DROP COLUMN IF EXISTS search;
+ALTER TABLE teams DROP COLUMN IF EXISTS stadium_id;
+
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', stadium), 'B') ||
+    setweight(to_tsvector('english', location), 'B') ||
+    setweight(to_tsvector('english', history), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS teams_search_idx ON teams USING GIN (search);
+
+DROP TABLE IF EXISTS stadiums;
@@ -0,0 +1,84 @@
+CREATE TABLE IF NOT EXISTS stadiums (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    city text NOT NULL,
+    capacity integer,
+    opened_year integer,
+    latitude double precision,
+    longitude double precision,
+    surface text NOT NULL DEFAULT 'grass',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT stadiums_capacity_check CHECK (capacity > 0),
+    CONSTRAINT stadiums_coordinates_check CHECK (
+        (latitude IS NULL) = (longitude IS NULL)
+        AND latitude BETWEEN -90 AND 90
+        AND longitude BETWEEN -180 AND 180
+    )
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS stadiums_name_key ON stadiums (LOWER(name));
+CREATE INDEX IF NOT EXISTS stadiums_latitude_idx ON stadiums (latitude) WHERE latitude IS NOT NULL;
+
+ALTER TABLE stadiums ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS stadiums_search_idx ON stadiums USING GIN (search);
+
+-- Spellings of the same ground are merged: case, surrounding and repeated spaces, a
+-- leading "The" and a trailing "Stadium" are ignored, so "Old Trafford" and "old
+-- trafford stadium" become one stadium.
+CREATE FUNCTION pg_temp.stadium_key(name text) RETURNS text AS $$
+    SELECT regexp_replace(regexp_replace(LOWER(btrim(name)), '\s+', ' ', 'g'), '^the |\s*stadium$', '', 'g')
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Every spelling in use, including those only found in the revision history, so that
+-- old revisions can still be reverted to. Capacity, opening year and coordinates
+-- aren't known for these, and have to be filled in through the API.
+WITH spellings AS (
+    SELECT stadium AS name, location AS city FROM teams
+    UNION ALL
+    SELECT after->>'stadium', after->>'location' FROM team_revisions
+    UNION ALL
+    SELECT before->>'stadium', before->>'location' FROM team_revisions WHERE before IS NOT NULL
+)
+INSERT INTO stadiums (name, city)
+SELECT DISTINCT ON (pg_temp.stadium_key(name))
+       regexp_replace(btrim(name), '\s+', ' ', 'g'), city
+FROM spellings
+ORDER BY pg_temp.stadium_key(name), length(btrim(name)), name;
+
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS stadium_id bigint REFERENCES stadiums ON DELETE RESTRICT;
+
+UPDATE teams t
+SET stadium_id = s.id
+FROM stadiums s
+WHERE pg_temp.stadium_key(s.name) = pg_temp.stadium_key(t.stadium);
+
+UPDATE team_revisions r
+SET after = jsonb_set(after, '{stadium_id}', to_jsonb(s.id))
+FROM stadiums s
+WHERE pg_temp.stadium_key(s.name) = pg_temp.stadium_key(r.after->>'stadium');
+
+UPDATE team_revisions r
+SET before = jsonb_set(before, '{stadium_id}', to_jsonb(s.id))
+FROM stadiums s
+WHERE pg_temp.stadium_key(s.name) = pg_temp.stadium_key(r.before->>'stadium');
+
+ALTER TABLE teams ALTER COLUMN stadium_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS teams_stadium_id_idx ON teams (stadium_id);
+
+-- The generated search column can only use the teams table, so the stadium name now
+-- comes from the stadiums' own search column.
+DROP INDEX IF EXISTS teams_search_idx;
+ALTER TABLE teams DROP COLUMN IF EXISTS search;
+ALTER TABLE teams DROP COLUMN IF EXISTS stadium;
+
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', name), 'A') ||
+    setweight(to_tsvector('english', location), 'B') ||
+    setweight(to_tsvector('english', history), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS teams_search_idx ON teams USING GIN (search);
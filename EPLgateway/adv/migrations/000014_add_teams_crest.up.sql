@@ -0,0 +1,3 @@
+-- The blob key of the team's crest as uploaded. The resized variants are stored
+-- under keys derived from it.
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS crest text;
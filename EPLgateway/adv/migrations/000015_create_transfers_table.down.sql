@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS player_memberships;
+DROP TABLE IF EXISTS transfer_windows;
@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS transfer_windows (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    opens_on date NOT NULL,
+    closes_on date NOT NULL,
+    CONSTRAINT transfer_windows_name_key UNIQUE (name),
+    CONSTRAINT transfer_windows_dates_check CHECK (opens_on <= closes_on)
+);
+
+-- A player's spells at each club. joined_on is inclusive and left_on exclusive, so a
+-- player transferred on a given day belongs to the new club from that day on. The
+-- current spell is the one without a left_on.
+CREATE TABLE IF NOT EXISTS player_memberships (
+    id bigserial PRIMARY KEY,
+    player_id bigint NOT NULL REFERENCES players ON DELETE CASCADE,
+    team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    joined_on date NOT NULL,
+    left_on date,
+    CONSTRAINT player_memberships_dates_check CHECK (left_on IS NULL OR joined_on < left_on)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS player_memberships_current_idx ON player_memberships (player_id) WHERE left_on IS NULL;
+CREATE INDEX IF NOT EXISTS player_memberships_team_id_idx ON player_memberships (team_id, joined_on);
+
+-- Existing players have been with their club from the start, since many were
+-- registered after playing for it, and their events in earlier matches must still
+-- count. PlayerModel.Insert() starts new players' first spells on the same date.
+INSERT INTO player_memberships (player_id, team_id, joined_on)
+SELECT id, team_id, DATE '1900-01-01'
+FROM players;
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    player_id bigint NOT NULL REFERENCES players ON DELETE CASCADE,
+    from_team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    to_team_id bigint NOT NULL REFERENCES teams ON DELETE RESTRICT,
+    type text NOT NULL,
+    fee bigint,
+    currency text,
+    transferred_on date NOT NULL,
+    window_id bigint REFERENCES transfer_windows ON DELETE RESTRICT,
+    exceptional boolean NOT NULL DEFAULT false,
+    CONSTRAINT transfers_teams_check CHECK (from_team_id <> to_team_id),
+    CONSTRAINT transfers_fee_check CHECK (fee IS NULL OR fee >= 0),
+    CONSTRAINT transfers_currency_check CHECK ((fee IS NULL) = (currency IS NULL)),
+    -- Transfers outside a window have to be flagged as exceptional.
+    CONSTRAINT transfers_window_check CHECK (window_id IS NOT NULL OR exceptional)
+);
+
+CREATE INDEX IF NOT EXISTS transfers_player_id_idx ON transfers (player_id, transferred_on);
+CREATE INDEX IF NOT EXISTS transfers_from_team_id_idx ON transfers (from_team_id);
+CREATE INDEX IF NOT EXISTS transfers_to_team_id_idx ON transfers (to_team_id);
+CREATE INDEX IF NOT EXISTS transfers_window_id_idx ON transfers (window_id);
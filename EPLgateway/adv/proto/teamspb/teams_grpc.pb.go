@@ -0,0 +1,328 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v4.25.3
+// source: proto/teams.proto
+
+package teamspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Teams_Create_FullMethodName     = "/teams.Teams/Create"
+	Teams_Show_FullMethodName       = "/teams.Teams/Show"
+	Teams_Update_FullMethodName     = "/teams.Teams/Update"
+	Teams_Delete_FullMethodName     = "/teams.Teams/Delete"
+	Teams_List_FullMethodName       = "/teams.Teams/List"
+	Teams_WatchTeams_FullMethodName = "/teams.Teams/WatchTeams"
+)
+
+// TeamsClient is the client API for Teams service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TeamsClient interface {
+	Create(ctx context.Context, in *TeamRequest, opts ...grpc.CallOption) (*TeamResponse, error)
+	Show(ctx context.Context, in *TeamIDRequest, opts ...grpc.CallOption) (*TeamResponse, error)
+	Update(ctx context.Context, in *TeamUpdateRequest, opts ...grpc.CallOption) (*TeamResponse, error)
+	Delete(ctx context.Context, in *TeamIDRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	List(ctx context.Context, in *ListTeamsRequest, opts ...grpc.CallOption) (*ListTeamsResponse, error)
+	WatchTeams(ctx context.Context, in *WatchTeamsRequest, opts ...grpc.CallOption) (Teams_WatchTeamsClient, error)
+}
+
+type teamsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTeamsClient(cc grpc.ClientConnInterface) TeamsClient {
+	return &teamsClient{cc}
+}
+
+func (c *teamsClient) Create(ctx context.Context, in *TeamRequest, opts ...grpc.CallOption) (*TeamResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TeamResponse)
+	err := c.cc.Invoke(ctx, Teams_Create_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamsClient) Show(ctx context.Context, in *TeamIDRequest, opts ...grpc.CallOption) (*TeamResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TeamResponse)
+	err := c.cc.Invoke(ctx, Teams_Show_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamsClient) Update(ctx context.Context, in *TeamUpdateRequest, opts ...grpc.CallOption) (*TeamResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TeamResponse)
+	err := c.cc.Invoke(ctx, Teams_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamsClient) Delete(ctx context.Context, in *TeamIDRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Teams_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamsClient) List(ctx context.Context, in *ListTeamsRequest, opts ...grpc.CallOption) (*ListTeamsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTeamsResponse)
+	err := c.cc.Invoke(ctx, Teams_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *teamsClient) WatchTeams(ctx context.Context, in *WatchTeamsRequest, opts ...grpc.CallOption) (Teams_WatchTeamsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Teams_ServiceDesc.Streams[0], Teams_WatchTeams_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &teamsWatchTeamsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Teams_WatchTeamsClient interface {
+	Recv() (*TeamEvent, error)
+	grpc.ClientStream
+}
+
+type teamsWatchTeamsClient struct {
+	grpc.ClientStream
+}
+
+func (x *teamsWatchTeamsClient) Recv() (*TeamEvent, error) {
+	m := new(TeamEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TeamsServer is the server API for Teams service.
+// All implementations must embed UnimplementedTeamsServer
+// for forward compatibility
+type TeamsServer interface {
+	Create(context.Context, *TeamRequest) (*TeamResponse, error)
+	Show(context.Context, *TeamIDRequest) (*TeamResponse, error)
+	Update(context.Context, *TeamUpdateRequest) (*TeamResponse, error)
+	Delete(context.Context, *TeamIDRequest) (*DeleteResponse, error)
+	List(context.Context, *ListTeamsRequest) (*ListTeamsResponse, error)
+	WatchTeams(*WatchTeamsRequest, Teams_WatchTeamsServer) error
+	mustEmbedUnimplementedTeamsServer()
+}
+
+// UnimplementedTeamsServer must be embedded to have forward compatible implementations.
+type UnimplementedTeamsServer struct {
+}
+
+func (UnimplementedTeamsServer) Create(context.Context, *TeamRequest) (*TeamResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedTeamsServer) Show(context.Context, *TeamIDRequest) (*TeamResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Show not implemented")
+}
+func (UnimplementedTeamsServer) Update(context.Context, *TeamUpdateRequest) (*TeamResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedTeamsServer) Delete(context.Context, *TeamIDRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedTeamsServer) List(context.Context, *ListTeamsRequest) (*ListTeamsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedTeamsServer) WatchTeams(*WatchTeamsRequest, Teams_WatchTeamsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTeams not implemented")
+}
+func (UnimplementedTeamsServer) mustEmbedUnimplementedTeamsServer() {}
+
+// UnsafeTeamsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TeamsServer will
+// result in compilation errors.
+type UnsafeTeamsServer interface {
+	mustEmbedUnimplementedTeamsServer()
+}
+
+func RegisterTeamsServer(s grpc.ServiceRegistrar, srv TeamsServer) {
+	s.RegisterService(&Teams_ServiceDesc, srv)
+}
+
+func _Teams_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamsServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Teams_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamsServer).Create(ctx, req.(*TeamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Teams_Show_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeamIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamsServer).Show(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Teams_Show_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamsServer).Show(ctx, req.(*TeamIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Teams_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeamUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamsServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Teams_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamsServer).Update(ctx, req.(*TeamUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Teams_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TeamIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Teams_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamsServer).Delete(ctx, req.(*TeamIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Teams_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTeamsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TeamsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Teams_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TeamsServer).List(ctx, req.(*ListTeamsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Teams_WatchTeams_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTeamsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TeamsServer).WatchTeams(m, &teamsWatchTeamsServer{ServerStream: stream})
+}
+
+type Teams_WatchTeamsServer interface {
+	Send(*TeamEvent) error
+	grpc.ServerStream
+}
+
+type teamsWatchTeamsServer struct {
+	grpc.ServerStream
+}
+
+func (x *teamsWatchTeamsServer) Send(m *TeamEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Teams_ServiceDesc is the grpc.ServiceDesc for Teams service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Teams_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "teams.Teams",
+	HandlerType: (*TeamsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _Teams_Create_Handler,
+		},
+		{
+			MethodName: "Show",
+			Handler:    _Teams_Show_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _Teams_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Teams_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Teams_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTeams",
+			Handler:       _Teams_WatchTeams_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/teams.proto",
+}
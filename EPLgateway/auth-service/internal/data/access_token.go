@@ -0,0 +1,97 @@
+package data
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const accessTokenIssuer = "auth-service"
+
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// AccessClaims are the claims carried by a signed access token. The embedded
+// StandardClaims keep the token readable by services that only know about the
+// registered claims: comment-service reads the user ID from Subject.
+type AccessClaims struct {
+	Scope       string      `json:"scope"`
+	Permissions Permissions `json:"permissions"`
+	jwt.StandardClaims
+}
+
+type AccessToken struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// NewAccessToken mints an HS256-signed JWT for the user, issued alongside the stored
+// token session. The token's ID claim is the hash of that token, so that deleting the
+// stored token also revokes the access token at introspection.
+func NewAccessToken(userID int64, session *Token, permissions Permissions, secret []byte) (*AccessToken, error) {
+	now := time.Now()
+	expiry := session.Expiry
+
+	if permissions == nil {
+		permissions = Permissions{}
+	}
+
+	claims := AccessClaims{
+		Scope:       session.Scope,
+		Permissions: permissions,
+		StandardClaims: jwt.StandardClaims{
+			Id:        hex.EncodeToString(session.Hash),
+			Subject:   strconv.FormatInt(userID, 10),
+			Issuer:    accessTokenIssuer,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: expiry.Unix(),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessToken{Token: signed, Expiry: time.Unix(expiry.Unix(), 0)}, nil
+}
+
+// ParseAccessToken verifies the signature and expiry of a token created by
+// NewAccessToken and returns its claims.
+func ParseAccessToken(tokenString string, secret []byte) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidAccessToken
+		}
+		return secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidAccessToken
+	}
+
+	return claims, nil
+}
+
+// UserID returns the numeric user ID stored in the Subject claim.
+func (c *AccessClaims) UserID() (int64, error) {
+	id, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrInvalidAccessToken
+	}
+	return id, nil
+}
+
+// SessionHash returns the hash of the stored token the access token was issued with,
+// as recorded in its ID claim.
+func (c *AccessClaims) SessionHash() ([]byte, error) {
+	hash, err := hex.DecodeString(c.Id)
+	if err != nil || len(hash) == 0 {
+		return nil, ErrInvalidAccessToken
+	}
+	return hash, nil
+}
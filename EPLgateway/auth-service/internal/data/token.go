@@ -5,7 +5,9 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
 )
 
@@ -40,6 +42,49 @@ func (m TokenModel) Insert(token *Token) error {
 	return err
 }
 
+// Get returns the stored token matching the plaintext and scope, provided it has not
+// yet expired.
+func (m TokenModel) Get(tokenScope, tokenPlaintext string) (*Token, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	token, err := m.GetByHash(tokenScope, tokenHash[:])
+	if err != nil {
+		return nil, err
+	}
+	token.Plaintext = tokenPlaintext
+
+	return token, nil
+}
+
+// GetByHash fetches an unexpired token by the hash of its plaintext, for callers that
+// only know the hash, such as the ID claim of an access token.
+func (m TokenModel) GetByHash(tokenScope string, tokenHash []byte) (*Token, error) {
+	query := `
+		SELECT hash, user_id, expiry, scope
+		FROM tokens
+		WHERE hash = $1 AND scope = $2 AND expiry > $3
+		`
+
+	args := []interface{}{tokenHash, tokenScope, time.Now()}
+
+	var token Token
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&token.Hash, &token.UserID, &token.Expiry, &token.Scope)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &token, nil
+}
+
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	query := `
 		DELETE FROM tokens
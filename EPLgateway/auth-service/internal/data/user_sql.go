@@ -185,7 +185,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*UserInfo, er
 			user_info.password_hash, user_info.activated, user_info.version
 		FROM       user_info
         INNER JOIN tokens
-			ON users.id = tokens.user_id
+			ON user_info.id = tokens.user_id
         WHERE tokens.hash = $1  
 			AND tokens.scope = $2
 			AND tokens.expiry > $3
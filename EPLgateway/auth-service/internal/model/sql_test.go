@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
 	"testing"
 	"time"
 )
@@ -299,3 +300,47 @@ func TestUserModel_GetForToken(t *testing.T) {
 		t.Errorf("expected email %s, got %s", expectedUser.Email, user.Email)
 	}
 }
+func TestNewAccessToken(t *testing.T) {
+	secret := []byte("test-secret")
+	permissions := data.Permissions{"matches:read"}
+
+	session := &data.Token{Hash: []byte{0xde, 0xad}, Expiry: time.Now().Add(time.Hour), Scope: data.ScopeAuthentication}
+
+	accessToken, err := data.NewAccessToken(42, session, permissions, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// comment-service only knows about the registered claims, so the token must
+	// verify as a plain jwt.StandardClaims token too.
+	standard := &jwt.StandardClaims{}
+	_, err = jwt.ParseWithClaims(accessToken.Token, standard, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error parsing standard claims: %s", err)
+	}
+	if standard.Subject != "42" {
+		t.Errorf("expected subject 42, got %q", standard.Subject)
+	}
+
+	claims, err := data.ParseAccessToken(accessToken.Token, secret)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if claims.Scope != data.ScopeAuthentication {
+		t.Errorf("expected scope %q, got %q", data.ScopeAuthentication, claims.Scope)
+	}
+	if len(claims.Permissions) != 1 || claims.Permissions[0] != "matches:read" {
+		t.Errorf("expected permissions [matches:read], got %v", claims.Permissions)
+	}
+	hash, err := claims.SessionHash()
+	if err != nil || string(hash) != string(session.Hash) {
+		t.Errorf("expected session hash %x, got %x (%v)", session.Hash, hash, err)
+	}
+
+	_, err = data.ParseAccessToken(accessToken.Token, []byte("wrong-secret"))
+	if err == nil {
+		t.Error("expected an error for a token signed with another secret, but got nil")
+	}
+}
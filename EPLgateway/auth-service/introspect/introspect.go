@@ -0,0 +1,160 @@
+// Package introspect is a client for auth-service's token introspection endpoint
+// (POST /v1/tokens/introspect). Other services use it to find out who a bearer token
+// belongs to and what that user is allowed to do, without sharing the token table or
+// the JWT secret.
+package introspect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when auth-service rejects the client's service
+	// credentials.
+	ErrUnauthorized = errors.New("introspect: service credentials rejected")
+)
+
+// Result is the introspection response, following RFC 7662. Only Active is
+// meaningful when the token is not active.
+type Result struct {
+	Active      bool     `json:"active"`
+	Subject     string   `json:"sub,omitempty"`
+	UserID      int64    `json:"user_id,omitempty"`
+	Expiry      int64    `json:"exp,omitempty"`
+	Scope       string   `json:"scope,omitempty"`
+	TokenType   string   `json:"token_type,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// ExpiresAt returns the token expiry as a time.Time.
+func (r *Result) ExpiresAt() time.Time {
+	return time.Unix(r.Expiry, 0)
+}
+
+// HasPermission reports whether the token's user holds the given permission code.
+func (r *Result) HasPermission(code string) bool {
+	for _, p := range r.Permissions {
+		if p == code {
+			return true
+		}
+	}
+	return false
+}
+
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+// Client calls the introspection endpoint and keeps results in a short-lived
+// in-process cache, so a burst of requests carrying the same token only costs one
+// round trip to auth-service.
+type Client struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	ttl          time.Duration
+	httpClient   *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cacheEntry
+}
+
+// New returns a Client for the introspection endpoint at the given URL. Results are
+// cached for at most ttl, and never beyond the token's own expiry.
+func New(endpoint, clientID, clientSecret string, ttl time.Duration) *Client {
+	return &Client{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		ttl:          ttl,
+		httpClient:   &http.Client{Timeout: 5 * time.Second},
+		cache:        make(map[[sha256.Size]byte]cacheEntry),
+	}
+}
+
+// Introspect returns the introspection result for the token. An inactive token is
+// not an error: check Result.Active.
+func (c *Client) Introspect(ctx context.Context, token string) (*Result, error) {
+	// Key the cache on a hash of the token so plaintext tokens don't linger in
+	// memory longer than the request that carried them.
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expires) {
+		return entry.result, nil
+	}
+
+	result, err := c.fetch(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	expires := now.Add(c.ttl)
+	if result.Active && result.ExpiresAt().Before(expires) {
+		expires = result.ExpiresAt()
+	}
+
+	c.mu.Lock()
+	c.sweep(now)
+	c.cache[key] = cacheEntry{result: result, expires: expires}
+	c.mu.Unlock()
+
+	return result, nil
+}
+
+// sweep drops expired entries. It must be called with c.mu held.
+func (c *Client) sweep(now time.Time) {
+	for key, entry := range c.cache {
+		if !now.Before(entry.expires) {
+			delete(c.cache, key)
+		}
+	}
+}
+
+func (c *Client) fetch(ctx context.Context, token string) (*Result, error) {
+	form := url.Values{"token": {token}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	default:
+		return nil, fmt.Errorf("introspect: unexpected status %d", resp.StatusCode)
+	}
+
+	var result Result
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("introspect: decoding response: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,68 @@
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientIntrospectCachesResults(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "adv" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		result := Result{Active: false}
+		if r.PostFormValue("token") == "good" {
+			result = Result{
+				Active:      true,
+				Subject:     "7",
+				UserID:      7,
+				Expiry:      time.Now().Add(time.Hour).Unix(),
+				Scope:       "authentication",
+				Permissions: []string{"teams:write"},
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer srv.Close()
+
+	client := New(srv.URL, "adv", "s3cret", time.Minute)
+
+	for i := 0; i < 3; i++ {
+		result, err := client.Introspect(context.Background(), "good")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !result.Active || result.UserID != 7 || !result.HasPermission("teams:write") {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 call to the introspection endpoint, got %d", got)
+	}
+
+	result, err := client.Introspect(context.Background(), "bad")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Active {
+		t.Error("expected inactive result for unknown token")
+	}
+
+	_, err = New(srv.URL, "adv", "wrong", time.Minute).Introspect(context.Background(), "good")
+	if err != ErrUnauthorized {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
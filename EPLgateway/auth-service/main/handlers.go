@@ -196,7 +196,21 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 		return
 	}
 
-	err = writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The signed access token is what other services verify on their own, using the
+	// shared JWT secret. It expires, and is revoked, together with the opaque token.
+	accessToken, err := data.NewAccessToken(user.ID, token, permissions, []byte(app.config.jwt.secret))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = writeJSON(w, http.StatusCreated, envelope{"authentication_token": token, "access_token": accessToken}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -44,6 +44,7 @@ func writeJSON(w http.ResponseWriter, status int, data envelope,
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
+	w.Write(js)
 
 	return nil
 }
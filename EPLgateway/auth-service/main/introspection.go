@@ -0,0 +1,169 @@
+package main
+
+import (
+	"EPLgateway/auth-service/internal/data"
+	"EPLgateway/auth-service/introspect"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseIntrospectionClients parses the INTROSPECTION_CLIENTS setting, a comma
+// separated list of "client-id:secret" pairs, one for each service allowed to call
+// the introspection endpoint.
+func parseIntrospectionClients(s string) (map[string]string, error) {
+	clients := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, errors.New("INTROSPECTION_CLIENTS entries must have the form client-id:secret")
+		}
+
+		clients[id] = secret
+	}
+
+	return clients, nil
+}
+
+// requireServiceCredentials only lets through requests that carry the HTTP Basic
+// credentials of a configured introspection client.
+func (app *application) requireServiceCredentials(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if ok {
+			expected, known := app.config.introspection.clients[id]
+			if known && subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1 {
+				next(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		app.invalidCredentialsResponse(w, r)
+	}
+}
+
+// introspectTokenHandler implements RFC 7662 token introspection for both the opaque
+// authentication tokens stored in the tokens table and the signed access tokens.
+// Unknown, expired and malformed tokens are all reported as {"active": false}.
+func (app *application) introspectTokenHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tokenPlaintext := r.PostForm.Get("token")
+	if tokenPlaintext == "" {
+		app.badRequestResponse(w, r, errors.New("token must be provided"))
+		return
+	}
+
+	result, err := app.introspect(tokenPlaintext)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+
+	// RFC 7662 responses are not wrapped in an envelope, and must not say anything
+	// about a token beyond the fact that it is inactive.
+	env := envelope{"active": false}
+	if result.Active {
+		env = envelope{
+			"active":      true,
+			"sub":         result.Subject,
+			"user_id":     result.UserID,
+			"exp":         result.Expiry,
+			"scope":       result.Scope,
+			"token_type":  result.TokenType,
+			"permissions": result.Permissions,
+		}
+	}
+
+	err = writeJSON(w, http.StatusOK, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) introspect(tokenPlaintext string) (*introspect.Result, error) {
+	inactive := &introspect.Result{Active: false}
+	result := &introspect.Result{Active: true, TokenType: "Bearer"}
+
+	// Signed access tokens are JWTs and always contain two dots; the opaque tokens
+	// are base32 and never do.
+	if strings.Count(tokenPlaintext, ".") == 2 {
+		claims, err := data.ParseAccessToken(tokenPlaintext, []byte(app.config.jwt.secret))
+		if err != nil {
+			return inactive, nil
+		}
+
+		result.UserID, err = claims.UserID()
+		if err != nil {
+			return inactive, nil
+		}
+
+		// An access token is only as good as the stored token it was issued with, so
+		// that deleting the stored token revokes both.
+		hash, err := claims.SessionHash()
+		if err != nil {
+			return inactive, nil
+		}
+		_, err = app.models.Tokens.GetByHash(data.ScopeAuthentication, hash)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+
+		result.Expiry = claims.ExpiresAt
+		result.Scope = claims.Scope
+	} else {
+		token, err := app.models.Tokens.Get(data.ScopeAuthentication, tokenPlaintext)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+
+		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, tokenPlaintext)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+
+		result.UserID = user.ID
+		result.Expiry = token.Expiry.Unix()
+		result.Scope = token.Scope
+	}
+
+	result.Subject = strconv.FormatInt(result.UserID, 10)
+
+	// Permissions are always read fresh, so a revoked permission stops working
+	// before a signed token carrying it expires.
+	permissions, err := app.models.Permissions.GetAllForUser(result.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+	result.Permissions = permissions
+
+	return result, nil
+}
@@ -43,6 +43,12 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	jwt struct {
+		secret string
+	}
+	introspection struct {
+		clients map[string]string
+	}
 }
 type application struct {
 	config config
@@ -65,12 +71,31 @@ func main() {
 
 	dbURL := os.Getenv("DB_URL")
 
+	// JWT_SECRET must match the secret configured for comment-service, otherwise
+	// the access tokens issued here will be rejected there.
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("JWT_SECRET must be set")
+	}
+
+	introspectionClients, err := parseIntrospectionClients(os.Getenv("INTROSPECTION_CLIENTS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	db = initDB(dbURL)
+	defer db.Close()
+
 	app := &application{
 		config: config{
 			port: 8080,
 			env:  "development",
-		}}
+			jwt:  struct{ secret string }{secret: jwtSecret},
+		},
+		logger: jsonlog.NewLogger(os.Stdout, jsonlog.LevelInfo),
+		models: data.NewModels(db),
+	}
+	app.config.introspection.clients = introspectionClients
 	// Applying migrations
 
 	r := app.setupRoutes()
@@ -88,6 +113,7 @@ func (app *application) setupRoutes() http.Handler {
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/introspect", app.requireServiceCredentials(app.introspectTokenHandler))
 
 	return router
 }
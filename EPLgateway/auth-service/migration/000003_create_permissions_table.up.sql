@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS permissions
+(
+    id   BIGSERIAL PRIMARY KEY,
+    code TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS users_permissions
+(
+    user_id       BIGINT NOT NULL REFERENCES user_info ON DELETE CASCADE,
+    permission_id BIGINT NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (user_id, permission_id)
+);
+
+INSERT INTO permissions (code)
+VALUES ('matches:read'),
+       ('matches:write');
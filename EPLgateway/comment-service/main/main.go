@@ -1,6 +1,7 @@
 package main
 
 import (
+	"EPLgateway/auth-service/introspect"
 	"EPLgateway/auth-service/jsonlog"
 	"EPLgateway/comment-service/internal/model"
 	"database/sql"
@@ -12,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"time"
 )
 
 var (
@@ -36,10 +38,11 @@ type config struct {
 }
 
 type application struct {
-	logger *jsonlog.Logger
-	config config
-	models model.Models
-	wg     sync.WaitGroup
+	logger       *jsonlog.Logger
+	config       config
+	models       model.Models
+	introspector *introspect.Client
+	wg           sync.WaitGroup
 }
 
 func main() {
@@ -61,9 +64,14 @@ func main() {
 			env:  "development",
 			jwt:  struct{ secret string }{secret: jwtSecret},
 		},
+		logger: jsonlog.NewLogger(os.Stdout, jsonlog.LevelInfo),
 		models: model.NewModels(db),
 	}
 
+	if introspectURL := os.Getenv("AUTH_INTROSPECT_URL"); introspectURL != "" {
+		app.introspector = introspect.New(introspectURL, os.Getenv("AUTH_CLIENT_ID"), os.Getenv("AUTH_CLIENT_SECRET"), 30*time.Second)
+	}
+
 	r := app.setupRoutes()
 
 	log.Println("Server started on :8081")
@@ -76,13 +84,15 @@ func (app *application) setupRoutes() http.Handler {
 	router := httprouter.New()
 
 	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/comments", app.requireAuthentication(app.createCommentHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/comments", app.requireAuthentication(app.updateCommentHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/comments", app.requireAuthentication(app.deleteCommentHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/teams/:id/comments", app.listCommentsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/comments/:id", app.getCommentByIDHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/comments/:id", app.requireAuthentication(app.updateCommentHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/comments/:id", app.requireAuthentication(app.deleteCommentHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/ratings", app.requireAuthentication(app.createRatingHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/ratings", app.requireAuthentication(app.updateRatingHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/teams/:id/ratings", app.requireAuthentication(app.deleteRatingHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/teams/:id/ratings", app.listRatingsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/ratings/:id", app.getRatingByIDHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/ratings/:id", app.requireAuthentication(app.updateRatingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/ratings/:id", app.requireAuthentication(app.deleteRatingHandler))
 
 	return router
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,25 +14,51 @@ func (app *application) requireAuthentication(next http.HandlerFunc) http.Handle
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is missing", http.StatusUnauthorized)
+			app.authenticationRequiredResponse(w, r)
 			return
 		}
 
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
 
 		tokenString := headerParts[1]
+
+		// When introspection is configured every token is checked with auth-service:
+		// opaque tokens can't be verified locally, and signed ones may have been
+		// revoked before they expire.
+		if app.introspector != nil {
+			result, err := app.introspector.Introspect(r.Context(), tokenString)
+			if err != nil {
+				app.logError(r, err)
+				app.errorResponse(w, r, http.StatusServiceUnavailable, "unable to verify the authentication token, please try again later")
+				return
+			}
+			if !result.Active {
+				app.invalidAuthenticationTokenResponse(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "userID", result.Subject)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
 		claims := &jwt.StandardClaims{}
 
+		// Access tokens are issued by auth-service and signed with the shared HS256
+		// secret. Refuse any other algorithm so a token can't pick its own verifier.
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
 			return []byte(app.config.jwt.secret), nil
 		})
 
 		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			app.invalidAuthenticationTokenResponse(w, r)
 			return
 		}
 
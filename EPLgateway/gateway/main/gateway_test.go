@@ -0,0 +1,204 @@
+package main
+
+import (
+	"EPLgateway/auth-service/jsonlog"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestApplication(t *testing.T, tc tableConfig) *application {
+	app := &application{
+		logger: jsonlog.NewLogger(io.Discard, jsonlog.LevelOff),
+	}
+
+	table, err := newRouteTable(tc, app.proxyErrorHandler)
+	if err != nil {
+		t.Fatalf("Error building route table: %v", err)
+	}
+	app.table = table
+
+	return app
+}
+
+func newNamedServer(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", name)
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestProxyRoutesToUpstream(t *testing.T) {
+	adv := newNamedServer("adv")
+	defer adv.Close()
+	auth := newNamedServer("auth")
+	defer auth.Close()
+	comments := newNamedServer("comments")
+	defer comments.Close()
+
+	var cfg config
+	cfg.upstreams.adv = adv.URL
+	cfg.upstreams.auth = auth.URL
+	cfg.upstreams.comments = comments.URL
+
+	app := newTestApplication(t, defaultTableConfig(cfg))
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/v1/teams", "adv"},
+		{http.MethodGet, "/v1/teams/1", "adv"},
+		{http.MethodGet, "/v1/players/7", "adv"},
+		{http.MethodGet, "/v1/matches", "adv"},
+		{http.MethodPatch, "/v1/matches/12", "adv"},
+		{http.MethodGet, "/v1/standings", "adv"},
+		{http.MethodPost, "/v1/seasons/2/close", "adv"},
+		{http.MethodPost, "/v1/seasons/2/fixtures", "adv"},
+		{http.MethodGet, "/v1/stats/leaders", "adv"},
+		{http.MethodGet, "/v1/stadiums/nearby", "adv"},
+		{http.MethodGet, "/v1/crests/1-0a1b2c3d4e5f-64.png", "adv"},
+		{http.MethodGet, "/v1/transfers", "adv"},
+		{http.MethodGet, "/v1/transfers/windows", "adv"},
+		{http.MethodPost, "/v1/teams/1/comments", "comments"},
+		{http.MethodGet, "/v1/teams/1/ratings", "comments"},
+		{http.MethodPut, "/v1/comments/3", "comments"},
+		{http.MethodPost, "/v1/users", "auth"},
+		{http.MethodPut, "/v1/users/activated", "auth"},
+		{http.MethodPost, "/v1/tokens/authentication", "auth"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("X-Served-By"); got != tt.want {
+			t.Errorf("%s %s: expected upstream %q, got %q (status %d)", tt.method, tt.path, tt.want, got, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/unknown", nil)
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown route, got %d", rr.Code)
+	}
+}
+
+func TestProxyErrorEnvelope(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tc := tableConfig{
+		Upstreams: []upstreamConfig{
+			{Name: "slow", Targets: []string{slow.URL}, Timeout: duration(20 * time.Millisecond)},
+			{Name: "down", Targets: []string{down.URL}},
+			{Name: "removed", Targets: []string{slow.URL}},
+		},
+		Routes: []routeConfig{
+			{Pattern: "/slow", Upstream: "slow"},
+			{Pattern: "/down", Upstream: "down"},
+			{Pattern: "/removed", Upstream: "removed"},
+		},
+	}
+
+	app := newTestApplication(t, tc)
+	app.table.upstreams[2].targets[0].healthy.Store(false)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/slow", http.StatusGatewayTimeout},
+		{"/down", http.StatusBadGateway},
+		{"/removed", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.status, rr.Code)
+		}
+
+		var body map[string]string
+		err := json.NewDecoder(rr.Body).Decode(&body)
+		if err != nil {
+			t.Fatalf("%s: error decoding response body: %v", tt.path, err)
+		}
+		if body["error"] == "" {
+			t.Errorf("%s: expected an error message in the response envelope", tt.path)
+		}
+	}
+}
+
+func TestProxyEventStreamIgnoresTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("id: 1\ndata: {}\n\n"))
+	}))
+	defer slow.Close()
+
+	tc := tableConfig{
+		Upstreams: []upstreamConfig{
+			{Name: "slow", Targets: []string{slow.URL}, Timeout: duration(20 * time.Millisecond)},
+		},
+		Routes: []routeConfig{
+			{Pattern: "/events", Upstream: "slow"},
+		},
+	}
+
+	app := newTestApplication(t, tc)
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for event stream, got %d", rr.Code)
+	}
+}
+
+func TestProxyWebSocketIgnoresTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+
+	tc := tableConfig{
+		Upstreams: []upstreamConfig{
+			{Name: "slow", Targets: []string{slow.URL}, Timeout: duration(20 * time.Millisecond)},
+		},
+		Routes: []routeConfig{
+			{Pattern: "/live", Upstream: "slow"},
+		},
+	}
+
+	app := newTestApplication(t, tc)
+
+	req := httptest.NewRequest(http.MethodGet, "/live", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for WebSocket, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type envelope map[string]interface{}
+
+type contextKey string
+
+const upstreamContextKey = contextKey("upstream")
+
+func writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+func (app *application) logError(r *http.Request, err error) {
+	app.logger.PrintError(err, map[string]string{
+		"request_method": r.Method,
+		"request_url":    r.URL.String(),
+	})
+}
+
+// errorResponse sends the same {"error": ...} envelope that adv, auth-service and
+// comment-service use, so clients see one error format whether the failure happened
+// in the gateway or behind it.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+	env := envelope{"error": message}
+
+	err := writeJSON(w, status, env, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := "the server encountered a problem and could not process your request"
+	app.errorResponse(w, r, http.StatusInternalServerError, message)
+}
+
+func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested resource could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, message)
+}
+
+// proxyErrorHandler is installed on every reverse proxy. It turns transport failures
+// into JSON error responses that name the upstream which failed.
+func (app *application) proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	name, _ := r.Context().Value(upstreamContextKey).(string)
+
+	switch {
+	case errors.Is(err, errNoHealthyTarget):
+		message := fmt.Sprintf("the %s service is currently unavailable, please try again later", name)
+		app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+	case errors.Is(err, context.DeadlineExceeded):
+		app.logError(r, err)
+		message := fmt.Sprintf("the %s service did not respond in time", name)
+		app.errorResponse(w, r, http.StatusGatewayTimeout, message)
+	case errors.Is(err, context.Canceled):
+		// The client went away, so there is nobody to send a response to.
+	default:
+		app.logError(r, err)
+		message := fmt.Sprintf("the %s service could not be reached", name)
+		app.errorResponse(w, r, http.StatusBadGateway, message)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"EPLgateway/auth-service/jsonlog"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+type config struct {
+	port           int
+	env            string
+	routesFile     string
+	healthInterval time.Duration
+	upstreams      struct {
+		adv      string
+		auth     string
+		comments string
+	}
+}
+
+type application struct {
+	config config
+	logger *jsonlog.Logger
+	table  *routeTable
+}
+
+func main() {
+	var cfg config
+
+	flag.IntVar(&cfg.port, "port", 8000, "Gateway server port")
+	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
+	flag.StringVar(&cfg.routesFile, "routes", "", "Path to a JSON route table (defaults to the built-in table)")
+	flag.DurationVar(&cfg.healthInterval, "health-interval", 10*time.Second, "Interval between upstream health checks")
+	flag.StringVar(&cfg.upstreams.adv, "adv-url", "http://localhost:4000", "Base URL of the adv (teams) service")
+	flag.StringVar(&cfg.upstreams.auth, "auth-url", "http://localhost:8080", "Base URL of the auth service")
+	flag.StringVar(&cfg.upstreams.comments, "comments-url", "http://localhost:8081", "Base URL of the comment service")
+
+	flag.Parse()
+
+	logger := jsonlog.NewLogger(os.Stdout, jsonlog.LevelInfo)
+
+	tc := defaultTableConfig(cfg)
+	if cfg.routesFile != "" {
+		var err error
+		tc, err = loadTableConfig(cfg.routesFile)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	app := &application{
+		config: cfg,
+		logger: logger,
+	}
+
+	table, err := newRouteTable(tc, app.proxyErrorHandler)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	app.table = table
+
+	// Health checks run for the lifetime of the process, so the context is never
+	// cancelled.
+	go app.table.monitor(context.Background(), cfg.healthInterval, app.logger)
+
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.port),
+		Handler:      app.routes(),
+		ErrorLog:     log.New(logger, "", 0),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 2 * time.Minute,
+	}
+
+	logger.PrintInfo("starting gateway", map[string]string{
+		"addr": srv.Addr,
+		"env":  cfg.env,
+	})
+
+	err = srv.ListenAndServe()
+	logger.PrintFatal(err, nil)
+}
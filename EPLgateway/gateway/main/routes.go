@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func (app *application) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/healthcheck", app.healthcheckHandler)
+	mux.HandleFunc("/", app.proxyHandler)
+
+	return mux
+}
+
+func (app *application) proxyHandler(w http.ResponseWriter, r *http.Request) {
+	u := app.table.lookup(r)
+	if u == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), upstreamContextKey, u.name)
+	u.serveHTTP(w, r.WithContext(ctx), app.proxyErrorHandler)
+}
+
+// healthcheckHandler reports the gateway's own status along with the health of every
+// upstream target as last seen by the health checker.
+func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	upstreams := make(map[string]map[string]string)
+
+	for _, u := range app.table.upstreams {
+		targets := make(map[string]string)
+		for _, t := range u.targets {
+			status := "unavailable"
+			if t.healthy.Load() {
+				status = "available"
+			}
+			targets[t.url.String()] = status
+		}
+		upstreams[u.name] = targets
+	}
+
+	env := envelope{
+		"status": "available",
+		"system_info": map[string]string{
+			"environment": app.config.env,
+		},
+		"upstreams": upstreams,
+	}
+
+	err := writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duration wraps time.Duration so that the route table file can use strings such as
+// "5s" or "750ms" instead of a raw number of nanoseconds.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(jsonValue []byte) error {
+	unquoted, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return fmt.Errorf("invalid duration %s", jsonValue)
+	}
+
+	parsed, err := time.ParseDuration(unquoted)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", unquoted)
+	}
+
+	*d = duration(parsed)
+	return nil
+}
+
+type upstreamConfig struct {
+	Name       string   `json:"name"`
+	Targets    []string `json:"targets"`
+	Timeout    duration `json:"timeout"`
+	HealthPath string   `json:"health_path"`
+}
+
+type routeConfig struct {
+	Pattern  string   `json:"pattern"`
+	Methods  []string `json:"methods,omitempty"`
+	Upstream string   `json:"upstream"`
+}
+
+// tableConfig is the on-disk representation of the route table. Routes are matched
+// in the order they are listed, so more specific patterns must come first.
+type tableConfig struct {
+	Upstreams []upstreamConfig `json:"upstreams"`
+	Routes    []routeConfig    `json:"routes"`
+}
+
+func loadTableConfig(path string) (tableConfig, error) {
+	var tc tableConfig
+
+	f, err := os.Open(path)
+	if err != nil {
+		return tc, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(&tc)
+	if err != nil {
+		return tc, fmt.Errorf("route table %s: %w", path, err)
+	}
+
+	return tc, nil
+}
+
+// defaultTableConfig returns the route table used when no -routes file is given. It
+// points at the three services using the URLs from the command-line flags.
+func defaultTableConfig(cfg config) tableConfig {
+	return tableConfig{
+		Upstreams: []upstreamConfig{
+			{Name: "adv", Targets: []string{cfg.upstreams.adv}, Timeout: duration(10 * time.Second), HealthPath: "/v1/healthcheck"},
+			{Name: "auth", Targets: []string{cfg.upstreams.auth}, Timeout: duration(5 * time.Second)},
+			{Name: "comments", Targets: []string{cfg.upstreams.comments}, Timeout: duration(5 * time.Second)},
+		},
+		Routes: []routeConfig{
+			{Pattern: "/v1/teams/:id/comments", Upstream: "comments"},
+			{Pattern: "/v1/teams/:id/ratings", Upstream: "comments"},
+			{Pattern: "/v1/comments/*", Upstream: "comments"},
+			{Pattern: "/v1/ratings/*", Upstream: "comments"},
+			{Pattern: "/v1/teams", Upstream: "adv"},
+			{Pattern: "/v1/teams/*", Upstream: "adv"},
+			{Pattern: "/v1/players/*", Upstream: "adv"},
+			{Pattern: "/v1/stadiums", Upstream: "adv"},
+			{Pattern: "/v1/stadiums/*", Upstream: "adv"},
+			{Pattern: "/v1/matches", Upstream: "adv"},
+			{Pattern: "/v1/matches/*", Upstream: "adv"},
+			{Pattern: "/v1/standings", Upstream: "adv"},
+			{Pattern: "/v1/seasons", Upstream: "adv"},
+			{Pattern: "/v1/seasons/*", Upstream: "adv"},
+			{Pattern: "/v1/stats/*", Upstream: "adv"},
+			{Pattern: "/v1/crests/*", Upstream: "adv"},
+			{Pattern: "/v1/transfers", Upstream: "adv"},
+			{Pattern: "/v1/transfers/*", Upstream: "adv"},
+			{Pattern: "/v1/users", Upstream: "auth"},
+			{Pattern: "/v1/users/*", Upstream: "auth"},
+			{Pattern: "/v1/tokens/*", Upstream: "auth"},
+		},
+	}
+}
+
+// route is a compiled routeConfig. A pattern is a list of path segments where ":name"
+// matches exactly one segment and a trailing "*" matches any remaining segments.
+type route struct {
+	segments []string
+	methods  []string
+	upstream *upstream
+}
+
+func (rt route) matches(r *http.Request) bool {
+	if len(rt.methods) > 0 {
+		allowed := false
+		for _, m := range rt.methods {
+			if m == r.Method {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	path := splitPath(r.URL.Path)
+
+	for i, seg := range rt.segments {
+		if seg == "*" {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if !strings.HasPrefix(seg, ":") && seg != path[i] {
+			return false
+		}
+	}
+
+	return len(path) == len(rt.segments)
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+type routeTable struct {
+	routes    []route
+	upstreams []*upstream
+}
+
+func newRouteTable(tc tableConfig, errorHandler func(http.ResponseWriter, *http.Request, error)) (*routeTable, error) {
+	table := &routeTable{}
+	byName := make(map[string]*upstream)
+
+	for _, uc := range tc.Upstreams {
+		if uc.Name == "" {
+			return nil, errors.New("route table: upstream name must be provided")
+		}
+		if _, exists := byName[uc.Name]; exists {
+			return nil, fmt.Errorf("route table: duplicate upstream %q", uc.Name)
+		}
+
+		u, err := newUpstream(uc, errorHandler)
+		if err != nil {
+			return nil, fmt.Errorf("route table: %w", err)
+		}
+
+		byName[uc.Name] = u
+		table.upstreams = append(table.upstreams, u)
+	}
+
+	for _, rc := range tc.Routes {
+		u, ok := byName[rc.Upstream]
+		if !ok {
+			return nil, fmt.Errorf("route table: route %s refers to unknown upstream %q", rc.Pattern, rc.Upstream)
+		}
+
+		segments := splitPath(rc.Pattern)
+		for i, seg := range segments {
+			if seg == "*" && i != len(segments)-1 {
+				return nil, fmt.Errorf("route table: wildcard must be the last segment of %s", rc.Pattern)
+			}
+		}
+
+		table.routes = append(table.routes, route{
+			segments: segments,
+			methods:  rc.Methods,
+			upstream: u,
+		})
+	}
+
+	return table, nil
+}
+
+// lookup returns the upstream that should serve the request, or nil if no route
+// matches.
+func (t *routeTable) lookup(r *http.Request) *upstream {
+	for _, rt := range t.routes {
+		if rt.matches(r) {
+			return rt.upstream
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"EPLgateway/auth-service/jsonlog"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errNoHealthyTarget is returned when every target of an upstream has been removed
+// from rotation by the health checker.
+var errNoHealthyTarget = errors.New("no healthy target")
+
+type target struct {
+	url     *url.URL
+	proxy   *httputil.ReverseProxy
+	healthy atomic.Bool
+}
+
+// upstream is a named group of interchangeable targets. Requests are spread across
+// the healthy targets in round-robin order, and each proxied request is bounded by
+// the upstream's timeout.
+type upstream struct {
+	name       string
+	timeout    time.Duration
+	healthPath string
+	targets    []*target
+	next       atomic.Uint64
+}
+
+func newUpstream(uc upstreamConfig, errorHandler func(http.ResponseWriter, *http.Request, error)) (*upstream, error) {
+	if len(uc.Targets) == 0 {
+		return nil, fmt.Errorf("upstream %q has no targets", uc.Name)
+	}
+
+	u := &upstream{
+		name:       uc.Name,
+		timeout:    time.Duration(uc.Timeout),
+		healthPath: uc.HealthPath,
+	}
+
+	for _, raw := range uc.Targets {
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("upstream %q has invalid target %q", uc.Name, raw)
+		}
+
+		t := &target{url: parsed}
+		t.proxy = &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(parsed)
+				pr.SetXForwarded()
+			},
+			ErrorHandler: errorHandler,
+		}
+		// Targets start out healthy so that the gateway can serve traffic before the
+		// first round of health checks has completed.
+		t.healthy.Store(true)
+
+		u.targets = append(u.targets, t)
+	}
+
+	return u, nil
+}
+
+// pick returns the next healthy target, or nil if none are available.
+func (u *upstream) pick() *target {
+	n := uint64(len(u.targets))
+	start := u.next.Add(1)
+
+	for i := uint64(0); i < n; i++ {
+		t := u.targets[(start+i)%n]
+		if t.healthy.Load() {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (u *upstream) serveHTTP(w http.ResponseWriter, r *http.Request, errorHandler func(http.ResponseWriter, *http.Request, error)) {
+	t := u.pick()
+	if t == nil {
+		errorHandler(w, r, errNoHealthyTarget)
+		return
+	}
+
+	// Event streams and WebSockets stay open for as long as the client is listening,
+	// so neither the upstream timeout nor the server's write deadline applies to them.
+	if isEventStream(r) || isWebSocket(r) {
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	} else if u.timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), u.timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	t.proxy.ServeHTTP(w, r)
+}
+
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// check probes a single target. Upstreams with a health path must answer it with a
+// 2xx status; upstreams without one only need to accept a TCP connection.
+func (u *upstream) check(ctx context.Context, client *http.Client, t *target) error {
+	if u.healthPath == "" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", t.url.Host)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url.JoinPath(u.healthPath).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// checkAll probes every target of every upstream once, updating their health and
+// logging any transitions.
+func (t *routeTable) checkAll(ctx context.Context, client *http.Client, logger *jsonlog.Logger) {
+	var wg sync.WaitGroup
+
+	for _, u := range t.upstreams {
+		for _, tgt := range u.targets {
+			wg.Add(1)
+			go func(u *upstream, tgt *target) {
+				defer wg.Done()
+
+				checkCtx, cancel := context.WithTimeout(ctx, client.Timeout)
+				defer cancel()
+
+				err := u.check(checkCtx, client, tgt)
+				healthy := err == nil
+
+				if tgt.healthy.Swap(healthy) != healthy {
+					properties := map[string]string{
+						"upstream": u.name,
+						"target":   tgt.url.String(),
+					}
+					if healthy {
+						logger.PrintInfo("upstream target restored", properties)
+					} else {
+						logger.PrintError(fmt.Errorf("upstream target removed: %w", err), properties)
+					}
+				}
+			}(u, tgt)
+		}
+	}
+
+	wg.Wait()
+}
+
+// monitor runs health checks at the given interval until the context is cancelled.
+func (t *routeTable) monitor(ctx context.Context, interval time.Duration, logger *jsonlog.Logger) {
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		t.checkAll(ctx, client, logger)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}